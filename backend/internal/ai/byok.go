@@ -14,10 +14,10 @@ type BYOKPipeline struct {
 }
 
 func (p BYOKPipeline) Validate() error {
-	if p.Provider == "" || p.Model == "" {
+	if p.Provider == "" || (p.Model == "" && !Keyless(p.Provider)) {
 		return errors.New("provider/model required")
 	}
-	if p.APIKey == "" {
+	if p.APIKey == "" && !Keyless(p.Provider) {
 		return errors.New("api key required")
 	}
 	adapter := p.Adapter
@@ -30,15 +30,26 @@ func (p BYOKPipeline) Validate() error {
 	return adapter.Validate(p.APIKey, p.APIBase, p.Model)
 }
 
-func (p BYOKPipeline) GenerateDrafts(theme string, count int, character CharacterInput) ([]DraftIdea, error) {
+func (p BYOKPipeline) SuggestThemes(req ThemeRequest, character CharacterInput) ([]ThemeSuggestion, error) {
 	adapter := p.Adapter
 	if adapter == nil {
 		adapter = adapterFor(p.Provider)
 	}
 	if adapter == nil {
-		return p.Fallback.GenerateDrafts(theme, count, character)
+		return p.Fallback.SuggestThemes(req, character)
 	}
-	return adapter.GenerateDrafts(p.APIKey, p.APIBase, p.Model, theme, count, character)
+	return adapter.SuggestThemes(p.APIKey, p.APIBase, p.Model, req, character)
+}
+
+func (p BYOKPipeline) GenerateDrafts(req DraftRequest, character CharacterInput) ([]DraftIdea, error) {
+	adapter := p.Adapter
+	if adapter == nil {
+		adapter = adapterFor(p.Provider)
+	}
+	if adapter == nil {
+		return p.Fallback.GenerateDrafts(req, character)
+	}
+	return adapter.GenerateDrafts(p.APIKey, p.APIBase, p.Model, req, character)
 }
 
 func (p BYOKPipeline) GenerateImage(prompt string, character CharacterInput) (string, error) {
@@ -52,6 +63,27 @@ func (p BYOKPipeline) GenerateImage(prompt string, character CharacterInput) (st
 	return adapter.GenerateImage(p.APIKey, p.APIBase, p.Model, prompt, character)
 }
 
+func (p BYOKPipeline) GenerateImages(req ImageRequest, character CharacterInput) ([]GeneratedImage, error) {
+	if req.N < 1 {
+		req.N = 1
+	}
+	adapter := p.Adapter
+	if adapter == nil {
+		adapter = adapterFor(p.Provider)
+	}
+	if adapter == nil {
+		return p.Fallback.GenerateImages(req, character)
+	}
+	images, err := adapter.GenerateImages(p.APIKey, p.APIBase, p.Model, req, character)
+	for i := range images {
+		images[i].Provider = p.Provider
+		if images[i].Model == "" {
+			images[i].Model = p.Model
+		}
+	}
+	return images, err
+}
+
 func (p BYOKPipeline) RemoveBackground(imageURL string) (string, error) {
 	adapter := p.Adapter
 	if adapter == nil {
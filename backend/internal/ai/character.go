@@ -0,0 +1,67 @@
+package ai
+
+import "strings"
+
+// Describe renders the character definition as prompt text shared by every
+// adapter, so drafts and images stay on-model. Group casts are numbered so
+// the model keeps each character distinct.
+func (c CharacterInput) Describe() string {
+	if len(c.Companions) == 0 {
+		return c.describeOne()
+	}
+	cast := c.Cast()
+	parts := make([]string, 0, len(cast))
+	for i, m := range cast {
+		parts = append(parts, "("+itoa(i+1)+") "+m.describeOne())
+	}
+	return itoa(len(cast)) + " characters together: " + strings.Join(parts, " ")
+}
+
+// Cast returns the character followed by its companions.
+func (c CharacterInput) Cast() []CharacterInput {
+	primary := c
+	primary.Companions = nil
+	return append([]CharacterInput{primary}, c.Companions...)
+}
+
+func (c CharacterInput) describeOne() string {
+	parts := []string{}
+	head := strings.TrimSpace(c.Prompt)
+	if c.Name != "" {
+		if head != "" {
+			head = c.Name + ", " + head
+		} else {
+			head = c.Name
+		}
+	}
+	if head != "" {
+		parts = append(parts, head)
+	}
+	if c.Personality != "" {
+		parts = append(parts, "personality: "+c.Personality)
+	}
+	if len(c.Palette) > 0 {
+		parts = append(parts, "colour palette: "+strings.Join(c.Palette, ", "))
+	}
+	if c.StyleNotes != "" {
+		parts = append(parts, "style: "+c.StyleNotes)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// ReferenceImages returns every reference image of the cast, including the
+// legacy single URL, primary character first.
+func (c CharacterInput) ReferenceImages() []string {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, m := range c.Cast() {
+		for _, u := range append([]string{m.ReferenceImageURL}, m.ReferenceImageURLs...) {
+			if u == "" || seen[u] {
+				continue
+			}
+			seen[u] = true
+			out = append(out, u)
+		}
+	}
+	return out
+}
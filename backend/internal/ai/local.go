@@ -0,0 +1,278 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"example.com/app/internal/imageio"
+)
+
+// LocalProvider removes uniform backgrounds in-process; it needs no API key.
+const LocalProvider = "local"
+
+// LocalDefaultModel is the local method used when none is configured.
+const LocalDefaultModel = "auto"
+
+// Keyless reports whether a provider works without credentials.
+func Keyless(provider string) bool {
+	return provider == LocalProvider
+}
+
+// LocalAdapter implements background removal only. The model selects the
+// method and may carry options, e.g. "chroma-key,color=#00FF00,tolerance=60".
+//
+//	auto        flood fill from the edges; fails when the border is not uniform
+//	flood-fill  flood fill from the edges, keeping enclosed background-coloured areas
+//	chroma-key  removes every pixel close to the key colour
+type LocalAdapter struct{}
+
+type localOptions struct {
+	method    string
+	key       *color.NRGBA
+	tolerance float64
+	feather   float64
+}
+
+const (
+	defaultLocalTolerance = 32
+	defaultLocalFeather   = 16
+	// maxBorderSpread is how far border pixels may stray from the estimated
+	// background before auto mode gives up.
+	maxBorderSpread = 48
+)
+
+func parseLocalModel(model string) (localOptions, error) {
+	opts := localOptions{method: LocalDefaultModel, tolerance: defaultLocalTolerance, feather: defaultLocalFeather}
+	parts := strings.Split(model, ",")
+	if m := strings.TrimSpace(parts[0]); m != "" {
+		opts.method = m
+	}
+	switch opts.method {
+	case "auto", "flood-fill", "chroma-key":
+	default:
+		return opts, errors.New("local model must be auto, flood-fill or chroma-key")
+	}
+	for _, part := range parts[1:] {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return opts, fmt.Errorf("invalid option %q", part)
+		}
+		switch k {
+		case "color":
+			c, err := parseKeyColor(v)
+			if err != nil {
+				return opts, err
+			}
+			opts.key = &c
+		case "tolerance", "feather":
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil || n < 0 || n > 255 {
+				return opts, fmt.Errorf("%s must be between 0 and 255", k)
+			}
+			if k == "tolerance" {
+				opts.tolerance = n
+			} else {
+				opts.feather = n
+			}
+		default:
+			return opts, fmt.Errorf("unknown option %q", k)
+		}
+	}
+	return opts, nil
+}
+
+func parseKeyColor(v string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(v, "#")
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return color.NRGBA{}, errors.New("color must be #RRGGBB")
+	}
+	return color.NRGBA{R: uint8(n >> 16), G: uint8(n >> 8), B: uint8(n), A: 255}, nil
+}
+
+func (a LocalAdapter) Validate(apiKey string, apiBase string, model string) error {
+	_, err := parseLocalModel(model)
+	return err
+}
+
+func (a LocalAdapter) SuggestThemes(apiKey, apiBase, model string, req ThemeRequest, character CharacterInput) ([]ThemeSuggestion, error) {
+	return nil, errors.New("local provider only removes backgrounds")
+}
+
+func (a LocalAdapter) GenerateDrafts(apiKey, apiBase, model string, req DraftRequest, character CharacterInput) ([]DraftIdea, error) {
+	return nil, errors.New("local provider only removes backgrounds")
+}
+
+func (a LocalAdapter) GenerateImage(apiKey, apiBase, model, prompt string, character CharacterInput) (string, error) {
+	return "", errors.New("local provider only removes backgrounds")
+}
+
+func (a LocalAdapter) GenerateImages(apiKey, apiBase, model string, req ImageRequest, character CharacterInput) ([]GeneratedImage, error) {
+	return nil, errors.New("local provider only removes backgrounds")
+}
+
+// RemoveBackground returns the cut-out as a PNG data URL.
+func (a LocalAdapter) RemoveBackground(apiKey, apiBase, model, imageURL string) (string, error) {
+	opts, err := parseLocalModel(model)
+	if err != nil {
+		return "", err
+	}
+	src, err := imageio.Load(imageURL)
+	if err != nil {
+		return "", err
+	}
+	img := toNRGBA(src)
+	key, spread := borderColor(img)
+	if opts.key != nil {
+		key = *opts.key
+	} else if opts.method == "auto" && spread > maxBorderSpread {
+		return "", errors.New("background is not uniform enough for local removal")
+	}
+	switch opts.method {
+	case "chroma-key":
+		chromaKey(img, key, opts.tolerance, opts.feather)
+	default:
+		floodFill(img, key, opts.tolerance, opts.feather)
+	}
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func toNRGBA(src image.Image) *image.NRGBA {
+	b := src.Bounds()
+	img := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			img.Set(x, y, src.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return img
+}
+
+// borderColor estimates the background as the per-channel median of the
+// outermost pixels and reports how far the border strays from it (90th
+// percentile distance).
+func borderColor(img *image.NRGBA) (color.NRGBA, float64) {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	border := []color.NRGBA{}
+	for x := 0; x < w; x++ {
+		border = append(border, img.NRGBAAt(x, 0), img.NRGBAAt(x, h-1))
+	}
+	for y := 1; y < h-1; y++ {
+		border = append(border, img.NRGBAAt(0, y), img.NRGBAAt(w-1, y))
+	}
+	channel := func(get func(color.NRGBA) uint8) uint8 {
+		v := make([]int, len(border))
+		for i, c := range border {
+			v[i] = int(get(c))
+		}
+		sort.Ints(v)
+		return uint8(v[len(v)/2])
+	}
+	key := color.NRGBA{
+		R: channel(func(c color.NRGBA) uint8 { return c.R }),
+		G: channel(func(c color.NRGBA) uint8 { return c.G }),
+		B: channel(func(c color.NRGBA) uint8 { return c.B }),
+		A: 255,
+	}
+	dists := make([]float64, len(border))
+	for i, c := range border {
+		dists[i] = colorDistance(c, key)
+	}
+	sort.Float64s(dists)
+	return key, dists[len(dists)*9/10]
+}
+
+func colorDistance(a, b color.NRGBA) float64 {
+	dr := float64(a.R) - float64(b.R)
+	dg := float64(a.G) - float64(b.G)
+	db := float64(a.B) - float64(b.B)
+	return math.Sqrt(dr*dr + dg*dg + db*db)
+}
+
+// keyAlpha maps a distance from the key colour to an alpha factor: 0 within
+// tolerance, rising linearly to 1 across the feather band.
+func keyAlpha(d, tolerance, feather float64) float64 {
+	if d <= tolerance {
+		return 0
+	}
+	if feather <= 0 || d >= tolerance+feather {
+		return 1
+	}
+	return (d - tolerance) / feather
+}
+
+// floodFill clears background connected to the image edges. Pixels in the
+// feather band become partly transparent and stop the fill, which keeps
+// enclosed areas of the same colour (eyes, teeth) intact.
+func floodFill(img *image.NRGBA, key color.NRGBA, tolerance, feather float64) {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	visited := make([]bool, w*h)
+	queue := []int{}
+	push := func(x, y int) {
+		i := y*w + x
+		if visited[i] {
+			return
+		}
+		visited[i] = true
+		c := img.NRGBAAt(x, y)
+		f := keyAlpha(colorDistance(c, key), tolerance, feather)
+		if f >= 1 {
+			return
+		}
+		c.A = uint8(float64(c.A) * f)
+		img.SetNRGBA(x, y, c)
+		if f == 0 {
+			queue = append(queue, i)
+		}
+	}
+	for x := 0; x < w; x++ {
+		push(x, 0)
+		push(x, h-1)
+	}
+	for y := 0; y < h; y++ {
+		push(0, y)
+		push(w-1, y)
+	}
+	for len(queue) > 0 {
+		i := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		x, y := i%w, i/w
+		if x > 0 {
+			push(x-1, y)
+		}
+		if x < w-1 {
+			push(x+1, y)
+		}
+		if y > 0 {
+			push(x, y-1)
+		}
+		if y < h-1 {
+			push(x, y+1)
+		}
+	}
+}
+
+// chromaKey clears every pixel close to the key colour.
+func chromaKey(img *image.NRGBA, key color.NRGBA, tolerance, feather float64) {
+	b := img.Rect
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := img.NRGBAAt(x, y)
+			c.A = uint8(float64(c.A) * keyAlpha(colorDistance(c, key), tolerance, feather))
+			img.SetNRGBA(x, y, c)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -27,9 +28,12 @@ type openAIChatResponse struct {
 }
 
 type openAIImageRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	Size   string `json:"size"`
+	Model   string `json:"model"`
+	Prompt  string `json:"prompt"`
+	Size    string `json:"size"`
+	N       int    `json:"n,omitempty"`
+	Style   string `json:"style,omitempty"`
+	Quality string `json:"quality,omitempty"`
 }
 
 type openAIImageResponse struct {
@@ -45,13 +49,50 @@ func (a OpenAIAdapter) Validate(apiKey string, apiBase string, model string) err
 	return nil
 }
 
-func (a OpenAIAdapter) GenerateDrafts(apiKey, apiBase, model, theme string, count int, character CharacterInput) ([]DraftIdea, error) {
+func (a OpenAIAdapter) SuggestThemes(apiKey, apiBase, model string, req ThemeRequest, character CharacterInput) ([]ThemeSuggestion, error) {
 	base := defaultBase(apiBase)
+	payload := openAIChatRequest{
+		Model: model,
+		Messages: []openAIMessage{
+			{Role: "system", Content: "You are a LINE Creators Market expert who plans sticker sets."},
+			{Role: "user", Content: themePrompt(req, character)},
+		},
+	}
+
+	body, _ := json.Marshal(payload)
+	respBody, err := retry(3, 300*time.Millisecond, func() ([]byte, error) {
+		return doJSON(base+"/v1/chat/completions", apiKey, body)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var chatResp openAIChatResponse
+	if err := json.Unmarshal(respBody, &chatResp); err != nil {
+		return nil, err
+	}
+	if len(chatResp.Choices) == 0 {
+		return nil, errors.New("no choices")
+	}
+
+	var themes []ThemeSuggestion
+	if err := json.Unmarshal([]byte(stripCodeFence(chatResp.Choices[0].Message.Content)), &themes); err != nil {
+		return nil, err
+	}
+	return rankThemes(themes), nil
+}
+
+func (a OpenAIAdapter) GenerateDrafts(apiKey, apiBase, model string, req DraftRequest, character CharacterInput) ([]DraftIdea, error) {
+	base := defaultBase(apiBase)
+	prompt, err := draftsPrompt(req, character)
+	if err != nil {
+		return nil, err
+	}
 	payload := openAIChatRequest{
 		Model: model,
 		Messages: []openAIMessage{
 			{Role: "system", Content: "You generate sticker drafts. Return JSON array with caption and imagePrompt."},
-			{Role: "user", Content: "Theme: " + theme + ". Character: " + character.Prompt + ". Count: " + itoa(count)},
+			{Role: "user", Content: prompt},
 		},
 	}
 
@@ -78,28 +119,94 @@ func (a OpenAIAdapter) GenerateDrafts(apiKey, apiBase, model, theme string, coun
 	return ideas, nil
 }
 
+func supportsImageStyle(model string) bool {
+	return strings.HasPrefix(model, "dall-e-3")
+}
+
 func (a OpenAIAdapter) GenerateImage(apiKey, apiBase, model, prompt string, character CharacterInput) (string, error) {
+	images, err := a.GenerateImages(apiKey, apiBase, model, ImageRequest{Prompt: prompt, N: 1}, character)
+	if err != nil {
+		return "", err
+	}
+	return images[0].URL, nil
+}
+
+// GenerateImages asks for req.N images in one call and tops up with further
+// calls when the model caps n (dall-e-3 only returns a single image).
+func (a OpenAIAdapter) GenerateImages(apiKey, apiBase, model string, req ImageRequest, character CharacterInput) ([]GeneratedImage, error) {
 	base := defaultBase(apiBase)
-	payload := openAIImageRequest{
-		Model:  model,
-		Prompt: prompt,
-		Size:   "1024x1024",
+	if req.N < 1 {
+		req.N = 1
 	}
-	body, _ := json.Marshal(payload)
-	respBody, err := retry(3, 300*time.Millisecond, func() ([]byte, error) {
-		return doJSON(base+"/v1/images/generations", apiKey, body)
-	})
+	prompt, err := imagePrompt(req, character)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	var imgResp openAIImageResponse
-	if err := json.Unmarshal(respBody, &imgResp); err != nil {
-		return "", err
+	// the images API has no negative prompt, so state it in the prompt
+	if req.NegativePrompt != "" {
+		prompt += ". Avoid: " + req.NegativePrompt
+	}
+	// style and the "hd" quality only exist on dall-e-3; other models
+	// reject them
+	var style, quality string
+	if supportsImageStyle(model) {
+		style, _ = req.Params["style"].(string)
+		quality, _ = req.Params["quality"].(string)
+	}
+	urls := []string{}
+	for attempt := 0; len(urls) < req.N && attempt < req.N; attempt++ {
+		payload := openAIImageRequest{
+			Model:   model,
+			Prompt:  prompt,
+			Size:    "1024x1024",
+			N:       req.N - len(urls),
+			Style:   style,
+			Quality: quality,
+		}
+		body, _ := json.Marshal(payload)
+		respBody, err := retry(3, 300*time.Millisecond, func() ([]byte, error) {
+			return doJSON(base+"/v1/images/generations", apiKey, body)
+		})
+		if err != nil && payload.N > 1 {
+			// Some models reject n>1 outright; retry one image at a time.
+			payload.N = 1
+			body, _ = json.Marshal(payload)
+			respBody, err = retry(3, 300*time.Millisecond, func() ([]byte, error) {
+				return doJSON(base+"/v1/images/generations", apiKey, body)
+			})
+		}
+		if err != nil {
+			if len(urls) > 0 {
+				break
+			}
+			return nil, err
+		}
+		var imgResp openAIImageResponse
+		if err := json.Unmarshal(respBody, &imgResp); err != nil {
+			return nil, err
+		}
+		for _, d := range imgResp.Data {
+			if d.URL != "" && len(urls) < req.N {
+				urls = append(urls, d.URL)
+			}
+		}
+	}
+	if len(urls) == 0 {
+		return nil, errors.New("no image")
+	}
+	// the images API takes no seed, so only the prompt and options are kept
+	params := map[string]interface{}{"size": "1024x1024"}
+	if style != "" {
+		params["style"] = style
+	}
+	if quality != "" {
+		params["quality"] = quality
 	}
-	if len(imgResp.Data) == 0 {
-		return "", errors.New("no image")
+	images := make([]GeneratedImage, 0, len(urls))
+	for _, url := range urls {
+		images = append(images, GeneratedImage{URL: url, Prompt: prompt, Model: model, Params: params})
 	}
-	return imgResp.Data[0].URL, nil
+	return images, nil
 }
 
 func (a OpenAIAdapter) RemoveBackground(apiKey, apiBase, model, imageURL string) (string, error) {
@@ -1,9 +1,18 @@
 package ai
 
 type CharacterInput struct {
-	Prompt            string
-	ReferenceImageURL string
-	SourceType        string
+	Name               string
+	Prompt             string
+	Personality        string
+	Palette            []string
+	StyleNotes         string
+	ReferenceImageURL  string
+	ReferenceImageURLs []string
+	SourceType         string
+
+	// Companions are the other characters appearing with this one in duo or
+	// group stickers.
+	Companions []CharacterInput
 }
 
 type DraftIdea struct {
@@ -11,21 +20,59 @@ type DraftIdea struct {
 	ImagePrompt string
 }
 
+// ImageRequest describes one image generation call. N asks for several
+// candidates of the same prompt; adapters that cannot batch fall back to
+// repeated calls. Params are provider-specific extras (e.g. guidance_scale).
+type ImageRequest struct {
+	Prompt         string
+	NegativePrompt string
+	Params         map[string]interface{}
+	N              int
+
+	// Template overrides DefaultImageTemplate; Vars fill its other fields.
+	Template string
+	Vars     PromptVars
+
+	// Seed fixes the first image's seed on providers that accept one; 0 lets
+	// the adapter pick. Further images use Seed+1, Seed+2, ...
+	Seed int64
+}
+
+// GeneratedImage is one image together with everything needed to reproduce
+// it: the prompt as sent, the seed (0 when the provider has none) and the
+// provider parameters.
+type GeneratedImage struct {
+	URL            string                 `json:"-"`
+	Prompt         string                 `json:"prompt"`
+	NegativePrompt string                 `json:"negativePrompt,omitempty"`
+	Seed           int64                  `json:"seed,omitempty"`
+	Provider       string                 `json:"provider,omitempty"`
+	Model          string                 `json:"model,omitempty"`
+	Params         map[string]interface{} `json:"params,omitempty"`
+}
+
 type Pipeline interface {
-	GenerateDrafts(theme string, count int, character CharacterInput) ([]DraftIdea, error)
+	SuggestThemes(req ThemeRequest, character CharacterInput) ([]ThemeSuggestion, error)
+	GenerateDrafts(req DraftRequest, character CharacterInput) ([]DraftIdea, error)
 	GenerateImage(prompt string, character CharacterInput) (string, error)
+	GenerateImages(req ImageRequest, character CharacterInput) ([]GeneratedImage, error)
 	RemoveBackground(imageURL string) (string, error)
 }
 
 // MockPipeline provides deterministic placeholder output for MVP.
 type MockPipeline struct{}
 
-func (m MockPipeline) GenerateDrafts(theme string, count int, character CharacterInput) ([]DraftIdea, error) {
-	ideas := make([]DraftIdea, 0, count)
-	for i := 1; i <= count; i++ {
+func (m MockPipeline) SuggestThemes(req ThemeRequest, character CharacterInput) ([]ThemeSuggestion, error) {
+	return SuggestCuratedThemes(req, character), nil
+}
+
+func (m MockPipeline) GenerateDrafts(req DraftRequest, character CharacterInput) ([]DraftIdea, error) {
+	theme := req.Theme
+	ideas := make([]DraftIdea, 0, req.Count)
+	for i := 1; i <= req.Count; i++ {
 		ideas = append(ideas, DraftIdea{
 			Caption:     theme + " " + "貼圖" + " " + itoa(i),
-			ImagePrompt: character.Prompt + " / " + theme + " / action " + itoa(i),
+			ImagePrompt: character.Describe() + " / " + theme + " / action " + itoa(i),
 		})
 	}
 	return ideas, nil
@@ -35,6 +82,25 @@ func (m MockPipeline) GenerateImage(prompt string, character CharacterInput) (st
 	return "https://example.com/sticker.png", nil
 }
 
+func (m MockPipeline) GenerateImages(req ImageRequest, character CharacterInput) ([]GeneratedImage, error) {
+	prompt, err := imagePrompt(req, character)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]GeneratedImage, 0, req.N)
+	for i := 0; i < req.N; i++ {
+		url, _ := m.GenerateImage(prompt, character)
+		out = append(out, GeneratedImage{
+			URL:            url,
+			Prompt:         prompt,
+			NegativePrompt: req.NegativePrompt,
+			Provider:       "mock",
+			Params:         req.Params,
+		})
+	}
+	return out, nil
+}
+
 func (m MockPipeline) RemoveBackground(imageURL string) (string, error) {
 	return "https://example.com/sticker-transparent.png", nil
 }
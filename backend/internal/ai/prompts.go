@@ -0,0 +1,79 @@
+package ai
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+)
+
+// PromptVars are the variables available to prompt templates.
+type PromptVars struct {
+	Theme     string
+	Character string
+	Locale    string
+	Style     string
+	Index     int
+	Count     int
+	Caption   string
+	Prompt    string
+}
+
+// DraftRequest describes one draft generation call. Template overrides
+// DefaultDraftsTemplate when set.
+type DraftRequest struct {
+	Theme    string
+	Count    int
+	Locale   string
+	Style    string
+	Template string
+}
+
+// DefaultDraftsTemplate reproduces the original "Theme / Character / Count"
+// instruction sent to text providers.
+const DefaultDraftsTemplate = `Theme: {{.Theme}}. Character: {{.Character}}. Count: {{.Count}}` +
+	`{{if .Locale}}. Write captions in {{.Locale}}{{end}}` +
+	`{{if .Style}}. Art style: {{.Style}}{{end}}`
+
+// DefaultImageTemplate appends the character definition to the draft prompt.
+const DefaultImageTemplate = `{{.Prompt}}{{if .Character}}. Character: {{.Character}}{{end}}`
+
+// RenderPrompt executes a text/template prompt. Unknown fields are errors so
+// typos surface when a template is saved or previewed.
+func RenderPrompt(tpl string, vars PromptVars) (string, error) {
+	t, err := template.New("prompt").Option("missingkey=error").Parse(tpl)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// draftsPrompt renders the drafts template for a request and character.
+func draftsPrompt(req DraftRequest, character CharacterInput) (string, error) {
+	tpl := req.Template
+	if tpl == "" {
+		tpl = DefaultDraftsTemplate
+	}
+	return RenderPrompt(tpl, PromptVars{
+		Theme:     req.Theme,
+		Character: character.Describe(),
+		Locale:    req.Locale,
+		Style:     req.Style,
+		Count:     req.Count,
+	})
+}
+
+// imagePrompt renders the image template around the (styled) draft prompt.
+func imagePrompt(req ImageRequest, character CharacterInput) (string, error) {
+	tpl := req.Template
+	if tpl == "" {
+		tpl = DefaultImageTemplate
+	}
+	vars := req.Vars
+	vars.Prompt = req.Prompt
+	vars.Character = character.Describe()
+	return RenderPrompt(tpl, vars)
+}
@@ -5,8 +5,10 @@ import "errors"
 // ProviderAdapter defines real provider integrations.
 type ProviderAdapter interface {
 	Validate(apiKey string, apiBase string, model string) error
-	GenerateDrafts(apiKey, apiBase, model, theme string, count int, character CharacterInput) ([]DraftIdea, error)
+	SuggestThemes(apiKey, apiBase, model string, req ThemeRequest, character CharacterInput) ([]ThemeSuggestion, error)
+	GenerateDrafts(apiKey, apiBase, model string, req DraftRequest, character CharacterInput) ([]DraftIdea, error)
 	GenerateImage(apiKey, apiBase, model, prompt string, character CharacterInput) (string, error)
+	GenerateImages(apiKey, apiBase, model string, req ImageRequest, character CharacterInput) ([]GeneratedImage, error)
 	RemoveBackground(apiKey, apiBase, model, imageURL string) (string, error)
 }
 
@@ -24,7 +26,11 @@ func (g GenericAdapter) Validate(apiKey string, apiBase string, model string) er
 	return nil
 }
 
-func (g GenericAdapter) GenerateDrafts(apiKey, apiBase, model, theme string, count int, character CharacterInput) ([]DraftIdea, error) {
+func (g GenericAdapter) SuggestThemes(apiKey, apiBase, model string, req ThemeRequest, character CharacterInput) ([]ThemeSuggestion, error) {
+	return nil, errors.New("provider not implemented")
+}
+
+func (g GenericAdapter) GenerateDrafts(apiKey, apiBase, model string, req DraftRequest, character CharacterInput) ([]DraftIdea, error) {
 	return nil, errors.New("provider not implemented")
 }
 
@@ -32,16 +38,31 @@ func (g GenericAdapter) GenerateImage(apiKey, apiBase, model, prompt string, cha
 	return "", errors.New("provider not implemented")
 }
 
+func (g GenericAdapter) GenerateImages(apiKey, apiBase, model string, req ImageRequest, character CharacterInput) ([]GeneratedImage, error) {
+	return nil, errors.New("provider not implemented")
+}
+
 func (g GenericAdapter) RemoveBackground(apiKey, apiBase, model, imageURL string) (string, error) {
 	return imageURL, nil
 }
 
+// CanonicalProvider maps provider aliases to the id their adapter and
+// settings are keyed by.
+func CanonicalProvider(provider string) string {
+	if provider == "chatgpt" {
+		return "openai"
+	}
+	return provider
+}
+
 func adapterFor(provider string) ProviderAdapter {
-	switch provider {
-	case "openai", "chatgpt":
+	switch CanonicalProvider(provider) {
+	case "openai":
 		return OpenAIAdapter{}
 	case "replicate":
 		return ReplicateAdapter{}
+	case LocalProvider:
+		return LocalAdapter{}
 	case "gemini", "copilot", "grok", "kimi", "deepseek", "other":
 		return GenericAdapter{Provider: provider}
 	default:
@@ -4,10 +4,23 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"math/rand"
 	"net/http"
+	"strings"
 	"time"
 )
 
+// Replicate models name their image inputs differently, so reference images
+// are only sent to inputs the request names: ParamReferenceImage takes the
+// first reference (e.g. "image" on img2img models) and ParamReferenceImages
+// the whole list. The names come from a style preset's provider params or
+// from options on the model, e.g. "<version>,reference_image_input=image".
+const (
+	ParamReferenceImage  = "reference_image_input"
+	ParamReferenceImages = "reference_images_input"
+)
+
 type replicateRequest struct {
 	Version string                 `json:"version"`
 	Input   map[string]interface{} `json:"input"`
@@ -32,18 +45,82 @@ func (a ReplicateAdapter) Validate(apiKey string, apiBase string, model string)
 	if model == "" {
 		return errors.New("missing model version")
 	}
-	return nil
+	_, _, err := parseReplicateModel(model)
+	return err
+}
+
+// parseReplicateModel splits the options off a model, returning the version
+// and the reference input names it sets.
+func parseReplicateModel(model string) (string, map[string]string, error) {
+	parts := strings.Split(model, ",")
+	opts := map[string]string{}
+	for _, part := range parts[1:] {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || v == "" {
+			return "", nil, fmt.Errorf("invalid option %q", part)
+		}
+		switch k {
+		case ParamReferenceImage, ParamReferenceImages:
+			opts[k] = v
+		default:
+			return "", nil, fmt.Errorf("unknown option %q", k)
+		}
+	}
+	return strings.TrimSpace(parts[0]), opts, nil
+}
+
+func (a ReplicateAdapter) SuggestThemes(apiKey, apiBase, model string, req ThemeRequest, character CharacterInput) ([]ThemeSuggestion, error) {
+	base := apiBase
+	if base == "" {
+		base = "https://api.replicate.com/v1"
+	}
+	payload := replicateRequest{
+		Version: model,
+		Input: map[string]interface{}{
+			"prompt": themePrompt(req, character),
+		},
+	}
+	body, _ := json.Marshal(payload)
+	respBody, err := retry(3, 300*time.Millisecond, func() ([]byte, error) {
+		return doReplicateJSON(base+"/predictions", apiKey, body)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var r replicateResponse
+	if err := json.Unmarshal(respBody, &r); err != nil {
+		return nil, err
+	}
+	if r.URLs.Get == "" {
+		return nil, errors.New("no prediction url")
+	}
+	out, err := retry(3, 300*time.Millisecond, func() (interface{}, error) {
+		return replicatePoll(r.URLs.Get, apiKey)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// language models on Replicate stream tokens, so join them before parsing
+	var themes []ThemeSuggestion
+	if err := json.Unmarshal([]byte(stripCodeFence(joinText(out))), &themes); err != nil {
+		return nil, err
+	}
+	return rankThemes(themes), nil
 }
 
-func (a ReplicateAdapter) GenerateDrafts(apiKey, apiBase, model, theme string, count int, character CharacterInput) ([]DraftIdea, error) {
+func (a ReplicateAdapter) GenerateDrafts(apiKey, apiBase, model string, req DraftRequest, character CharacterInput) ([]DraftIdea, error) {
 	base := apiBase
 	if base == "" {
 		base = "https://api.replicate.com/v1"
 	}
+	prompt, err := draftsPrompt(req, character)
+	if err != nil {
+		return nil, err
+	}
 	payload := replicateRequest{
 		Version: model,
 		Input: map[string]interface{}{
-			"prompt": "Theme: " + theme + ", Character: " + character.Prompt + ", Count: " + itoa(count),
+			"prompt": prompt,
 		},
 	}
 	body, _ := json.Marshal(payload)
@@ -85,15 +162,86 @@ func (a ReplicateAdapter) GenerateDrafts(apiKey, apiBase, model, theme string, c
 }
 
 func (a ReplicateAdapter) GenerateImage(apiKey, apiBase, model, prompt string, character CharacterInput) (string, error) {
+	images, err := a.GenerateImages(apiKey, apiBase, model, ImageRequest{Prompt: prompt, N: 1}, character)
+	if err != nil {
+		return "", err
+	}
+	return images[0].URL, nil
+}
+
+// GenerateImages runs one prediction per image so that every image has its
+// own seed and can be reproduced on its own.
+func (a ReplicateAdapter) GenerateImages(apiKey, apiBase, model string, req ImageRequest, character CharacterInput) ([]GeneratedImage, error) {
 	base := apiBase
 	if base == "" {
 		base = "https://api.replicate.com/v1"
 	}
+	if req.N < 1 {
+		req.N = 1
+	}
+	version, modelOpts, err := parseReplicateModel(model)
+	if err != nil {
+		return nil, err
+	}
+	input := map[string]interface{}{}
+	for k, v := range req.Params {
+		input[k] = v
+	}
+	// the model's own input names win over a preset's
+	for k, v := range modelOpts {
+		input[k] = v
+	}
+	firstInput, _ := input[ParamReferenceImage].(string)
+	allInput, _ := input[ParamReferenceImages].(string)
+	delete(input, ParamReferenceImage)
+	delete(input, ParamReferenceImages)
+	prompt, err := imagePrompt(req, character)
+	if err != nil {
+		return nil, err
+	}
+	input["prompt"] = prompt
+	if req.NegativePrompt != "" {
+		input["negative_prompt"] = req.NegativePrompt
+	}
+	if refs := character.ReferenceImages(); len(refs) > 0 {
+		if firstInput != "" {
+			input[firstInput] = refs[0]
+		}
+		if allInput != "" {
+			input[allInput] = refs
+		}
+	}
+	seed := req.Seed
+	if seed == 0 {
+		seed = RandomSeed()
+	}
+	images := []GeneratedImage{}
+	for i := 0; i < req.N; i++ {
+		input["seed"] = seed + int64(i)
+		url, err := replicatePredict(base, apiKey, version, input)
+		if err != nil {
+			if len(images) > 0 {
+				break
+			}
+			return nil, err
+		}
+		images = append(images, GeneratedImage{
+			URL:            url,
+			Prompt:         prompt,
+			NegativePrompt: req.NegativePrompt,
+			Seed:           seed + int64(i),
+			Model:          model,
+			Params:         req.Params,
+		})
+	}
+	return images, nil
+}
+
+// replicatePredict starts a prediction and returns its first output URL.
+func replicatePredict(base, apiKey, model string, input map[string]interface{}) (string, error) {
 	payload := replicateRequest{
 		Version: model,
-		Input: map[string]interface{}{
-			"prompt": prompt,
-		},
+		Input:   input,
 	}
 	body, _ := json.Marshal(payload)
 	respBody, err := retry(3, 300*time.Millisecond, func() ([]byte, error) {
@@ -116,10 +264,16 @@ func (a ReplicateAdapter) GenerateImage(apiKey, apiBase, model, prompt string, c
 	if err != nil {
 		return "", err
 	}
-	if url, ok := out.(string); ok {
-		return url, nil
+	urls := extractURLs(out)
+	if len(urls) == 0 {
+		return "", errors.New("unexpected output")
 	}
-	return "", errors.New("unexpected output")
+	return urls[0], nil
+}
+
+// RandomSeed picks a seed in the 32-bit range most diffusion models accept.
+func RandomSeed() int64 {
+	return rand.Int63n(1<<31-1) + 1
 }
 
 func (a ReplicateAdapter) RemoveBackground(apiKey, apiBase, model, imageURL string) (string, error) {
@@ -200,6 +354,38 @@ func replicatePoll(url, apiKey string) (interface{}, error) {
 	return r.Output, nil
 }
 
+func joinText(out interface{}) string {
+	switch v := out.(type) {
+	case string:
+		return v
+	case []interface{}:
+		var b bytes.Buffer
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				b.WriteString(s)
+			}
+		}
+		return b.String()
+	}
+	return ""
+}
+
+func extractURLs(out interface{}) []string {
+	switch v := out.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		urls := []string{}
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				urls = append(urls, s)
+			}
+		}
+		return urls
+	}
+	return nil
+}
+
 func extractURL(out interface{}) string {
 	switch v := out.(type) {
 	case string:
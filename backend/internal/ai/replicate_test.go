@@ -0,0 +1,84 @@
+package ai
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// fakeReplicate answers predictions with a fixed output and records the
+// version and input of the last one.
+func fakeReplicate(t *testing.T) (*httptest.Server, *replicateRequest) {
+	t.Helper()
+	last := &replicateRequest{}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/predictions":
+			*last = replicateRequest{}
+			if err := json.NewDecoder(r.Body).Decode(last); err != nil {
+				t.Errorf("decode prediction: %v", err)
+			}
+			w.Write([]byte(`{"urls":{"get":"` + srv.URL + `/predictions/1"}}`))
+		case "/predictions/1":
+			w.Write([]byte(`{"status":"succeeded","output":["https://example.com/out.png"]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, last
+}
+
+func TestReplicateReferenceInputs(t *testing.T) {
+	srv, last := fakeReplicate(t)
+	character := CharacterInput{Name: "Mochi", ReferenceImageURL: "data:image/png;base64,AAAA", ReferenceImageURLs: []string{"data:image/png;base64,BBBB"}}
+	refs := []interface{}{"data:image/png;base64,AAAA", "data:image/png;base64,BBBB"}
+
+	tests := []struct {
+		name   string
+		model  string
+		params map[string]interface{}
+		want   map[string]interface{}
+	}{
+		{"no names", "v1", map[string]interface{}{"guidance_scale": 7.5}, map[string]interface{}{"guidance_scale": 7.5}},
+		{"preset names", "v1", map[string]interface{}{ParamReferenceImage: "image", ParamReferenceImages: "input_images"},
+			map[string]interface{}{"image": refs[0], "input_images": refs}},
+		{"model names", "v1,reference_image_input=init_image", nil, map[string]interface{}{"init_image": refs[0]}},
+		{"model wins", "v1,reference_image_input=init_image", map[string]interface{}{ParamReferenceImage: "image"},
+			map[string]interface{}{"init_image": refs[0]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReplicateAdapter{}.GenerateImages("key", srv.URL, tt.model, ImageRequest{Prompt: "wave", Params: tt.params, Seed: 42}, character)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if last.Version != "v1" {
+				t.Fatalf("version %q, want v1", last.Version)
+			}
+			got := map[string]interface{}{}
+			for k, v := range last.Input {
+				if k != "prompt" && k != "seed" {
+					got[k] = v
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("input %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplicateModelOptions(t *testing.T) {
+	for _, model := range []string{"v1,image", "v1,reference_image_input=", "v1,steps=4"} {
+		if err := (ReplicateAdapter{}).Validate("key", "", model); err == nil {
+			t.Errorf("%q: want an error", model)
+		}
+	}
+	if err := (ReplicateAdapter{}).Validate("key", "", "v1, reference_images_input=input_images"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,168 @@
+package ai
+
+import (
+	"sort"
+	"strings"
+)
+
+// ThemeRequest carries the context a text provider needs to propose themes.
+type ThemeRequest struct {
+	Locale   string
+	Market   string
+	Keywords []string
+	Count    int
+}
+
+type ThemeSuggestion struct {
+	Theme     string  `json:"theme"`
+	Rationale string  `json:"rationale"`
+	Score     float64 `json:"score"`
+}
+
+type curatedTheme struct {
+	Theme     string
+	Rationale string
+	Tags      []string
+	Markets   []string
+}
+
+// curatedThemes is the offline list used when no provider credentials are
+// stored. Markets are LINE Creators Market regions where the theme sells well.
+var curatedThemes = []curatedTheme{
+	{Theme: "Office Life", Rationale: "Everyday work replies like OK, on my way and overtime are the most used sticker messages.", Tags: []string{"work", "office", "job", "business", "meeting"}, Markets: []string{"JP", "TW", "TH"}},
+	{Theme: "Commute", Rationale: "Running late, on the train and almost there cover daily chat with friends and coworkers.", Tags: []string{"train", "bus", "late", "travel", "daily"}, Markets: []string{"JP", "TW"}},
+	{Theme: "Weekend", Rationale: "Relaxing, lazy and party moods fit casual chats at the end of the week.", Tags: []string{"relax", "holiday", "party", "rest", "fun"}, Markets: []string{"JP", "TW", "TH", "ID"}},
+	{Theme: "Greetings & Thanks", Rationale: "Hello, thank you and good night are the basic set buyers expect in every pack.", Tags: []string{"greeting", "thanks", "hello", "polite", "basic"}, Markets: []string{"JP", "TW", "TH", "ID"}},
+	{Theme: "Food Cravings", Rationale: "Hungry, yummy and let's eat reactions are easy to read and popular in group chats.", Tags: []string{"food", "eat", "hungry", "snack", "cafe"}, Markets: []string{"TW", "TH", "JP"}},
+	{Theme: "Couple Talk", Rationale: "Affectionate replies such as miss you and love you sell well for paired characters.", Tags: []string{"love", "couple", "heart", "cute", "romance"}, Markets: []string{"TW", "TH", "JP"}},
+	{Theme: "Seasonal Events", Rationale: "New Year, festival and birthday greetings drive seasonal purchases.", Tags: []string{"season", "holiday", "birthday", "newyear", "festival"}, Markets: []string{"JP", "TW", "TH", "ID"}},
+	{Theme: "Polite Keigo Replies", Rationale: "Respectful Japanese phrases are useful for chats with seniors and clients.", Tags: []string{"polite", "keigo", "business", "respect"}, Markets: []string{"JP"}},
+	{Theme: "Emotional Reactions", Rationale: "Big feelings like crying, shocked and angry make short replies expressive.", Tags: []string{"emotion", "cry", "angry", "shock", "funny"}, Markets: []string{"JP", "TW", "TH", "ID"}},
+	{Theme: "Study & Exams", Rationale: "Students share studying, exam stress and done-with-homework moments every day.", Tags: []string{"school", "study", "exam", "student", "homework"}, Markets: []string{"TW", "TH", "ID"}},
+	{Theme: "Pet Daily Life", Rationale: "Animal characters doing pet-like actions appeal to a broad audience.", Tags: []string{"pet", "cat", "dog", "animal", "cute"}, Markets: []string{"JP", "TW", "TH"}},
+	{Theme: "Fitness & Diet", Rationale: "Workout, diet and motivation stickers fit health-focused chats.", Tags: []string{"gym", "sport", "diet", "health", "workout"}, Markets: []string{"TW", "JP"}},
+}
+
+// SuggestCuratedThemes ranks the curated list by keyword overlap with the
+// request and the character description, then by market fit.
+func SuggestCuratedThemes(req ThemeRequest, character CharacterInput) []ThemeSuggestion {
+	count := req.Count
+	if count <= 0 {
+		count = 5
+	}
+	words := themeWords(append(append([]string{}, req.Keywords...), character.Describe()))
+	market := strings.ToUpper(req.Market)
+	if market == "" {
+		market = marketForLocale(req.Locale)
+	}
+
+	out := make([]ThemeSuggestion, 0, len(curatedThemes))
+	for i, t := range curatedThemes {
+		score := 0.0
+		for _, tag := range t.Tags {
+			if words[tag] {
+				score += 1
+			}
+		}
+		for w := range themeWords([]string{t.Theme}) {
+			if words[w] {
+				score += 1
+			}
+		}
+		for _, m := range t.Markets {
+			if m == market {
+				score += 0.5
+			}
+		}
+		// keep curated order as a stable tie-breaker
+		score += float64(len(curatedThemes)-i) / 1000
+		out = append(out, ThemeSuggestion{Theme: t.Theme, Rationale: t.Rationale, Score: score})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	if len(out) > count {
+		out = out[:count]
+	}
+	return out
+}
+
+func themeWords(values []string) map[string]bool {
+	words := map[string]bool{}
+	for _, v := range values {
+		for _, w := range strings.FieldsFunc(strings.ToLower(v), func(r rune) bool {
+			return r == ' ' || r == ',' || r == '/' || r == '&' || r == '-' || r == '.' || r == '、' || r == '，'
+		}) {
+			words[w] = true
+		}
+	}
+	return words
+}
+
+func marketForLocale(locale string) string {
+	switch strings.ToLower(strings.SplitN(locale, "-", 2)[0]) {
+	case "ja":
+		return "JP"
+	case "zh":
+		return "TW"
+	case "th":
+		return "TH"
+	case "id":
+		return "ID"
+	case "ko":
+		return "KR"
+	}
+	return ""
+}
+
+// themePrompt is shared by the text adapters so every provider gets the same
+// instructions and answers in the same JSON shape.
+func themePrompt(req ThemeRequest, character CharacterInput) string {
+	count := req.Count
+	if count <= 0 {
+		count = 5
+	}
+	var b strings.Builder
+	b.WriteString("Suggest " + itoa(count) + " LINE sticker set themes ranked from best to worst.")
+	if desc := character.Describe(); desc != "" {
+		b.WriteString(" Character: " + desc + ".")
+	}
+	if req.Market != "" {
+		b.WriteString(" Target market: " + req.Market + ".")
+	}
+	if req.Locale != "" {
+		b.WriteString(" Write themes in locale " + req.Locale + ".")
+	}
+	if len(req.Keywords) > 0 {
+		b.WriteString(" Keywords: " + strings.Join(req.Keywords, ", ") + ".")
+	}
+	b.WriteString(` Return only a JSON array of objects with "theme" and "rationale" (one short sentence).`)
+	return b.String()
+}
+
+// rankThemes assigns descending scores in the order the provider returned.
+func rankThemes(list []ThemeSuggestion) []ThemeSuggestion {
+	out := make([]ThemeSuggestion, 0, len(list))
+	for _, t := range list {
+		if strings.TrimSpace(t.Theme) == "" {
+			continue
+		}
+		out = append(out, t)
+	}
+	for i := range out {
+		out[i].Score = float64(len(out)-i) / float64(len(out))
+	}
+	return out
+}
+
+// stripCodeFence removes a surrounding ```json fence that chat models often
+// add around JSON answers.
+func stripCodeFence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	s = strings.TrimPrefix(s, "```")
+	if i := strings.Index(s, "\n"); i >= 0 {
+		s = s[i+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "```"))
+}
@@ -0,0 +1,269 @@
+package api
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+
+	"golang.org/x/image/draw"
+)
+
+// APNG fcTL dispose and blend operations.
+const (
+	apngDisposeNone = 0
+	apngBlendSource = 0
+)
+
+// apngFrame is one frame's pixels: 4 bytes a pixel for RGBA, 1 for a
+// palette index. Rows start at the frame's origin.
+type apngFrame struct {
+	pix    []byte
+	stride int
+	bpp    int
+}
+
+func (f apngFrame) offset(x, y int) int {
+	return y*f.stride + x*f.bpp
+}
+
+// encodeAPNG writes frames as an animated PNG that plays loops times; 0
+// loops forever. delaysMs holds each frame's display time. Frames sharing
+// one palette are written indexed, anything else as 8-bit RGBA. After the
+// first frame only the rectangle that changed is stored, replacing the
+// previous pixels, which keeps mostly-still animations small.
+func encodeAPNG(frames []image.Image, delaysMs []int, loops int) ([]byte, error) {
+	if len(frames) == 0 || len(frames) != len(delaysMs) {
+		return nil, errors.New("apng needs one delay per frame")
+	}
+	bounds := frames[0].Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	pal := sharedPalette(frames)
+	imgs := make([]apngFrame, len(frames))
+	for i, f := range frames {
+		if f.Bounds().Dx() != w || f.Bounds().Dy() != h {
+			return nil, errors.New("apng frames must share one size")
+		}
+		if pal != nil {
+			p := f.(*image.Paletted)
+			imgs[i] = apngFrame{pix: p.Pix[p.PixOffset(p.Rect.Min.X, p.Rect.Min.Y):], stride: p.Stride, bpp: 1}
+			continue
+		}
+		img := image.NewNRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(img, img.Bounds(), f, f.Bounds().Min, draw.Src)
+		imgs[i] = apngFrame{pix: img.Pix, stride: img.Stride, bpp: 4}
+	}
+
+	buf := &bytes.Buffer{}
+	buf.Write(pngSignature)
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(w))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(h))
+	ihdr[8] = 8
+	ihdr[9] = pngRGBA
+	if pal != nil {
+		ihdr[9] = pngPalette
+	}
+	writePNGChunk(buf, "IHDR", ihdr)
+
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl[0:], uint32(len(imgs)))
+	binary.BigEndian.PutUint32(actl[4:], uint32(loops))
+	writePNGChunk(buf, "acTL", actl)
+	if pal != nil {
+		writePalette(buf, pal)
+	}
+
+	seq := uint32(0)
+	full := image.Rect(0, 0, w, h)
+	for i, img := range imgs {
+		region := full
+		if i > 0 {
+			region = changedRegion(imgs[i-1], img, full)
+		}
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:], seq)
+		binary.BigEndian.PutUint32(fctl[4:], uint32(region.Dx()))
+		binary.BigEndian.PutUint32(fctl[8:], uint32(region.Dy()))
+		binary.BigEndian.PutUint32(fctl[12:], uint32(region.Min.X))
+		binary.BigEndian.PutUint32(fctl[16:], uint32(region.Min.Y))
+		binary.BigEndian.PutUint16(fctl[20:], uint16(delaysMs[i]))
+		binary.BigEndian.PutUint16(fctl[22:], 1000)
+		fctl[24] = apngDisposeNone
+		fctl[25] = apngBlendSource
+		writePNGChunk(buf, "fcTL", fctl)
+		seq++
+
+		data, err := compressRegion(img, region)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			writePNGChunk(buf, "IDAT", data)
+			continue
+		}
+		fdat := make([]byte, 4, 4+len(data))
+		binary.BigEndian.PutUint32(fdat, seq)
+		writePNGChunk(buf, "fdAT", append(fdat, data...))
+		seq++
+	}
+	writePNGChunk(buf, "IEND", nil)
+	return buf.Bytes(), nil
+}
+
+// sharedPalette is the palette of frames that are all paletted with the
+// same colours, or nil.
+func sharedPalette(frames []image.Image) color.Palette {
+	var pal color.Palette
+	for i, f := range frames {
+		p, ok := f.(*image.Paletted)
+		if !ok || len(p.Palette) == 0 || len(p.Palette) > 256 {
+			return nil
+		}
+		if i == 0 {
+			pal = p.Palette
+			continue
+		}
+		if len(p.Palette) != len(pal) {
+			return nil
+		}
+		for j, c := range p.Palette {
+			if color.NRGBAModel.Convert(c) != color.NRGBAModel.Convert(pal[j]) {
+				return nil
+			}
+		}
+	}
+	return pal
+}
+
+// writePalette writes the PLTE chunk and, when any entry is not opaque,
+// the tRNS chunk up to the last such entry.
+func writePalette(buf *bytes.Buffer, pal color.Palette) {
+	plte := make([]byte, 0, 3*len(pal))
+	trns := make([]byte, 0, len(pal))
+	last := -1
+	for i, c := range pal {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		plte = append(plte, n.R, n.G, n.B)
+		trns = append(trns, n.A)
+		if n.A != 0xff {
+			last = i
+		}
+	}
+	writePNGChunk(buf, "PLTE", plte)
+	if last >= 0 {
+		writePNGChunk(buf, "tRNS", trns[:last+1])
+	}
+}
+
+// changedRegion is the bounding box within b of pixels that differ between
+// two frames, at least one pixel since fcTL cannot be empty.
+func changedRegion(prev, next apngFrame, b image.Rectangle) image.Rectangle {
+	minX, minY, maxX, maxY := b.Max.X, b.Max.Y, b.Min.X, b.Min.Y
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			i := next.offset(x, y)
+			if bytes.Equal(prev.pix[i:i+next.bpp], next.pix[i:i+next.bpp]) {
+				continue
+			}
+			if x < minX {
+				minX = x
+			}
+			if x >= maxX {
+				maxX = x + 1
+			}
+			if y < minY {
+				minY = y
+			}
+			if y >= maxY {
+				maxY = y + 1
+			}
+		}
+	}
+	if minX >= maxX {
+		return image.Rect(b.Min.X, b.Min.Y, b.Min.X+1, b.Min.Y+1)
+	}
+	return image.Rect(minX, minY, maxX, maxY)
+}
+
+// compressRegion filters and deflates one region as PNG image data. RGBA
+// rows get the filter with the smallest sum of absolute differences, as
+// image/png does; palette indices are left unfiltered, as the PNG spec
+// recommends.
+func compressRegion(img apngFrame, r image.Rectangle) ([]byte, error) {
+	bpp := img.bpp
+	stride := r.Dx() * bpp
+	filters := 5
+	if bpp == 1 {
+		filters = 1
+	}
+	prev := make([]byte, stride)
+	cur := make([]byte, stride)
+	filtered := make([][]byte, filters)
+	for i := range filtered {
+		filtered[i] = make([]byte, stride+1)
+		filtered[i][0] = byte(i)
+	}
+
+	out := &bytes.Buffer{}
+	zw, err := zlib.NewWriterLevel(out, zlib.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		start := img.offset(r.Min.X, y)
+		copy(cur, img.pix[start:start+stride])
+		best, bestSum := 0, -1
+		for f := 0; f < filters; f++ {
+			line := filtered[f][1:]
+			sum := 0
+			for i := 0; i < stride; i++ {
+				var a, b, c byte
+				if i >= bpp {
+					a, c = cur[i-bpp], prev[i-bpp]
+				}
+				b = prev[i]
+				var v byte
+				switch f {
+				case 0:
+					v = cur[i]
+				case 1:
+					v = cur[i] - a
+				case 2:
+					v = cur[i] - b
+				case 3:
+					v = cur[i] - byte((int(a)+int(b))/2)
+				case 4:
+					v = cur[i] - paeth(a, b, c)
+				}
+				line[i] = v
+				sum += absInt(int(int8(v)))
+			}
+			if bestSum < 0 || sum < bestSum {
+				best, bestSum = f, sum
+			}
+		}
+		if _, err := zw.Write(filtered[best]); err != nil {
+			return nil, err
+		}
+		prev, cur = cur, prev
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := absInt(p-int(a)), absInt(p-int(b)), absInt(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
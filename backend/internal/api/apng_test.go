@@ -0,0 +1,193 @@
+package api
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+type pngChunk struct {
+	kind string
+	body []byte
+}
+
+func readChunks(t *testing.T, data []byte) []pngChunk {
+	t.Helper()
+	if !bytes.HasPrefix(data, pngSignature) {
+		t.Fatal("missing png signature")
+	}
+	chunks := []pngChunk{}
+	for pos := len(pngSignature); pos < len(data); {
+		if pos+8 > len(data) {
+			t.Fatalf("truncated chunk header at %d", pos)
+		}
+		size := int(binary.BigEndian.Uint32(data[pos:]))
+		end := pos + 8 + size + 4
+		if end > len(data) {
+			t.Fatalf("truncated chunk at %d", pos)
+		}
+		chunks = append(chunks, pngChunk{kind: string(data[pos+4 : pos+8]), body: data[pos+8 : pos+8+size]})
+		pos = end
+	}
+	return chunks
+}
+
+// movingFrames draws a square that steps right on a transparent canvas, so
+// every frame after the first changes only part of the image.
+func movingFrames(n int) []image.Image {
+	frames := make([]image.Image, n)
+	for i := range frames {
+		img := image.NewNRGBA(image.Rect(0, 0, 64, 48))
+		for y := 10; y < 30; y++ {
+			for x := 4 + i*6; x < 24+i*6; x++ {
+				img.SetNRGBA(x, y, color.NRGBA{R: 0xe0, G: uint8(i * 40), B: 0x20, A: 0xff})
+			}
+		}
+		frames[i] = img
+	}
+	return frames
+}
+
+func TestEncodeAPNGChunks(t *testing.T) {
+	frames := movingFrames(5)
+	delays := []int{100, 150, 200, 250, 300}
+	data, err := encodeAPNG(frames, delays, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var frameCount, plays uint32
+	var seqs []uint32
+	var gotDelays []int
+	for _, c := range readChunks(t, data) {
+		switch c.kind {
+		case "acTL":
+			frameCount = binary.BigEndian.Uint32(c.body[0:])
+			plays = binary.BigEndian.Uint32(c.body[4:])
+		case "fcTL":
+			seqs = append(seqs, binary.BigEndian.Uint32(c.body[0:]))
+			num, den := binary.BigEndian.Uint16(c.body[20:]), binary.BigEndian.Uint16(c.body[22:])
+			gotDelays = append(gotDelays, int(num)*1000/int(den))
+		case "fdAT":
+			seqs = append(seqs, binary.BigEndian.Uint32(c.body[0:]))
+		}
+	}
+	if frameCount != 5 || plays != 3 {
+		t.Fatalf("acTL = %d frames, %d plays; want 5 and 3", frameCount, plays)
+	}
+	for i, seq := range seqs {
+		if seq != uint32(i) {
+			t.Fatalf("sequence numbers %v, want 0, 1, 2, ...", seqs)
+		}
+	}
+	if len(seqs) != 9 {
+		t.Fatalf("%d fcTL and fdAT chunks, want 9", len(seqs))
+	}
+	for i, d := range gotDelays {
+		if d != delays[i] {
+			t.Fatalf("delays %v, want %v", gotDelays, delays)
+		}
+	}
+
+	info, err := readPNGInfo(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Frames != 5 || info.Plays != 3 || info.DurationMs != 1000 {
+		t.Fatalf("info = %+v, want 5 frames, 3 plays, 1000 ms", info)
+	}
+
+	first, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("first frame does not decode: %v", err)
+	}
+	want := frames[0].(*image.NRGBA)
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			if got := color.NRGBAModel.Convert(first.At(x, y)); got != want.NRGBAAt(x, y) {
+				t.Fatalf("first frame pixel (%d,%d) = %v, want %v", x, y, got, want.NRGBAAt(x, y))
+			}
+		}
+	}
+}
+
+func TestEncodeAPNGPaletted(t *testing.T) {
+	pal := color.Palette{color.NRGBA{}, color.NRGBA{R: 0xff, A: 0xff}, color.NRGBA{B: 0xff, A: 0x80}}
+	frames := make([]image.Image, 5)
+	for i := range frames {
+		img := image.NewPaletted(image.Rect(0, 0, 32, 32), pal)
+		for x := 0; x < 32; x++ {
+			img.SetColorIndex(x, i*5, 1)
+			img.SetColorIndex(i*5, x, 2)
+		}
+		frames[i] = img
+	}
+	data, err := encodeAPNG(frames, []int{200, 200, 200, 200, 200}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := readPNGInfo(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ColorType != pngPalette || !info.Transparency {
+		t.Fatalf("info = %+v, want an indexed PNG with tRNS", info)
+	}
+	first, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("first frame does not decode: %v", err)
+	}
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			want := color.NRGBAModel.Convert(frames[0].At(x, y))
+			if got := color.NRGBAModel.Convert(first.At(x, y)); got != want {
+				t.Fatalf("first frame pixel (%d,%d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestAPNGDurationLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		delays []int
+		loops  int
+		ok     bool
+	}{
+		{"within limit", []int{200, 200, 200, 200, 200}, 3, true},
+		{"at limit", []int{200, 200, 200, 200, 200}, 4, true},
+		{"over limit", []int{300, 300, 300, 300, 300}, 3, false},
+		{"one loop over", []int{1000, 1000, 1000, 1000, 1000}, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateTiming(tt.delays, tt.loops, lineAnimation); (err == nil) != tt.ok {
+				t.Fatalf("validateTiming = %v, want ok %v", err, tt.ok)
+			}
+			data, err := encodeAPNG(movingFrames(len(tt.delays)), tt.delays, tt.loops)
+			if err != nil {
+				t.Fatal(err)
+			}
+			info, err := readPNGInfo(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var findings []string
+			validateAPNG(info, lineAnimation, func(severity, rule, message string) {
+				findings = append(findings, message)
+			})
+			if (len(findings) == 0) != tt.ok {
+				t.Fatalf("validateAPNG findings %v, want ok %v", findings, tt.ok)
+			}
+		})
+	}
+}
+
+func TestEncodeAPNGRejectsMismatchedDelays(t *testing.T) {
+	if _, err := encodeAPNG(movingFrames(3), []int{100, 100}, 1); err == nil {
+		t.Fatal("want an error when delays and frames differ in number")
+	}
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"example.com/app/internal/imageio"
+)
+
+const (
+	assetURLPrefix = "/api/v1/assets/"
+
+	// maxUploadBytes caps a single uploaded image; maxUploadRequestBytes caps
+	// a whole multipart request so batch uploads stay bounded.
+	maxUploadBytes        = 10 << 20
+	maxUploadRequestBytes = 100 << 20
+	maxUploadPixels       = 4096 * 4096
+)
+
+func assetDir() string {
+	return filepath.Join(os.TempDir(), "line-sticker-assets")
+}
+
+// saveAsset writes PNG data to the asset directory and returns its API URL.
+func saveAsset(data []byte) (string, error) {
+	if err := os.MkdirAll(assetDir(), 0o755); err != nil {
+		return "", err
+	}
+	name := newID("asset") + ".png"
+	if err := os.WriteFile(filepath.Join(assetDir(), name), data, 0o644); err != nil {
+		return "", err
+	}
+	return assetURLPrefix + name, nil
+}
+
+// removeAssets deletes the files of stored asset URLs, such as uploads that
+// were saved before the request failed.
+func removeAssets(urls []string) {
+	for _, url := range urls {
+		if path, ok := assetPath(url); ok {
+			_ = os.Remove(path)
+		}
+	}
+}
+
+// assetPath maps an asset URL back to its file, rejecting anything that is not
+// a plain file name inside the asset directory.
+func assetPath(url string) (string, bool) {
+	if !strings.HasPrefix(url, assetURLPrefix) {
+		return "", false
+	}
+	name := strings.TrimPrefix(url, assetURLPrefix)
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", false
+	}
+	return filepath.Join(assetDir(), name), true
+}
+
+func serveAsset(w http.ResponseWriter, r *http.Request, name string) {
+	path, ok := assetPath(assetURLPrefix + name)
+	if !ok {
+		writeStatus(w, http.StatusBadRequest)
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		writeStatus(w, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	http.ServeFile(w, r, path)
+}
+
+// checkLocalImageURL accepts an image reference only when it is a stored
+// asset or an image data URL, so request fields cannot make the server fetch
+// arbitrary URLs.
+func checkLocalImageURL(url string) error {
+	if path, ok := assetPath(url); ok {
+		if _, err := os.Stat(path); err != nil {
+			return errors.New("asset not found")
+		}
+		return nil
+	}
+	if _, ok, err := imageio.ParseDataURL(url); ok {
+		return err
+	}
+	return errors.New("image must be an uploaded asset or an image data URL")
+}
+
+// providerImageURL inlines local assets as data URLs, since remote providers
+// cannot reach this server's asset paths.
+func providerImageURL(url string) string {
+	path, ok := assetPath(url)
+	if !ok {
+		return url
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return url
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// decodeUpload validates an uploaded PNG, JPEG, GIF or WebP file and
+// re-encodes it as an RGBA PNG, upright per its EXIF orientation, so the
+// rest of the pipeline only deals with one format.
+func decodeUpload(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty file")
+	}
+	if len(data) > maxUploadBytes {
+		return nil, fmt.Errorf("file larger than %d MB", maxUploadBytes>>20)
+	}
+	cfg, err := imageio.DecodeConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxUploadPixels {
+		return nil, errors.New("image dimensions out of range")
+	}
+	img, err := imageio.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return encodePNG(img)
+}
+
+// readUploads parses a multipart request and returns the validated images of
+// the given field as stored asset URLs; max caps how many files are taken, 0
+// for any number. Files are only saved once all of them are valid. On failure
+// it writes the response and leaves no files behind.
+func readUploads(w http.ResponseWriter, r *http.Request, field string, max int) ([]string, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadRequestBytes)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid multipart form"})
+		return nil, false
+	}
+	files := r.MultipartForm.File[field]
+	if len(files) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing " + field})
+		return nil, false
+	}
+	if max > 0 && len(files) > max {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("at most %d %s allowed", max, field)})
+		return nil, false
+	}
+	images := make([][]byte, 0, len(files))
+	for _, fh := range files {
+		data, err := readUploadFile(fh, maxUploadBytes)
+		if err == nil {
+			data, err = decodeUpload(data)
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fh.Filename + ": " + err.Error()})
+			return nil, false
+		}
+		images = append(images, data)
+	}
+	urls := make([]string, 0, len(images))
+	for _, data := range images {
+		url, err := saveAsset(data)
+		if err != nil {
+			removeAssets(urls)
+			writeStatus(w, http.StatusInternalServerError)
+			return nil, false
+		}
+		urls = append(urls, url)
+	}
+	return urls, true
+}
+
+func readUploadFile(fh *multipart.FileHeader, limit int64) ([]byte, error) {
+	if fh.Size > limit {
+		return nil, fmt.Errorf("file larger than %d MB", limit>>20)
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, limit+1))
+}
+
+// writePNG sends encoded PNG bytes, uncached since previews change often.
+func writePNG(w http.ResponseWriter, data []byte) {
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,328 @@
+package api
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	captionMargin    = 10
+	minCaptionSize   = 12
+	maxCaptionSize   = 200
+	maxOutlineWidth  = 12
+	captionLineSpace = 1.2
+)
+
+var captionPositions = map[string]bool{"top": true, "bottom": true, "center": true, "left": true, "right": true}
+
+func defaultCaptionStyle() CaptionStyle {
+	return CaptionStyle{
+		FontID:       defaultCaptionFont,
+		Size:         44,
+		Color:        "#222222",
+		OutlineColor: "#FFFFFF",
+		OutlineWidth: 5,
+		Position:     "bottom",
+	}
+}
+
+// withCaptionDefaults fills unset fields; a zero OutlineWidth is kept since
+// it turns the outline off.
+func withCaptionDefaults(st CaptionStyle) CaptionStyle {
+	def := defaultCaptionStyle()
+	if st.FontID == "" {
+		st.FontID = def.FontID
+	}
+	if st.Size == 0 {
+		st.Size = def.Size
+	}
+	if st.Color == "" {
+		st.Color = def.Color
+	}
+	if st.OutlineColor == "" {
+		st.OutlineColor = def.OutlineColor
+	}
+	if st.Position == "" {
+		if st.Vertical {
+			st.Position = "right"
+		} else {
+			st.Position = def.Position
+		}
+	}
+	return st
+}
+
+func validateCaptionStyle(st CaptionStyle) error {
+	if st.Size < minCaptionSize || st.Size > maxCaptionSize {
+		return errors.New("size must be between 12 and 200")
+	}
+	if st.OutlineWidth < 0 || st.OutlineWidth > maxOutlineWidth {
+		return errors.New("outlineWidth must be between 0 and 12")
+	}
+	if !captionPositions[st.Position] {
+		return errors.New("position must be top, bottom, center, left or right")
+	}
+	if _, err := parseHexColor(st.Color); err != nil {
+		return errors.New("color: " + err.Error())
+	}
+	if _, err := parseHexColor(st.OutlineColor); err != nil {
+		return errors.New("outlineColor: " + err.Error())
+	}
+	return nil
+}
+
+// parseHexColor accepts #RGB, #RRGGBB and #RRGGBBAA.
+func parseHexColor(v string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(v, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return color.NRGBA{}, errors.New("expected #RRGGBB")
+	}
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, errors.New("expected #RRGGBB")
+	}
+	return color.NRGBA{R: uint8(n >> 24), G: uint8(n >> 16), B: uint8(n >> 8), A: uint8(n)}, nil
+}
+
+// verticalForms maps punctuation to its vertical presentation form for
+// tategaki captions.
+var verticalForms = map[rune]rune{
+	'、': '︑', '。': '︒', '，': '︐', '：': '︓', '；': '︔', '！': '︕', '？': '︖',
+	'「': '﹁', '」': '﹂', '『': '﹃', '』': '﹄', '（': '︵', '）': '︶',
+	'ー': '︱', '—': '︱', '…': '︙',
+}
+
+type captionFace struct {
+	font *opentype.Font
+	face font.Face
+}
+
+// captionFaces is a font fallback chain: each rune is drawn with the first
+// font that has a glyph for it.
+type captionFaces []captionFace
+
+func openCaptionFaces(fonts []*opentype.Font, size float64) (captionFaces, error) {
+	out := captionFaces{}
+	for _, f := range fonts {
+		face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			out.close()
+			return nil, err
+		}
+		out = append(out, captionFace{font: f, face: face})
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no fonts")
+	}
+	return out, nil
+}
+
+func (fs captionFaces) close() {
+	for _, f := range fs {
+		f.face.Close()
+	}
+}
+
+func (fs captionFaces) find(r rune) (font.Face, bool) {
+	var buf sfnt.Buffer
+	for _, f := range fs {
+		if idx, err := f.font.GlyphIndex(&buf, r); err == nil && idx != 0 {
+			return f.face, true
+		}
+	}
+	return fs[0].face, false
+}
+
+// missingGlyphs lists, once each, the characters of text that none of the
+// fonts has a glyph for and so would be drawn as empty boxes.
+func missingGlyphs(text string, fonts []*opentype.Font) []rune {
+	var buf sfnt.Buffer
+	seen := map[rune]bool{}
+	missing := []rune{}
+	for _, r := range text {
+		if unicode.IsSpace(r) || seen[r] {
+			continue
+		}
+		seen[r] = true
+		found := false
+		for _, f := range fonts {
+			if idx, err := f.GlyphIndex(&buf, r); err == nil && idx != 0 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
+
+type placedGlyph struct {
+	r    rune
+	face font.Face
+	dot  fixed.Point26_6
+}
+
+// layoutCaption places glyphs relative to the top-left of the text block and
+// returns the block size. Horizontal lines are centred; vertical columns run
+// right to left.
+func layoutCaption(lines [][]rune, fs captionFaces, size float64, vertical bool) ([]placedGlyph, int, int) {
+	ascent := fs[0].face.Metrics().Ascent
+	step := fixed.Int26_6(size * captionLineSpace * 64)
+	glyphs := []placedGlyph{}
+	if vertical {
+		cell := fixed.Int26_6(size * 1.05 * 64)
+		cols, rows := len(lines), 0
+		for i, line := range lines {
+			if len(line) > rows {
+				rows = len(line)
+			}
+			colX := fixed.Int26_6(cols-1-i) * step
+			for j, r := range line {
+				if vr, ok := verticalForms[r]; ok {
+					if _, ok := fs.find(vr); ok {
+						r = vr
+					}
+				}
+				face, _ := fs.find(r)
+				adv, _ := face.GlyphAdvance(r)
+				x := colX + (step-adv)/2
+				y := fixed.Int26_6(j)*cell + ascent
+				glyphs = append(glyphs, placedGlyph{r: r, face: face, dot: fixed.Point26_6{X: x, Y: y}})
+			}
+		}
+		return glyphs, (fixed.Int26_6(cols) * step).Ceil(), (fixed.Int26_6(rows) * cell).Ceil()
+	}
+	widths := make([]fixed.Int26_6, len(lines))
+	var maxW fixed.Int26_6
+	for i, line := range lines {
+		prev := rune(-1)
+		var prevFace font.Face
+		for _, r := range line {
+			face, _ := fs.find(r)
+			if prevFace == face && prev >= 0 {
+				widths[i] += face.Kern(prev, r)
+			}
+			adv, _ := face.GlyphAdvance(r)
+			widths[i] += adv
+			prev, prevFace = r, face
+		}
+		if widths[i] > maxW {
+			maxW = widths[i]
+		}
+	}
+	for i, line := range lines {
+		x := (maxW - widths[i]) / 2
+		y := fixed.Int26_6(i)*step + ascent
+		prev := rune(-1)
+		var prevFace font.Face
+		for _, r := range line {
+			face, _ := fs.find(r)
+			if prevFace == face && prev >= 0 {
+				x += face.Kern(prev, r)
+			}
+			glyphs = append(glyphs, placedGlyph{r: r, face: face, dot: fixed.Point26_6{X: x, Y: y}})
+			adv, _ := face.GlyphAdvance(r)
+			x += adv
+			prev, prevFace = r, face
+		}
+	}
+	return glyphs, maxW.Ceil(), (fixed.Int26_6(len(lines)) * step).Ceil()
+}
+
+func captionLines(text string) [][]rune {
+	lines := [][]rune{}
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, []rune(line))
+		}
+	}
+	return lines
+}
+
+// drawCaption composites text onto dst. The font size shrinks until the
+// block fits inside the canvas margins, so long captions never get clipped.
+func drawCaption(dst *image.RGBA, text string, style CaptionStyle, fonts []*opentype.Font) error {
+	lines := captionLines(text)
+	if len(lines) == 0 {
+		return nil
+	}
+	style = withCaptionDefaults(style)
+	fill, err := parseHexColor(style.Color)
+	if err != nil {
+		return err
+	}
+	stroke, err := parseHexColor(style.OutlineColor)
+	if err != nil {
+		return err
+	}
+	b := dst.Bounds()
+	pad := captionMargin + style.OutlineWidth
+	availW, availH := b.Dx()-2*pad, b.Dy()-2*pad
+
+	size := style.Size
+	var fs captionFaces
+	var glyphs []placedGlyph
+	var w, h int
+	for {
+		fs, err = openCaptionFaces(fonts, size)
+		if err != nil {
+			return err
+		}
+		glyphs, w, h = layoutCaption(lines, fs, size, style.Vertical)
+		if (w <= availW && h <= availH) || size <= minCaptionSize {
+			break
+		}
+		fs.close()
+		ratio := math.Min(float64(availW)/float64(w), float64(availH)/float64(h))
+		size = math.Max(minCaptionSize, math.Floor(size*math.Min(ratio, 0.95)))
+	}
+	defer fs.close()
+
+	x := b.Min.X + (b.Dx()-w)/2
+	y := b.Min.Y + (b.Dy()-h)/2
+	switch style.Position {
+	case "top":
+		y = b.Min.Y + pad
+	case "bottom":
+		y = b.Max.Y - pad - h
+	case "left":
+		x = b.Min.X + pad
+	case "right":
+		x = b.Max.X - pad - w
+	}
+	offset := fixed.P(x, y)
+
+	mask := image.NewAlpha(b)
+	for _, g := range glyphs {
+		dr, m, mp, _, ok := g.face.Glyph(g.dot.Add(offset), g.r)
+		if !ok {
+			continue
+		}
+		draw.DrawMask(mask, dr, image.Opaque, image.Point{}, m, mp, draw.Over)
+	}
+	if style.OutlineWidth > 0 {
+		outline := dilateAlpha(mask, float64(style.OutlineWidth))
+		draw.DrawMask(dst, b, image.NewUniform(stroke), image.Point{}, outline, b.Min, draw.Over)
+	}
+	draw.DrawMask(dst, b, image.NewUniform(fill), image.Point{}, mask, b.Min, draw.Over)
+	return nil
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Contact sheet layout, in pixels.
+const (
+	// contactSheetFile is the sheet's name in the LINE export.
+	contactSheetFile = "contact_sheet.png"
+
+	sheetColumns     = 5
+	sheetThumb       = 180
+	sheetPad         = 16
+	sheetLabelHeight = 40
+	sheetBadgeWidth  = 44
+	sheetBadgeHeight = 34
+	sheetChecker     = 8
+	// sheetMaxCaption is how many characters of a caption are shown.
+	sheetMaxCaption = 24
+)
+
+var (
+	sheetBackground = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	sheetCheckLight = color.NRGBA{R: 0xf2, G: 0xf2, B: 0xf2, A: 0xff}
+	sheetCheckDark  = color.NRGBA{R: 0xd6, G: 0xd6, B: 0xd6, A: 0xff}
+	sheetBorder     = color.NRGBA{R: 0xb0, G: 0xb0, B: 0xb0, A: 0xff}
+	sheetBadge      = color.NRGBA{R: 0x33, G: 0x33, B: 0x33, A: 0xd0}
+)
+
+// ContactSheet renders every sticker of a project, as exported, into one
+// numbered grid with captions for review.
+func (s *Store) ContactSheet(projectID string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.getProject(projectID); !ok {
+		return nil, errProjectNotFound
+	}
+	list, err := s.projectStickers(projectID)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, errors.New("no stickers")
+	}
+	s.applyCaptions(projectID, list)
+	return s.contactSheet(projectID, list)
+}
+
+// contactSheet draws stickers, already captioned, in export order. Each
+// cell shows the sticker over a checkerboard so transparency shows, its
+// number and its caption. Caller holds s.mu.
+func (s *Store) contactSheet(projectID string, stickers []Sticker) ([]byte, error) {
+	sorted := sortStickers(stickers)
+	canvas := s.projectSpec(projectID).Item
+	tw, th := scaleToSide(canvas.width, canvas.height, sheetThumb)
+	cols := min(sheetColumns, len(sorted))
+	rows := (len(sorted) + cols - 1) / cols
+	cellW, cellH := tw+sheetPad, th+sheetLabelHeight+sheetPad
+	dst := image.NewRGBA(image.Rect(0, 0, cols*cellW+sheetPad, rows*cellH+sheetPad))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(sheetBackground), image.Point{}, draw.Src)
+
+	captions := s.draftCaptions(projectID)
+	style := s.getCaptionStyle(projectID)
+	fonts := s.captionFonts(style)
+	label := CaptionStyle{FontID: style.FontID, Size: 18, Color: "#333333", OutlineWidth: 0, Position: "center"}
+	badge := CaptionStyle{FontID: style.FontID, Size: 16, Color: "#FFFFFF", OutlineWidth: 0, Position: "center"}
+
+	for i, st := range sorted {
+		x, y := sheetPad+(i%cols)*cellW, sheetPad+(i/cols)*cellH
+		thumb := image.Rect(x, y, x+tw, y+th)
+		drawCheckerboard(dst, thumb)
+		if src, err := loadImage(stickerURL(st)); err == nil {
+			if fitted, err := fitImage(src, tw, th); err == nil {
+				draw.Draw(dst, thumb, fitted, image.Point{}, draw.Over)
+			}
+		}
+		drawFrame(dst, thumb, sheetBorder)
+
+		num := image.Rect(x, y, x+sheetBadgeWidth, y+sheetBadgeHeight)
+		draw.Draw(dst, num, image.NewUniform(sheetBadge), image.Point{}, draw.Over)
+		if err := drawCaption(dst.SubImage(num).(*image.RGBA), fmt.Sprint(i+1), badge, fonts); err != nil {
+			return nil, err
+		}
+		text := sheetCaption(captions[st.DraftID])
+		if text == "" {
+			continue
+		}
+		area := image.Rect(x, y+th, x+tw, y+th+sheetLabelHeight)
+		if err := drawCaption(dst.SubImage(area).(*image.RGBA), text, label, fonts); err != nil {
+			return nil, err
+		}
+	}
+	return compressPNG(dst)
+}
+
+// sheetCaption fits a caption on one short line.
+func sheetCaption(caption string) string {
+	text := []rune(strings.Join(strings.Fields(caption), " "))
+	if len(text) > sheetMaxCaption {
+		return string(text[:sheetMaxCaption-1]) + "…"
+	}
+	return string(text)
+}
+
+func drawCheckerboard(dst *image.RGBA, r image.Rectangle) {
+	light, dark := image.NewUniform(sheetCheckLight), image.NewUniform(sheetCheckDark)
+	for y := r.Min.Y; y < r.Max.Y; y += sheetChecker {
+		for x := r.Min.X; x < r.Max.X; x += sheetChecker {
+			square := image.Rect(x, y, x+sheetChecker, y+sheetChecker).Intersect(r)
+			c := light
+			if ((x-r.Min.X)/sheetChecker+(y-r.Min.Y)/sheetChecker)%2 == 1 {
+				c = dark
+			}
+			draw.Draw(dst, square, c, image.Point{}, draw.Src)
+		}
+	}
+}
+
+// drawFrame outlines r with a one-pixel border just outside it.
+func drawFrame(dst *image.RGBA, r image.Rectangle, c color.Color) {
+	edge := image.NewUniform(c)
+	r = r.Inset(-1)
+	for _, line := range []image.Rectangle{
+		image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+1),
+		image.Rect(r.Min.X, r.Max.Y-1, r.Max.X, r.Max.Y),
+		image.Rect(r.Min.X, r.Min.Y, r.Min.X+1, r.Max.Y),
+		image.Rect(r.Max.X-1, r.Min.Y, r.Max.X, r.Max.Y),
+	} {
+		draw.Draw(dst, line, edge, image.Point{}, draw.Src)
+	}
+}
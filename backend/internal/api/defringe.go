@@ -0,0 +1,154 @@
+package api
+
+import (
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// defringe cleans a cut-out in place: it can drop small or faint specks and
+// harden the alpha ramp, and replaces the colour of semi-transparent edge
+// pixels with the colour of nearby solid pixels so no background halo is
+// left.
+func defringe(img *image.NRGBA, cfg DefringeConfig) {
+	if cfg.SpeckSize > 0 || cfg.SpeckAlpha > 0 {
+		removeSpecks(img, cfg.SpeckSize, uint8(cfg.SpeckAlpha))
+	}
+	if cfg.Harden {
+		hardenAlpha(img, uint8(cfg.HardenLow), uint8(cfg.HardenHigh))
+	}
+	if cfg.Radius > 0 {
+		decontaminateEdges(img, cfg.Radius)
+	}
+}
+
+func toNRGBA(src image.Image) *image.NRGBA {
+	b := src.Bounds()
+	img := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(img, img.Bounds(), src, b.Min, draw.Src)
+	return img
+}
+
+// removeSpecks clears 8-connected islands of visible pixels that are smaller
+// than minArea or never reach minAlpha.
+func removeSpecks(img *image.NRGBA, minArea int, minAlpha uint8) {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	seen := make([]bool, w*h)
+	stack := []int{}
+	component := []int{}
+	for start := 0; start < w*h; start++ {
+		if seen[start] || img.Pix[start*4+3] == 0 {
+			continue
+		}
+		seen[start] = true
+		stack = append(stack[:0], start)
+		component = component[:0]
+		var peak uint8
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			component = append(component, i)
+			if a := img.Pix[i*4+3]; a > peak {
+				peak = a
+			}
+			x, y := i%w, i/w
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx < 0 || ny < 0 || nx >= w || ny >= h {
+						continue
+					}
+					n := ny*w + nx
+					if !seen[n] && img.Pix[n*4+3] != 0 {
+						seen[n] = true
+						stack = append(stack, n)
+					}
+				}
+			}
+		}
+		if len(component) < minArea || peak < minAlpha {
+			for _, i := range component {
+				img.Pix[i*4+3] = 0
+			}
+		}
+	}
+}
+
+// hardenAlpha maps alpha below low to 0 and above high to 255, stretching
+// the values in between.
+func hardenAlpha(img *image.NRGBA, low, high uint8) {
+	if high <= low {
+		return
+	}
+	span := float64(high - low)
+	for i := 3; i < len(img.Pix); i += 4 {
+		a := img.Pix[i]
+		switch {
+		case a <= low:
+			img.Pix[i] = 0
+		case a >= high:
+			img.Pix[i] = 255
+		default:
+			img.Pix[i] = uint8(float64(a-low) / span * 255)
+		}
+	}
+}
+
+// decontaminateEdges recolours semi-transparent pixels within radius of a
+// solid pixel from the solid pixels further in; solid pixels are never
+// changed, so thin outlines keep their colour. Colour spreads outwards one
+// ring at a time so each edge pixel takes the average of its already-clean
+// neighbours. Semi-transparent parts with no solid pixels nearby keep their
+// colour.
+func decontaminateEdges(img *image.NRGBA, radius int) {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	clean := make([]bool, w*h)
+	frontier := []int{}
+	for i := 0; i < w*h; i++ {
+		if img.Pix[i*4+3] == 255 {
+			clean[i] = true
+			frontier = append(frontier, i)
+		}
+	}
+	neighbours := func(i int, visit func(j int)) {
+		x, y := i%w, i/w
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				nx, ny := x+dx, y+dy
+				if (dx != 0 || dy != 0) && nx >= 0 && ny >= 0 && nx < w && ny < h {
+					visit(ny*w + nx)
+				}
+			}
+		}
+	}
+	queued := make([]bool, w*h)
+	for step := 0; step < radius && len(frontier) > 0; step++ {
+		ring := []int{}
+		for _, i := range frontier {
+			neighbours(i, func(j int) {
+				if !clean[j] && !queued[j] && img.Pix[j*4+3] != 0 {
+					queued[j] = true
+					ring = append(ring, j)
+				}
+			})
+		}
+		colours := make([][3]uint8, len(ring))
+		for k, i := range ring {
+			var r, g, b, n int
+			neighbours(i, func(j int) {
+				if clean[j] {
+					r += int(img.Pix[j*4])
+					g += int(img.Pix[j*4+1])
+					b += int(img.Pix[j*4+2])
+					n++
+				}
+			})
+			colours[k] = [3]uint8{uint8(r / n), uint8(g / n), uint8(b / n)}
+		}
+		for k, i := range ring {
+			copy(img.Pix[i*4:i*4+3], colours[k][:])
+			clean[i] = true
+		}
+		frontier = ring
+	}
+}
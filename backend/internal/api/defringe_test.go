@@ -0,0 +1,45 @@
+package api
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDefaultDefringeKeepsArtwork(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	// a solid red body with a one-pixel black outline and a soft green halo
+	for y := 10; y < 30; y++ {
+		for x := 10; x < 30; x++ {
+			c := color.NRGBA{R: 0xff, A: 0xff}
+			if x == 10 || x == 29 || y == 10 || y == 29 {
+				c = color.NRGBA{A: 0xff}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+		img.SetNRGBA(9, y, color.NRGBA{G: 0xff, A: 0x80})
+	}
+	// a detached three-pixel sparkle
+	for x := 2; x < 5; x++ {
+		img.SetNRGBA(x, 2, color.NRGBA{R: 0xff, G: 0xff, A: 0xff})
+	}
+	src := image.NewNRGBA(img.Rect)
+	copy(src.Pix, img.Pix)
+
+	defringe(img, defaultPostProcess().Defringe)
+
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			want, got := src.NRGBAAt(x, y), img.NRGBAAt(x, y)
+			if want.A == 255 && got != want {
+				t.Fatalf("solid pixel (%d,%d) changed from %v to %v", x, y, want, got)
+			}
+			if got.A != want.A {
+				t.Fatalf("pixel (%d,%d) alpha changed from %d to %d", x, y, want.A, got.A)
+			}
+		}
+	}
+	if halo := img.NRGBAAt(9, 20); halo.G != 0 || halo.A != 0x80 {
+		t.Fatalf("halo pixel %v, want recoloured from the outline", halo)
+	}
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	exportTargetDiscord = "discord"
+	exportTargetSlack   = "slack"
+)
+
+// Discord sticker and Discord/Slack custom emoji limits.
+const (
+	discordStickerSide     = 320
+	discordStickerMaxBytes = 512 << 10
+	customEmojiSide        = 128
+	customEmojiMaxBytes    = 256 << 10
+	// discordMaxStickers is the sticker slots of a fully boosted server.
+	discordMaxStickers = 60
+	// custom names fit both Discord stickers (2-30) and emoji (2-32).
+	customNameMin = 2
+	customNameMax = 30
+
+	// namesFile maps each exported file to its name and caption.
+	namesFile = "names.json"
+)
+
+var (
+	discordStickerRule = targetRule{format: formatPNG, width: discordStickerSide, height: discordStickerSide, maxBytes: discordStickerMaxBytes}
+	customEmojiRule    = targetRule{format: formatPNG, width: customEmojiSide, height: customEmojiSide, maxBytes: customEmojiMaxBytes}
+)
+
+// customEntry is one sticker in names.json.
+type customEntry struct {
+	Name      string `json:"name"`
+	Caption   string `json:"caption"`
+	StickerID string `json:"stickerId"`
+	Sticker   string `json:"sticker,omitempty"`
+	// Tags is the related emoji Discord asks for when uploading a sticker.
+	Tags  string `json:"tags,omitempty"`
+	Emoji string `json:"emoji"`
+}
+
+// customName turns a caption or requested name into a sticker and emoji
+// name not yet in used. Text without enough ASCII letters or digits, such as
+// a Japanese or Chinese caption, falls back to sticker_NN; ok reports
+// whether the text was usable.
+func customName(caption string, n int, used map[string]bool) (name string, ok bool) {
+	name = slugName(caption)
+	if len(name) > customNameMax {
+		name = strings.TrimRight(name[:customNameMax], "_")
+	}
+	ok = len(name) >= customNameMin
+	if !ok {
+		name = fmt.Sprintf("sticker_%02d", n)
+	}
+	base := name
+	for i := 2; used[name]; i++ {
+		suffix := fmt.Sprintf("_%d", i)
+		name = strings.TrimRight(base[:min(len(base), customNameMax-len(suffix))], "_") + suffix
+	}
+	used[name] = true
+	return name, ok
+}
+
+// buildCustomZip renders the set as Discord stickers and custom emoji, or
+// as emoji alone for Slack. Files are named after the stickers' captions,
+// which is the name both apps take on upload; names.json maps them back.
+// captions and emoji map draft IDs to their caption and emoji, names maps
+// sticker IDs to names chosen instead of the caption; notes are findings
+// already made on the set.
+func buildCustomZip(projectID, target string, spec productSpec, stickers []Sticker, captions map[string]string, emoji map[string][]string, names map[string]string, notes []ValidationFinding) ([]byte, *ValidationReport, error) {
+	if len(stickers) == 0 {
+		return nil, nil, errors.New("no stickers")
+	}
+	report := newValidationReport(projectID, target)
+	report.note(notes)
+	sorted := sortStickers(stickers)
+	withStickers := target == exportTargetDiscord
+	if withStickers && len(sorted) > discordMaxStickers {
+		report.add(severityWarning, "sticker-count", fmt.Sprintf("set has %d stickers; a Discord server holds at most %d", len(sorted), discordMaxStickers))
+	}
+
+	files := []exportFile{}
+	entries := []customEntry{}
+	used := map[string]bool{}
+	for i, s := range sorted {
+		caption := captions[s.DraftID]
+		source, from := caption, "caption"
+		if requested := names[s.ID]; requested != "" {
+			source, from = requested, "name"
+		}
+		name, ok := customName(source, i+1, used)
+		entry := customEntry{Name: name, Caption: caption, StickerID: s.ID, Emoji: "emoji/" + name + ".png"}
+		var findings []ValidationFinding
+		if !ok {
+			message := fmt.Sprintf("%s %q has no usable letters or digits; named %s", from, source, name)
+			findings = append(findings, ValidationFinding{Rule: "name", Severity: severityWarning, Message: message})
+		}
+
+		if withStickers {
+			entry.Sticker = "stickers/" + name + ".png"
+			entry.Tags = defaultStickerEmoji
+			if list := emoji[s.DraftID]; len(list) > 0 {
+				entry.Tags = list[0]
+			}
+			rule := fileRule{width: discordStickerSide, height: discordStickerSide, maxBytes: discordStickerMaxBytes}
+			if s.Animation != nil {
+				rule.animation = spec.Item.animation
+			}
+			data, opt, err := exportItem(s, rule)
+			if err != nil {
+				report.addFile(unreadableFile(entry.Sticker, s.ID, err))
+				continue
+			}
+			fv := validateTargetFile(entry.Sticker, s.ID, data, discordStickerRule)
+			noteOptimization(&fv, opt)
+			fv.Findings = append(fv.Findings, findings...)
+			findings = nil
+			report.addFile(fv)
+			files = append(files, exportFile{name: entry.Sticker, stickerID: s.ID, data: data})
+		}
+
+		data, opt, err := exportItem(s, fileRule{width: customEmojiSide, height: customEmojiSide, maxBytes: customEmojiMaxBytes})
+		if err != nil {
+			report.addFile(unreadableFile(entry.Emoji, s.ID, err))
+			continue
+		}
+		fv := validateTargetFile(entry.Emoji, s.ID, data, customEmojiRule)
+		noteOptimization(&fv, opt)
+		fv.Findings = append(fv.Findings, findings...)
+		report.addFile(fv)
+		files = append(files, exportFile{name: entry.Emoji, stickerID: s.ID, data: data})
+		entries = append(entries, entry)
+	}
+
+	files = append(files,
+		exportFile{name: "README.txt", data: []byte(customReadme(withStickers))},
+		jsonFile(namesFile, entries),
+		jsonFile("report.json", report),
+	)
+	data, err := zipFiles(files)
+	if err != nil {
+		return nil, nil, err
+	}
+	report.ZipBytes = len(data)
+	return data, report, nil
+}
+
+func customReadme(withStickers bool) string {
+	b := &strings.Builder{}
+	if withStickers {
+		b.WriteString("Discord Sticker and Emoji Export\n")
+		fmt.Fprintf(b, "- stickers/: %dx%d PNG (APNG when animated), up to %s each\n", discordStickerSide, discordStickerSide, formatBytes(discordStickerMaxBytes))
+	} else {
+		b.WriteString("Slack Emoji Export\n")
+	}
+	fmt.Fprintf(b, "- emoji/: %dx%d PNG custom emoji, up to %s each\n", customEmojiSide, customEmojiSide, formatBytes(customEmojiMaxBytes))
+	fmt.Fprintf(b, "- %s: each name with its caption and files\n", namesFile)
+	fmt.Fprintf(b, "\nNames come from the captions: lower-case letters, digits and underscores, %d-%d characters.\n", customNameMin, customNameMax)
+	b.WriteString("Captions without enough of those, such as Japanese or Chinese ones, are named sticker_NN; rename them on upload or set names in the export request.\n")
+	if withStickers {
+		b.WriteString("Upload them under Server Settings > Stickers and Emoji; each sticker's tags entry is its related emoji.\n")
+	} else {
+		b.WriteString("Upload them under Customize Workspace > Emoji; the file name is the emoji name.\n")
+	}
+	return b.String()
+}
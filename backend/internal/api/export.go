@@ -3,153 +3,282 @@ package api
 import (
 	"archive/zip"
 	"bytes"
-	"encoding/base64"
+	"encoding/json"
 	"errors"
-	"fmt"
 	"image"
+	"image/color"
 	"image/png"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
-	"time"
+	"strings"
+
+	"example.com/app/internal/imageio"
 )
 
-func buildExportZip(projectID string, stickers []Sticker) (string, []string, error) {
+type exportFile struct {
+	name      string
+	stickerID string
+	data      []byte
+}
+
+// textAreaEntry is one item's text area in text_areas.json.
+type textAreaEntry struct {
+	File string `json:"file"`
+	TextArea
+}
+
+func exportDir() string {
+	return filepath.Join(os.TempDir(), "line-sticker-exports")
+}
+
+// buildExportZip renders the set into an in-memory ZIP laid out for the
+// product type and validates it against the LINE rules. sheet is the
+// contact sheet added for review; notes are findings already made on the
+// set, such as on its captions. The ZIP is returned even when the report
+// has errors; the caller decides whether to publish it.
+func buildExportZip(projectID string, spec productSpec, stickers []Sticker, sheet []byte, notes []ValidationFinding) ([]byte, *ValidationReport, error) {
 	if projectID == "" {
-		return "", nil, errors.New("missing project id")
+		return nil, nil, errors.New("missing project id")
 	}
 	if len(stickers) == 0 {
-		return "", nil, errors.New("no stickers")
-	}
-
-	baseDir := filepath.Join(os.TempDir(), "line-sticker-exports")
-	if err := os.MkdirAll(baseDir, 0o755); err != nil {
-		return "", nil, err
+		return nil, nil, errors.New("no stickers")
 	}
+	report := newValidationReport(projectID, exportTargetLine)
+	report.note(notes)
+	sorted := sortStickers(stickers)
+	validateStickerCount(report, len(sorted), spec)
 
-	zipPath := filepath.Join(baseDir, fmt.Sprintf("%s.zip", projectID))
-	f, err := os.Create(zipPath)
-	if err != nil {
-		return "", nil, err
-	}
-	defer f.Close()
-
-	zw := zip.NewWriter(f)
-	sorted := append([]Sticker(nil), stickers...)
-	sort.Slice(sorted, func(i, j int) bool {
-		return sorted[i].CreatedAt < sorted[j].CreatedAt
-	})
-
-	warnings := []string{}
-	written := 0
+	files := []exportFile{}
+	areas := []textAreaEntry{}
 	for i, s := range sorted {
-		url := s.TransparentURL
-		if url == "" {
-			url = s.ImageURL
-		}
-		data, err := fetchPNG(url)
+		name := spec.fileName(i + 1)
+		data, opt, err := exportItem(s, spec.Item)
 		if err != nil {
-			warnings = append(warnings, fmt.Sprintf("%s fetch failed", s.ID))
+			report.addFile(unreadableFile(name, s.ID, err))
 			continue
 		}
-		if ok := validatePNGSize(data, stickerWidth, stickerHeight); !ok {
-			warnings = append(warnings, fmt.Sprintf("%s invalid size", s.ID))
-			continue
+		fv := validateFile(name, s.ID, data, spec.Item)
+		noteOptimization(&fv, opt)
+		if area := textAreaFor(&s, spec); area != nil {
+			validateTextAreaClear(&fv, data, *area)
+			areas = append(areas, textAreaEntry{File: name, TextArea: *area})
 		}
-		if ok := validatePNGAlpha(data); !ok {
-			warnings = append(warnings, fmt.Sprintf("%s missing alpha", s.ID))
+		report.addFile(fv)
+		files = append(files, exportFile{name: name, stickerID: s.ID, data: data})
+		if spec.PreviewFormat == "" {
 			continue
 		}
-		name := fmt.Sprintf("%02d.png", i+1)
-		w, err := zw.Create(name)
+		preview := spec.previewName(i + 1)
+		data, opt, err = exportItem(s, spec.Preview)
 		if err != nil {
+			report.addFile(unreadableFile(preview, s.ID, err))
 			continue
 		}
-		_, _ = w.Write(data)
-		written++
-	}
-
-	// main + tab images (LINE requirement)
-	baseURL := stickers[0].TransparentURL
-	if baseURL == "" {
-		baseURL = stickers[0].ImageURL
-	}
-	if baseURL != "" {
-		if mainData, err := normalizeImageToSize(baseURL, 240, 240); err == nil {
-			if data, err := fetchPNG(mainData); err == nil {
-				if validatePNGSize(data, 240, 240) {
-					if w, err := zw.Create("main.png"); err == nil {
-						_, _ = w.Write(data)
-					}
-				}
-			}
+		fv = validateFile(preview, s.ID, data, spec.Preview)
+		noteOptimization(&fv, opt)
+		report.addFile(fv)
+		files = append(files, exportFile{name: preview, stickerID: s.ID, data: data})
+	}
+
+	// store images such as main and tab are made from the first item
+	for _, extra := range spec.Extras {
+		data, opt, err := exportItem(sorted[0], extra.Rule)
+		if err != nil {
+			report.addFile(unreadableFile(extra.Name, "", err))
+			continue
 		}
-		if tabData, err := normalizeImageToSize(baseURL, 96, 74); err == nil {
-			if data, err := fetchPNG(tabData); err == nil {
-				if validatePNGSize(data, 96, 74) {
-					if w, err := zw.Create("tab.png"); err == nil {
-						_, _ = w.Write(data)
-					}
-				}
-			}
+		fv := validateFile(extra.Name, "", data, extra.Rule)
+		noteOptimization(&fv, opt)
+		report.addFile(fv)
+		files = append(files, exportFile{name: extra.Name, data: data})
+	}
+
+	if spec.TextArea != nil {
+		files = append(files, jsonFile(textAreasFile, map[string]interface{}{
+			"canvas": map[string]int{"width": spec.Item.width, "height": spec.Item.height},
+			"areas":  areas,
+		}))
+	}
+
+	files = append(files,
+		exportFile{name: contactSheetFile, data: sheet},
+		exportFile{name: "README.txt", data: []byte(spec.readme())},
+	)
+	meta := map[string]interface{}{
+		"projectId":    projectID,
+		"type":         spec.ID,
+		"stickers":     len(sorted),
+		"contactSheet": contactSheetFile,
+	}
+	if spec.PreviewFormat != "" {
+		previews := make([]string, len(sorted))
+		for i := range sorted {
+			previews[i] = spec.previewName(i + 1)
 		}
+		meta["previews"] = previews
 	}
-	// add simple spec/readme
-	if w, err := zw.Create("README.txt"); err == nil {
-		_, _ = w.Write([]byte("LINE Stickers Export\n- Sticker: 370x320 PNG (transparent)\n- main.png: 240x240 PNG\n- tab.png: 96x74 PNG\n"))
+	if spec.TextArea != nil {
+		meta["textAreas"] = textAreasFile
 	}
-	if w, err := zw.Create("metadata.json"); err == nil {
-		meta := fmt.Sprintf("{\n  \"projectId\": \"%s\",\n  \"stickers\": %d,\n  \"main\": \"main.png\",\n  \"tab\": \"tab.png\"\n}\n", projectID, written)
-		_, _ = w.Write([]byte(meta))
+	if a := spec.Item.animation; a != nil {
+		meta["animation"] = map[string]int{
+			"minFrames":     a.minFrames,
+			"maxFrames":     a.maxFrames,
+			"maxLoops":      a.maxLoops,
+			"maxPlaybackMs": a.maxPlaybackMs,
+		}
 	}
-	if w, err := zw.Create("report.json"); err == nil {
-		report := fmt.Sprintf("{\n  \"warnings\": %q\n}\n", warnings)
-		_, _ = w.Write([]byte(report))
+	for _, extra := range spec.Extras {
+		meta[strings.TrimSuffix(extra.Name, ".png")] = extra.Name
+	}
+	files = append(files, jsonFile("metadata.json", meta), jsonFile("report.json", report))
+
+	data, err := zipFiles(files)
+	if err != nil {
+		return nil, nil, err
 	}
+	validateZipSize(report, len(data))
+	return data, report, nil
+}
+
+// sortStickers orders a set the way it is numbered in exports.
+func sortStickers(stickers []Sticker) []Sticker {
+	sorted := append([]Sticker(nil), stickers...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt < sorted[j].CreatedAt
+	})
+	return sorted
+}
+
+// jsonFile is an indented JSON file of the export. The values are plain
+// maps and structs, so marshalling cannot fail.
+func jsonFile(name string, v interface{}) exportFile {
+	data, _ := json.MarshalIndent(v, "", "  ")
+	return exportFile{name: name, data: append(data, '\n')}
+}
 
+func zipFiles(files []exportFile) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(f.data); err != nil {
+			return nil, err
+		}
+	}
 	if err := zw.Close(); err != nil {
-		return "", nil, err
+		return nil, err
 	}
-	return zipPath, warnings, nil
+	return buf.Bytes(), nil
 }
 
-func fetchPNG(url string) ([]byte, error) {
-	if url == "" {
-		return nil, errors.New("empty url")
+// exportName is the published ZIP of a target; LINE keeps the plain
+// project name.
+func exportName(projectID, target string) string {
+	if target == exportTargetLine {
+		return projectID + ".zip"
 	}
-	if len(url) > 30 && url[:22] == "data:image/png;base64," {
-		// data url
-		b64 := url[len("data:image/png;base64,"):]
-		return decodeBase64(b64)
+	return projectID + "-" + target + ".zip"
+}
+
+// writeExportZip publishes a built ZIP where serveExport finds it.
+func writeExportZip(name string, data []byte) error {
+	if err := os.MkdirAll(exportDir(), 0o755); err != nil {
+		return err
 	}
-	client := &http.Client{Timeout: 20 * time.Second}
-	resp, err := client.Get(url)
+	return os.WriteFile(filepath.Join(exportDir(), name), data, 0o644)
+}
+
+func stickerURL(s Sticker) string {
+	if s.TransparentURL != "" {
+		return s.TransparentURL
+	}
+	return s.ImageURL
+}
+
+// exportItem renders a sticker for one file of the export: its frames for
+// animated rules, otherwise its image fitted to the rule's size. Either is
+// optimized toward the rule's size limit.
+func exportItem(s Sticker, rule fileRule) ([]byte, *PNGOptimization, error) {
+	if rule.animation != nil {
+		return exportAPNG(s.Animation, rule.width, rule.height, rule.maxBytes)
+	}
+	fitted, err := normalizeImageToSize(stickerURL(s), rule.width, rule.height)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 400 {
-		return nil, errors.New("fetch failed")
+	return exportPNG(fitted, rule.maxBytes)
+}
+
+// exportAPNG encodes a sticker's frames at w x h for the export. Over
+// target bytes, the frames are quantized to one shared palette.
+func exportAPNG(anim *StickerAnimation, w, h, target int) ([]byte, *PNGOptimization, error) {
+	if anim == nil || len(anim.Frames) == 0 {
+		return nil, nil, errors.New("no animation frames; generate frames first")
 	}
-	limited := io.LimitReader(resp.Body, 10<<20)
-	img, _, err := image.Decode(limited)
+	frames, delays, err := loadAnimationFrames(*anim, w, h)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	buf := &bytes.Buffer{}
-	if err := png.Encode(buf, img); err != nil {
-		return nil, err
+	data, err := encodeAPNG(frames, delays, anim.Loops)
+	if err != nil {
+		return nil, nil, err
 	}
-	return buf.Bytes(), nil
+	opt := &PNGOptimization{OriginalBytes: len(data), TargetBytes: target}
+	data, err = reduceColors(data, opt, func(colors int) ([]byte, error) {
+		imgs := make([]*image.NRGBA, len(frames))
+		for i, f := range frames {
+			imgs[i] = toNRGBA(f)
+		}
+		pal, transparent := buildPalette(imgs, colors)
+		quantized := make([]image.Image, len(imgs))
+		for i, img := range imgs {
+			quantized[i] = quantize(img, pal, transparent)
+		}
+		return encodeAPNG(quantized, delays, anim.Loops)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err = setPNGDPI(data, lineDPI)
+	return data, opt, err
+}
+
+// exportPNG is fetchPNG optimized toward target bytes and tagged with
+// LINE's 72 dpi resolution.
+func exportPNG(url string, target int) ([]byte, *PNGOptimization, error) {
+	data, err := fetchPNG(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, opt, err := optimizePNG(data, target)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err = setPNGDPI(data, lineDPI)
+	return data, opt, err
 }
 
-func decodeBase64(v string) ([]byte, error) {
-	buf := make([]byte, len(v))
-	_, err := base64.StdEncoding.Decode(buf, []byte(v))
+// fetchPNG returns the image as an RGBA PNG, passing through PNGs that
+// already are one.
+func fetchPNG(url string) ([]byte, error) {
+	data, err := loadImageBytes(url)
+	if err != nil {
+		return nil, err
+	}
+	if http.DetectContentType(data) == "image/png" {
+		if cfg, err := png.DecodeConfig(bytes.NewReader(data)); err == nil && (cfg.ColorModel == color.NRGBAModel || cfg.ColorModel == color.RGBAModel) {
+			return data, nil
+		}
+	}
+	img, err := imageio.Decode(data)
 	if err != nil {
 		return nil, err
 	}
-	return buf, nil
+	return encodePNG(img)
 }
@@ -12,8 +12,7 @@ func serveExport(w http.ResponseWriter, r *http.Request, name string) {
 		writeStatus(w, http.StatusBadRequest)
 		return
 	}
-	baseDir := filepath.Join(os.TempDir(), "line-sticker-exports")
-	path := filepath.Join(baseDir, name)
+	path := filepath.Join(exportDir(), name)
 	if _, err := os.Stat(path); err != nil {
 		writeStatus(w, http.StatusNotFound)
 		return
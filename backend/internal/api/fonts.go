@@ -0,0 +1,155 @@
+package api
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gomedium"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+)
+
+// maxFontBytes is larger than the image limit: CJK fonts are often 15-20 MB.
+const maxFontBytes = 40 << 20
+
+const defaultCaptionFont = "gobold"
+
+// cjkCaptionFont ends every fallback chain so Chinese and Japanese captions
+// render without an upload.
+const cjkCaptionFont = "notosanscjk"
+
+// notoSansCJK is Noto Sans CJK TC Bold cut down to kana, CJK punctuation
+// and the common hanzi/kanji; gen_cjkfont.go lists what it keeps. Rarer
+// characters and Hangul still need an uploaded font.
+//
+//go:generate go run gen_cjkfont.go -src NotoSansCJK-Bold.ttc
+//go:embed fonts/NotoSansCJKtc-Bold-subset.ttf
+var notoSansCJK []byte
+
+// bundledFontIDs orders bundledFonts for listing.
+var bundledFontIDs = []string{"gobold", "gomedium", "goregular", cjkCaptionFont}
+
+// bundledFonts ship with the binary: the Go fonts for Latin scripts and a
+// CJK subset for the rest.
+var bundledFonts = map[string]struct {
+	name   string
+	family string
+	data   []byte
+}{
+	"gobold":       {"Go Bold", "Go", gobold.TTF},
+	"gomedium":     {"Go Medium", "Go", gomedium.TTF},
+	"goregular":    {"Go Regular", "Go", goregular.TTF},
+	cjkCaptionFont: {"Noto Sans CJK TC Bold", "Noto Sans CJK TC", notoSansCJK},
+}
+
+var fontCache = struct {
+	sync.Mutex
+	fonts map[string]*opentype.Font
+}{fonts: map[string]*opentype.Font{}}
+
+func fontDir() string {
+	return filepath.Join(os.TempDir(), "line-sticker-fonts")
+}
+
+// parseFontData accepts TrueType/OpenType fonts and collections; for a
+// collection the first font is used.
+func parseFontData(data []byte) (*opentype.Font, error) {
+	if bytes.HasPrefix(data, []byte("ttcf")) {
+		c, err := opentype.ParseCollection(data)
+		if err != nil {
+			return nil, err
+		}
+		return c.Font(0)
+	}
+	return opentype.Parse(data)
+}
+
+// fontFamily reads the family name from the font's name table.
+func fontFamily(f *opentype.Font) string {
+	name, err := f.Name(nil, sfnt.NameIDFamily)
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// saveFont validates and stores an uploaded font file, returning its path.
+func saveFont(id string, data []byte) (string, *opentype.Font, error) {
+	if len(data) == 0 {
+		return "", nil, errors.New("empty file")
+	}
+	f, err := parseFontData(data)
+	if err != nil {
+		return "", nil, errors.New("unsupported font (ttf, otf or ttc)")
+	}
+	if err := os.MkdirAll(fontDir(), 0o755); err != nil {
+		return "", nil, err
+	}
+	path := filepath.Join(fontDir(), id)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", nil, err
+	}
+	return path, f, nil
+}
+
+// loadFont returns a parsed font by id. Uploaded fonts are read from path
+// and cached; an empty path means a bundled font.
+func loadFont(id, path string) (*opentype.Font, error) {
+	fontCache.Lock()
+	defer fontCache.Unlock()
+	if f, ok := fontCache.fonts[id]; ok {
+		return f, nil
+	}
+	var data []byte
+	if b, ok := bundledFonts[id]; ok {
+		data = b.data
+	} else if path != "" {
+		d, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		data = d
+	} else {
+		return nil, errors.New("unknown font")
+	}
+	f, err := parseFontData(data)
+	if err != nil {
+		return nil, err
+	}
+	fontCache.fonts[id] = f
+	return f, nil
+}
+
+func forgetFont(id string) {
+	fontCache.Lock()
+	defer fontCache.Unlock()
+	delete(fontCache.fonts, id)
+}
+
+// readFontUpload reads the "file" field of a multipart font upload along
+// with an optional "name". On failure it writes the response.
+func readFontUpload(w http.ResponseWriter, r *http.Request) ([]byte, string, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFontBytes+1<<20)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid multipart form"})
+		return nil, "", false
+	}
+	files := r.MultipartForm.File["file"]
+	if len(files) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing file"})
+		return nil, "", false
+	}
+	data, err := readUploadFile(files[0], maxFontBytes)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": files[0].Filename + ": " + err.Error()})
+		return nil, "", false
+	}
+	return data, r.FormValue("name"), true
+}
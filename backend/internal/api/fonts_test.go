@@ -0,0 +1,42 @@
+package api
+
+import (
+	"testing"
+
+	"golang.org/x/image/font/opentype"
+)
+
+func TestBundledFontsCoverDefaultCaptions(t *testing.T) {
+	fonts := []*opentype.Font{}
+	for _, id := range []string{defaultCaptionFont, cjkCaptionFont} {
+		f, err := loadFont(id, "")
+		if err != nil {
+			t.Fatalf("%s: %v", id, err)
+		}
+		fonts = append(fonts, f)
+	}
+	for _, text := range []string{
+		"草稿 12",
+		"貼圖",
+		"谢谢你！",
+		"ありがとう〜",
+		"おつかれさまです。",
+		"ㄅㄆㄇ",
+		"︒︑﹁好﹂︙",
+		"Hi! 早安",
+	} {
+		if missing := missingGlyphs(text, fonts); len(missing) > 0 {
+			t.Errorf("%q: missing %q", text, string(missing))
+		}
+	}
+}
+
+func TestBundledCJKFontFamily(t *testing.T) {
+	f, err := loadFont(cjkCaptionFont, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fontFamily(f), bundledFonts[cjkCaptionFont].family; got != want {
+		t.Fatalf("family %q, want %q", got, want)
+	}
+}
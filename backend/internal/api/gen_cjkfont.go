@@ -0,0 +1,587 @@
+//go:build ignore
+
+// gen_cjkfont writes fonts/NotoSansCJKtc-Bold-subset.ttf, the bundled CJK
+// caption fallback. It cuts Noto Sans CJK TC Bold down to ASCII, kana,
+// bopomofo, CJK and vertical punctuation, fullwidth forms and the common
+// characters of Big5, GB2312 and JIS X 0208 (their symbol rows and level-1
+// hanzi/kanji), and converts the CFF outlines to TrueType quadratics so the
+// result is a plain glyf font with no layout tables.
+//
+//	go run gen_cjkfont.go -src NotoSansCJK-Bold.ttc
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+var (
+	src   = flag.String("src", "", "Noto Sans CJK Bold collection (.ttc)")
+	index = flag.Int("index", 3, "font in the collection; 3 is TC")
+	out   = flag.String("out", "fonts/NotoSansCJKtc-Bold-subset.ttf", "output file")
+)
+
+// tolerance is how far, in font units, a quadratic may stray from the
+// cubic it replaces.
+const tolerance = 0.5
+
+func main() {
+	flag.Parse()
+	data, err := os.ReadFile(*src)
+	if err != nil {
+		log.Fatal(err)
+	}
+	coll, err := sfnt.ParseCollection(data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	f, err := coll.Font(*index)
+	if err != nil {
+		log.Fatal(err)
+	}
+	raw := rawTables(data, *index)
+	var b sfnt.Buffer
+	upem := fixed.I(int(f.UnitsPerEm()))
+
+	// glyph 0 stays .notdef; the rest follow in code point order so runs of
+	// code points map to runs of glyphs and the cmap stays small
+	runes := []rune{}
+	for _, r := range charset() {
+		if gi, err := f.GlyphIndex(&b, r); err == nil && gi != 0 {
+			runes = append(runes, r)
+		}
+	}
+	olds := []sfnt.GlyphIndex{0}
+	newIndex := map[sfnt.GlyphIndex]uint16{0: 0}
+	cmap := map[rune]uint16{}
+	for _, r := range runes {
+		gi, _ := f.GlyphIndex(&b, r)
+		n, ok := newIndex[gi]
+		if !ok {
+			n = uint16(len(olds))
+			newIndex[gi] = n
+			olds = append(olds, gi)
+		}
+		cmap[r] = n
+	}
+
+	var glyf, loca, hmtx bytes.Buffer
+	var maxPoints, maxContours int
+	bbox := [4]int{math.MaxInt16, math.MaxInt16, math.MinInt16, math.MinInt16}
+	advances := make([]int, len(olds))
+	lsbs := make([]int, len(olds))
+	var maxAdvance, minLSB, minRSB, maxExtent = 0, math.MaxInt16, math.MaxInt16, math.MinInt16
+	for i, gi := range olds {
+		binary.Write(&loca, binary.BigEndian, uint32(glyf.Len()))
+		contours, err := outline(f, &b, gi, upem)
+		if err != nil {
+			log.Fatalf("glyph %d: %v", gi, err)
+		}
+		adv, err := f.GlyphAdvance(&b, gi, upem, font.HintingNone)
+		if err != nil {
+			log.Fatalf("glyph %d: %v", gi, err)
+		}
+		advances[i] = adv.Round()
+		maxAdvance = max(maxAdvance, advances[i])
+		if box, n := bounds(contours); n > 0 {
+			lsbs[i] = box[0]
+			bbox = [4]int{min(bbox[0], box[0]), min(bbox[1], box[1]), max(bbox[2], box[2]), max(bbox[3], box[3])}
+			minLSB = min(minLSB, box[0])
+			minRSB = min(minRSB, advances[i]-box[2])
+			maxExtent = max(maxExtent, box[2])
+			maxPoints = max(maxPoints, n)
+			maxContours = max(maxContours, len(contours))
+		}
+		glyf.Write(encodeGlyph(contours))
+		for glyf.Len()%4 != 0 {
+			glyf.WriteByte(0)
+		}
+	}
+	binary.Write(&loca, binary.BigEndian, uint32(glyf.Len()))
+
+	// trailing glyphs that share the last advance need only their lsb
+	numH := len(advances)
+	for numH > 1 && advances[numH-2] == advances[numH-1] {
+		numH--
+	}
+	for i := range olds {
+		if i < numH {
+			binary.Write(&hmtx, binary.BigEndian, uint16(advances[i]))
+		}
+		binary.Write(&hmtx, binary.BigEndian, int16(lsbs[i]))
+	}
+
+	head := clone(raw["head"])
+	binary.BigEndian.PutUint32(head[8:], 0) // checkSumAdjustment, set by assemble
+	put16(head, 36, bbox[0])
+	put16(head, 38, bbox[1])
+	put16(head, 40, bbox[2])
+	put16(head, 42, bbox[3])
+	put16(head, 50, 1) // long loca offsets
+	put16(head, 52, 0)
+
+	hhea := clone(raw["hhea"])
+	put16(hhea, 10, maxAdvance)
+	put16(hhea, 12, minLSB)
+	put16(hhea, 14, minRSB)
+	put16(hhea, 16, maxExtent)
+	put16(hhea, 34, numH)
+
+	os2 := clone(raw["OS/2"])
+	put16(os2, 64, int(runes[0]))
+	put16(os2, 66, int(runes[len(runes)-1]))
+
+	maxp := make([]byte, 32)
+	binary.BigEndian.PutUint32(maxp, 0x00010000)
+	put16(maxp, 4, len(olds))
+	put16(maxp, 6, maxPoints)
+	put16(maxp, 8, maxContours)
+	put16(maxp, 14, 2) // maxZones
+
+	post := clone(raw["post"][:32])
+	binary.BigEndian.PutUint32(post, 0x00030000)
+
+	tables := map[string][]byte{
+		"OS/2": os2,
+		"cmap": encodeCmap(cmap),
+		"glyf": glyf.Bytes(),
+		"head": head,
+		"hhea": hhea,
+		"hmtx": hmtx.Bytes(),
+		"loca": loca.Bytes(),
+		"maxp": maxp,
+		"name": encodeName(f, &b),
+		"post": post,
+	}
+	if err := os.WriteFile(*out, assemble(tables), 0o644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%d characters, %d glyphs", len(runes), len(olds))
+}
+
+// charset lists the code points to keep.
+func charset() []rune {
+	set := map[rune]bool{}
+	add := func(lo, hi rune) {
+		for r := lo; r <= hi; r++ {
+			set[r] = true
+		}
+	}
+	add(0x20, 0x7E)     // ASCII
+	add(0x3000, 0x30FF) // CJK punctuation and kana
+	add(0x3100, 0x312F) // bopomofo
+	add(0xFE10, 0xFE19) // vertical forms
+	add(0xFE30, 0xFE4F) // CJK compatibility forms
+	add(0xFF01, 0xFF9F) // fullwidth and halfwidth forms
+
+	decode := func(enc encoding.Encoding, lead, trail [2]byte, last uint16) {
+		dec := enc.NewDecoder()
+		for l := int(lead[0]); l <= int(lead[1]); l++ {
+			for t := int(trail[0]); t <= int(trail[1]); t++ {
+				if last != 0 && uint16(l)<<8|uint16(t) > last {
+					return
+				}
+				s, err := dec.Bytes([]byte{byte(l), byte(t)})
+				if err != nil {
+					continue
+				}
+				r, n := utf8.DecodeRune(s)
+				if n == len(s) && r != utf8.RuneError && !unicode.IsControl(r) {
+					set[r] = true
+				}
+			}
+		}
+	}
+	big5 := traditionalchinese.Big5
+	decode(big5, [2]byte{0xA1, 0xA3}, [2]byte{0x40, 0x7E}, 0)
+	decode(big5, [2]byte{0xA1, 0xA3}, [2]byte{0xA1, 0xFE}, 0)
+	decode(big5, [2]byte{0xA4, 0xC6}, [2]byte{0x40, 0x7E}, 0xC67E)
+	decode(big5, [2]byte{0xA4, 0xC5}, [2]byte{0xA1, 0xFE}, 0)
+	gb := simplifiedchinese.GBK
+	decode(gb, [2]byte{0xA1, 0xA9}, [2]byte{0xA1, 0xFE}, 0)
+	decode(gb, [2]byte{0xB0, 0xD7}, [2]byte{0xA1, 0xFE}, 0)
+	sjis := japanese.ShiftJIS
+	decode(sjis, [2]byte{0x81, 0x84}, [2]byte{0x40, 0xFC}, 0)
+	decode(sjis, [2]byte{0x88, 0x98}, [2]byte{0x40, 0xFC}, 0x9872)
+
+	runes := []rune{}
+	for r := range set {
+		if r <= 0xFFFF {
+			runes = append(runes, r)
+		}
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+	return runes
+}
+
+// rawTables returns the table data of font n in a collection.
+func rawTables(data []byte, n int) map[string][]byte {
+	be := binary.BigEndian
+	off := int(be.Uint32(data[12+4*n:]))
+	tables := map[string][]byte{}
+	count := int(be.Uint16(data[off+4:]))
+	for i := 0; i < count; i++ {
+		rec := data[off+12+16*i:]
+		start, size := be.Uint32(rec[8:]), be.Uint32(rec[12:])
+		tables[string(rec[:4])] = data[start : start+size]
+	}
+	return tables
+}
+
+type point struct {
+	x, y int
+	on   bool
+}
+
+type vec struct{ x, y float64 }
+
+func (a vec) add(b vec) vec       { return vec{a.x + b.x, a.y + b.y} }
+func (a vec) sub(b vec) vec       { return vec{a.x - b.x, a.y - b.y} }
+func (a vec) mul(k float64) vec   { return vec{a.x * k, a.y * k} }
+func (a vec) point(on bool) point { return point{int(math.Round(a.x)), int(math.Round(a.y)), on} }
+
+// outline loads a glyph in font units, y up, as TrueType contours.
+func outline(f *sfnt.Font, b *sfnt.Buffer, gi sfnt.GlyphIndex, upem fixed.Int26_6) ([][]point, error) {
+	segs, err := f.LoadGlyph(b, gi, upem, nil)
+	if err != nil {
+		return nil, err
+	}
+	at := func(p fixed.Point26_6) vec { return vec{float64(p.X) / 64, -float64(p.Y) / 64} }
+	contours := [][]point{}
+	var cur []point
+	var pen vec
+	flush := func() {
+		if c := cleanContour(cur); len(c) >= 3 {
+			contours = append(contours, c)
+		}
+		cur = nil
+	}
+	for _, s := range segs {
+		switch s.Op {
+		case sfnt.SegmentOpMoveTo:
+			flush()
+			pen = at(s.Args[0])
+			cur = append(cur, pen.point(true))
+		case sfnt.SegmentOpLineTo:
+			pen = at(s.Args[0])
+			cur = append(cur, pen.point(true))
+		case sfnt.SegmentOpQuadTo:
+			cur = append(cur, at(s.Args[0]).point(false))
+			pen = at(s.Args[1])
+			cur = append(cur, pen.point(true))
+		case sfnt.SegmentOpCubeTo:
+			p1, p2, p3 := at(s.Args[0]), at(s.Args[1]), at(s.Args[2])
+			cur = append(cur, cubicToQuads(pen, p1, p2, p3)...)
+			pen = p3
+		}
+	}
+	flush()
+	return contours, nil
+}
+
+// cubicToQuads splits a cubic into enough pieces that each is within
+// tolerance of a single quadratic, returning control and end points.
+func cubicToQuads(p0, p1, p2, p3 vec) []point {
+	d := p3.sub(p2.mul(3)).add(p1.mul(3)).sub(p0)
+	errMax := math.Sqrt(3) / 36 * math.Hypot(d.x, d.y)
+	n := max(1, int(math.Ceil(math.Cbrt(errMax/tolerance))))
+	at := func(t float64) vec {
+		u := 1 - t
+		return p0.mul(u * u * u).add(p1.mul(3 * u * u * t)).add(p2.mul(3 * u * t * t)).add(p3.mul(t * t * t))
+	}
+	deriv := func(t float64) vec {
+		u := 1 - t
+		return p1.sub(p0).mul(3 * u * u).add(p2.sub(p1).mul(6 * u * t)).add(p3.sub(p2).mul(3 * t * t))
+	}
+	pts := []point{}
+	for i := 0; i < n; i++ {
+		t0, t1 := float64(i)/float64(n), float64(i+1)/float64(n)
+		dt := (t1 - t0) / 3
+		q0, q3 := at(t0), at(t1)
+		q1, q2 := q0.add(deriv(t0).mul(dt)), q3.sub(deriv(t1).mul(dt))
+		c := q1.add(q2).mul(3).sub(q0).sub(q3).mul(0.25)
+		pts = append(pts, c.point(false), q3.point(true))
+	}
+	return pts
+}
+
+// cleanContour drops repeated points, the closing point and on-curve points
+// that TrueType implies between two off-curve points, then reverses the
+// contour to TrueType's clockwise winding.
+func cleanContour(c []point) []point {
+	dedup := []point{}
+	for _, p := range c {
+		if n := len(dedup); n > 0 && dedup[n-1].x == p.x && dedup[n-1].y == p.y && dedup[n-1].on == p.on {
+			continue
+		}
+		dedup = append(dedup, p)
+	}
+	if n := len(dedup); n > 1 && dedup[0] == dedup[n-1] {
+		dedup = dedup[:n-1]
+	}
+	n := len(dedup)
+	keep := []point{}
+	for i, p := range dedup {
+		prev, next := dedup[(i+n-1)%n], dedup[(i+1)%n]
+		if p.on && !prev.on && !next.on && 2*p.x == prev.x+next.x && 2*p.y == prev.y+next.y {
+			continue
+		}
+		keep = append(keep, p)
+	}
+	for i, j := 0, len(keep)-1; i < j; i, j = i+1, j-1 {
+		keep[i], keep[j] = keep[j], keep[i]
+	}
+	return keep
+}
+
+// bounds returns the bounding box and point count of a glyph.
+func bounds(contours [][]point) ([4]int, int) {
+	box := [4]int{math.MaxInt32, math.MaxInt32, math.MinInt32, math.MinInt32}
+	n := 0
+	for _, c := range contours {
+		for _, p := range c {
+			box = [4]int{min(box[0], p.x), min(box[1], p.y), max(box[2], p.x), max(box[3], p.y)}
+			n++
+		}
+	}
+	return box, n
+}
+
+// encodeGlyph writes a simple glyf entry with no instructions.
+func encodeGlyph(contours [][]point) []byte {
+	box, n := bounds(contours)
+	if n == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	w := func(v int) { binary.Write(&buf, binary.BigEndian, int16(v)) }
+	w(len(contours))
+	for _, v := range box {
+		w(v)
+	}
+	end := -1
+	for _, c := range contours {
+		end += len(c)
+		w(end)
+	}
+	w(0)
+
+	var flags []byte
+	var xs, ys bytes.Buffer
+	coord := func(d int, short, same byte, out *bytes.Buffer) byte {
+		switch {
+		case d == 0:
+			return same
+		case d > -256 && d < 256:
+			if d > 0 {
+				out.WriteByte(byte(d))
+				return short | same
+			}
+			out.WriteByte(byte(-d))
+			return short
+		}
+		binary.Write(out, binary.BigEndian, int16(d))
+		return 0
+	}
+	px, py := 0, 0
+	for _, c := range contours {
+		for _, p := range c {
+			flag := coord(p.x-px, 0x02, 0x10, &xs) | coord(p.y-py, 0x04, 0x20, &ys)
+			if p.on {
+				flag |= 0x01
+			}
+			flags = append(flags, flag)
+			px, py = p.x, p.y
+		}
+	}
+	for i := 0; i < len(flags); {
+		j := i + 1
+		for j < len(flags) && flags[j] == flags[i] && j-i <= 255 {
+			j++
+		}
+		if j-i > 1 {
+			buf.Write([]byte{flags[i] | 0x08, byte(j - i - 1)})
+		} else {
+			buf.WriteByte(flags[i])
+		}
+		i = j
+	}
+	buf.Write(xs.Bytes())
+	buf.Write(ys.Bytes())
+	return buf.Bytes()
+}
+
+// encodeCmap writes a Windows Unicode BMP (format 4) cmap.
+func encodeCmap(m map[rune]uint16) []byte {
+	runes := make([]rune, 0, len(m))
+	for r := range m {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+	type segment struct{ start, end rune }
+	segs := []segment{}
+	for _, r := range runes {
+		if n := len(segs); n > 0 && segs[n-1].end == r-1 && m[r-1]+1 == m[r] {
+			segs[n-1].end = r
+			continue
+		}
+		segs = append(segs, segment{r, r})
+	}
+	segs = append(segs, segment{0xFFFF, 0xFFFF})
+
+	count := len(segs)
+	length := 16 + 8*count
+	if length > 0xFFFF {
+		log.Fatalf("cmap too large: %d segments", count)
+	}
+	search := 1
+	selector := 0
+	for search*2 <= count {
+		search *= 2
+		selector++
+	}
+	var buf bytes.Buffer
+	w := func(v ...int) {
+		for _, x := range v {
+			binary.Write(&buf, binary.BigEndian, uint16(x))
+		}
+	}
+	w(0, 1, 3, 1)
+	binary.Write(&buf, binary.BigEndian, uint32(12))
+	w(4, length, 0, 2*count, 2*search, selector, 2*count-2*search)
+	for _, s := range segs {
+		w(int(s.end))
+	}
+	w(0)
+	for _, s := range segs {
+		w(int(s.start))
+	}
+	for _, s := range segs {
+		if s.start == 0xFFFF {
+			w(1)
+			continue
+		}
+		w((int(m[s.start]) - int(s.start)) & 0xFFFF)
+	}
+	for range segs {
+		w(0)
+	}
+	return buf.Bytes()
+}
+
+// encodeName copies the source names, marking the version as a subset.
+func encodeName(f *sfnt.Font, b *sfnt.Buffer) []byte {
+	ids := []sfnt.NameID{
+		sfnt.NameIDCopyright, sfnt.NameIDFamily, sfnt.NameIDSubfamily, sfnt.NameIDUniqueIdentifier,
+		sfnt.NameIDFull, sfnt.NameIDVersion, sfnt.NameIDPostScript, sfnt.NameIDLicense, sfnt.NameIDLicenseURL,
+	}
+	var records, strs bytes.Buffer
+	w := func(out *bytes.Buffer, v ...int) {
+		for _, x := range v {
+			binary.Write(out, binary.BigEndian, uint16(x))
+		}
+	}
+	count := 0
+	for _, id := range ids {
+		s, err := f.Name(b, id)
+		if err != nil || s == "" {
+			continue
+		}
+		if id == sfnt.NameIDVersion {
+			s += "; subset"
+		}
+		var enc bytes.Buffer
+		for _, r := range s {
+			r1, r2 := utf16Pair(r)
+			w(&enc, r1)
+			if r2 != 0 {
+				w(&enc, r2)
+			}
+		}
+		w(&records, 3, 1, 0x409, int(id), enc.Len(), strs.Len())
+		strs.Write(enc.Bytes())
+		count++
+	}
+	var buf bytes.Buffer
+	w(&buf, 0, count, 6+12*count)
+	buf.Write(records.Bytes())
+	buf.Write(strs.Bytes())
+	return buf.Bytes()
+}
+
+func utf16Pair(r rune) (int, int) {
+	if r < 0x10000 {
+		return int(r), 0
+	}
+	r -= 0x10000
+	return 0xD800 + int(r>>10), 0xDC00 + int(r&0x3FF)
+}
+
+// assemble lays out the table directory and tables, then fixes up the
+// head checksum adjustment.
+func assemble(tables map[string][]byte) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	search, selector := 1, 0
+	for search*2 <= len(tags) {
+		search *= 2
+		selector++
+	}
+	var buf bytes.Buffer
+	w := func(v ...uint16) { binary.Write(&buf, binary.BigEndian, v) }
+	binary.Write(&buf, binary.BigEndian, uint32(0x00010000))
+	w(uint16(len(tags)), uint16(16*search), uint16(selector), uint16(16*len(tags)-16*search))
+
+	offset := 12 + 16*len(tags)
+	headAt := 0
+	for _, tag := range tags {
+		data := tables[tag]
+		buf.WriteString(tag)
+		binary.Write(&buf, binary.BigEndian, []uint32{checksum(data), uint32(offset), uint32(len(data))})
+		if tag == "head" {
+			headAt = offset
+		}
+		offset += (len(data) + 3) &^ 3
+	}
+	for _, tag := range tags {
+		buf.Write(tables[tag])
+		for buf.Len()%4 != 0 {
+			buf.WriteByte(0)
+		}
+	}
+	out := buf.Bytes()
+	binary.BigEndian.PutUint32(out[headAt+8:], 0xB1B0AFBA-checksum(out))
+	return out
+}
+
+func checksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+func clone(b []byte) []byte { return append([]byte(nil), b...) }
+
+func put16(b []byte, at, v int) { binary.BigEndian.PutUint16(b[at:], uint16(v)) }
@@ -0,0 +1,282 @@
+package api
+
+import (
+	"image"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// alphaMask copies the alpha channel of img.
+func alphaMask(img image.Image) *image.Alpha {
+	b := img.Bounds()
+	out := image.NewAlpha(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			_, _, _, a := img.At(x, y).RGBA()
+			out.Pix[out.PixOffset(x, y)] = uint8(a >> 8)
+		}
+	}
+	return out
+}
+
+// dilateAlpha grows an alpha mask by radius pixels with an anti-aliased
+// round edge.
+func dilateAlpha(src *image.Alpha, radius float64) *image.Alpha {
+	b := src.Bounds()
+	out := image.NewAlpha(b)
+	type offset struct {
+		dx, dy int
+		weight float64
+	}
+	r := int(math.Ceil(radius))
+	offsets := []offset{}
+	for dy := -r; dy <= r; dy++ {
+		for dx := -r; dx <= r; dx++ {
+			d := math.Hypot(float64(dx), float64(dy))
+			if w := math.Min(1, radius+0.5-d); w > 0 {
+				offsets = append(offsets, offset{dx, dy, w})
+			}
+		}
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			a := src.AlphaAt(x, y).A
+			if a == 0 {
+				continue
+			}
+			for _, o := range offsets {
+				px, py := x+o.dx, y+o.dy
+				if px < b.Min.X || py < b.Min.Y || px >= b.Max.X || py >= b.Max.Y {
+					continue
+				}
+				v := uint8(float64(a) * o.weight)
+				i := out.PixOffset(px, py)
+				if v > out.Pix[i] {
+					out.Pix[i] = v
+				}
+			}
+		}
+	}
+	return out
+}
+
+// blurAlpha softens a mask with three box blur passes, which approximate a
+// gaussian of the given radius.
+func blurAlpha(src *image.Alpha, radius int) *image.Alpha {
+	if radius <= 0 {
+		return src
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	cur := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			cur[y*w+x] = float64(src.Pix[src.PixOffset(b.Min.X+x, b.Min.Y+y)])
+		}
+	}
+	tmp := make([]float64, w*h)
+	for pass := 0; pass < 3; pass++ {
+		boxBlur(cur, tmp, w, h, radius, 1, w)
+		boxBlur(tmp, cur, h, w, radius, w, 1)
+	}
+	out := image.NewAlpha(b)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			out.Pix[out.PixOffset(b.Min.X+x, b.Min.Y+y)] = uint8(math.Min(255, math.Round(cur[y*w+x])))
+		}
+	}
+	return out
+}
+
+// boxBlur runs a running-sum blur along one axis: n samples per line, lines
+// lines, step between samples and stride between lines.
+func boxBlur(src, dst []float64, n, lines, radius, step, stride int) {
+	size := float64(2*radius + 1)
+	for l := 0; l < lines; l++ {
+		base := l * stride
+		sum := 0.0
+		for i := -radius; i <= radius; i++ {
+			if i >= 0 && i < n {
+				sum += src[base+i*step]
+			}
+		}
+		for i := 0; i < n; i++ {
+			dst[base+i*step] = sum / size
+			if out := i - radius; out >= 0 {
+				sum -= src[base+out*step]
+			}
+			if in := i + radius + 1; in < n {
+				sum += src[base+in*step]
+			}
+		}
+	}
+}
+
+// applyOutline draws a stroke (and optionally a drop shadow) around the
+// opaque part of src. The subject is first fitted inside the canvas minus
+// the margin and the stroke and shadow extent so nothing is cut off.
+func applyOutline(src image.Image, cfg OutlineConfig, canvasW, canvasH, margin int) (*image.RGBA, error) {
+	stroke, err := parseHexColor(cfg.Color)
+	if err != nil {
+		return nil, err
+	}
+	subject, err := placeOnCanvas(src, canvasW, canvasH, margin+outlinePadding(cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	mask := alphaMask(subject)
+	outline := blurAlpha(dilateAlpha(mask, float64(cfg.Width)), cfg.Softness)
+	dst := image.NewRGBA(subject.Bounds())
+	if cfg.Shadow.Enabled {
+		shadow, err := parseHexColor(cfg.Shadow.Color)
+		if err != nil {
+			return nil, err
+		}
+		shadowMask := blurAlpha(outline, cfg.Shadow.Blur)
+		offset := image.Pt(-cfg.Shadow.OffsetX, -cfg.Shadow.OffsetY)
+		draw.DrawMask(dst, dst.Bounds(), image.NewUniform(shadow), image.Point{}, shadowMask, offset, draw.Over)
+	}
+	draw.DrawMask(dst, dst.Bounds(), image.NewUniform(stroke), image.Point{}, outline, image.Point{}, draw.Over)
+	draw.Draw(dst, dst.Bounds(), subject, image.Point{}, draw.Over)
+	return dst, nil
+}
+
+// outlinePadding is how far the stroke and shadow reach past the subject.
+func outlinePadding(cfg OutlineConfig) int {
+	pad := cfg.Width + cfg.Softness
+	if cfg.Shadow.Enabled {
+		offset := absInt(cfg.Shadow.OffsetX)
+		if dy := absInt(cfg.Shadow.OffsetY); dy > offset {
+			offset = dy
+		}
+		pad += offset + 2*cfg.Shadow.Blur
+	}
+	return pad
+}
+
+// placeOnCanvas fits src inside the canvas minus inset on every side and
+// centres it on a transparent canvas.
+func placeOnCanvas(src image.Image, canvasW, canvasH, inset int) (*image.RGBA, error) {
+	inner, err := fitImage(src, canvasW-2*inset, canvasH-2*inset)
+	if err != nil {
+		return nil, err
+	}
+	// fitImage centres within its own canvas, so the inset offset is exact
+	dst := image.NewRGBA(image.Rect(0, 0, canvasW, canvasH))
+	draw.Draw(dst, inner.Bounds().Add(image.Pt(inset, inset)), inner, image.Point{}, draw.Src)
+	return dst, nil
+}
+
+// alphaBounds is the bounding box of pixels whose alpha exceeds threshold.
+// It is empty for a fully transparent image.
+func alphaBounds(img image.Image, threshold uint8) image.Rectangle {
+	b := img.Bounds()
+	minX, minY, maxX, maxY := b.Max.X, b.Max.Y, b.Min.X, b.Min.Y
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			_, _, _, a := img.At(x, y).RGBA()
+			if uint8(a>>8) <= threshold {
+				continue
+			}
+			if x < minX {
+				minX = x
+			}
+			if x >= maxX {
+				maxX = x + 1
+			}
+			if y < minY {
+				minY = y
+			}
+			if y >= maxY {
+				maxY = y + 1
+			}
+		}
+	}
+	if minX >= maxX || minY >= maxY {
+		return image.Rectangle{}
+	}
+	return image.Rect(minX, minY, maxX, maxY)
+}
+
+// trimSubject crops frames to their subject. The box is the union over all
+// frames so an animation keeps one scale and position throughout. For fill
+// and smart-crop the crop also takes the aspect ratio of the target area so
+// that fitting it afterwards covers the area completely; smart-crop weighs
+// the first frame.
+func trimSubject(frames []image.Image, cfg TrimConfig, targetW, targetH int) []image.Image {
+	box := image.Rectangle{}
+	for _, img := range frames {
+		box = box.Union(alphaBounds(img, uint8(cfg.AlphaThreshold)))
+	}
+	if box.Empty() {
+		return frames
+	}
+	out := make([]image.Image, len(frames))
+	for i, img := range frames {
+		rgba := image.NewRGBA(img.Bounds())
+		draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+		if i == 0 && (cfg.Mode == trimFill || cfg.Mode == trimSmartCrop) {
+			box = coverCrop(rgba, box, cfg, float64(targetW)/float64(targetH))
+		}
+		out[i] = rgba.SubImage(box)
+	}
+	return out
+}
+
+// coverCrop narrows box to the given aspect ratio along its longer axis.
+// fill keeps the centre; smart-crop slides the window to where the subject
+// has the most opaque pixels. On ties a vertical crop keeps the top, where
+// faces usually are, and a horizontal one stays central.
+func coverCrop(img *image.RGBA, box image.Rectangle, cfg TrimConfig, aspect float64) image.Rectangle {
+	bw, bh := box.Dx(), box.Dy()
+	horizontal := float64(bw)/float64(bh) > aspect
+	length, window := bh, int(math.Round(float64(bw)/aspect))
+	if horizontal {
+		length, window = bw, int(math.Round(float64(bh)*aspect))
+	}
+	if window >= length || window < 1 {
+		return box
+	}
+	center := (length - window) / 2
+	offset := center
+	if cfg.Mode == trimSmartCrop {
+		span := bw
+		if horizontal {
+			span = bh
+		}
+		// prefix sums of alpha per row (or column) of the box
+		mass := make([]int, length+1)
+		for i := 0; i < length; i++ {
+			sum := 0
+			for j := 0; j < span; j++ {
+				x, y := box.Min.X+j, box.Min.Y+i
+				if horizontal {
+					x, y = box.Min.X+i, box.Min.Y+j
+				}
+				sum += int(img.Pix[img.PixOffset(x, y)+3])
+			}
+			mass[i+1] = mass[i] + sum
+		}
+		best := -1
+		for o := 0; o+window <= length; o++ {
+			v := mass[o+window] - mass[o]
+			closer := horizontal && absInt(o-center) < absInt(offset-center)
+			if v > best || (v == best && closer) {
+				best, offset = v, o
+			}
+		}
+	}
+	if horizontal {
+		return image.Rect(box.Min.X+offset, box.Min.Y, box.Min.X+offset+window, box.Max.Y)
+	}
+	return image.Rect(box.Min.X, box.Min.Y+offset, box.Max.X, box.Min.Y+offset+window)
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -6,44 +6,68 @@ import (
 	"errors"
 	"image"
 	"image/png"
-	"io"
-	"net/http"
-	"time"
+	"os"
 
+	"example.com/app/internal/imageio"
 	"golang.org/x/image/draw"
 )
 
-const (
-	stickerWidth  = 370
-	stickerHeight = 320
-)
-
-func normalizeStickerImage(imageURL string) (string, error) {
-	return normalizeImageToSize(imageURL, stickerWidth, stickerHeight)
-}
-
 func normalizeImageToSize(imageURL string, targetW, targetH int) (string, error) {
 	if imageURL == "" {
 		return "", errors.New("empty image url")
 	}
-	client := &http.Client{Timeout: 20 * time.Second}
-	resp, err := client.Get(imageURL)
+	src, err := loadImage(imageURL)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 400 {
-		return "", errors.New("image fetch failed")
+	dst, err := fitImage(src, targetW, targetH)
+	if err != nil {
+		return "", err
 	}
-	limited := io.LimitReader(resp.Body, 10<<20)
-	src, _, err := image.Decode(limited)
+	return pngDataURL(dst)
+}
+
+// normalizeImageInArea is normalizeImageToSize for an image that fills only
+// r of its targetW x targetH canvas.
+func normalizeImageInArea(imageURL string, r image.Rectangle, targetW, targetH int) (string, error) {
+	if imageURL == "" {
+		return "", errors.New("empty image url")
+	}
+	src, err := loadImage(imageURL)
+	if err != nil {
+		return "", err
+	}
+	dst, err := placeInArea(src, r, targetW, targetH)
 	if err != nil {
 		return "", err
 	}
+	return pngDataURL(dst)
+}
+
+// placeInArea fits src into r on a transparent w x h canvas.
+func placeInArea(src image.Image, r image.Rectangle, w, h int) (*image.RGBA, error) {
+	inner, err := fitImage(src, r.Dx(), r.Dy())
+	if err != nil {
+		return nil, err
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, r, inner, image.Point{}, draw.Src)
+	return dst, nil
+}
+
+// fitImage scales src to fit inside targetW x targetH, centred on a
+// transparent canvas.
+func fitImage(src image.Image, targetW, targetH int) (*image.RGBA, error) {
 	bw := src.Bounds().Dx()
 	bh := src.Bounds().Dy()
 	if bw == 0 || bh == 0 {
-		return "", errors.New("invalid image")
+		return nil, errors.New("invalid image")
+	}
+	if bw == targetW && bh == targetH {
+		// already fitted (e.g. by post-processing); avoid resampling blur
+		dst := image.NewRGBA(image.Rect(0, 0, targetW, targetH))
+		draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Src)
+		return dst, nil
 	}
 
 	scaleW := float64(targetW) / float64(bw)
@@ -66,11 +90,42 @@ func normalizeImageToSize(imageURL string, targetW, targetH int) (string, error)
 	dst := image.NewRGBA(image.Rect(0, 0, targetW, targetH))
 	dstRect := image.Rect(offX, offY, offX+newW, offY+newH)
 	draw.CatmullRom.Scale(dst, dstRect, src, src.Bounds(), draw.Over, nil)
+	return dst, nil
+}
+
+// loadImage fetches and decodes an image reference of any supported format.
+func loadImage(url string) (image.Image, error) {
+	data, err := loadImageBytes(url)
+	if err != nil {
+		return nil, err
+	}
+	return imageio.Decode(data)
+}
 
+func encodePNG(img image.Image) ([]byte, error) {
 	buf := &bytes.Buffer{}
-	if err := png.Encode(buf, dst); err != nil {
+	if err := png.Encode(buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func pngDataURL(img image.Image) (string, error) {
+	data, err := encodePNG(img)
+	if err != nil {
 		return "", err
 	}
-	encoded := base64.StdEncoding.EncodeToString(buf.Bytes())
-	return "data:image/png;base64," + encoded, nil
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// loadImageBytes reads the raw bytes behind an image reference: a local asset,
+// an image data URL or a remote http(s) URL.
+func loadImageBytes(url string) ([]byte, error) {
+	if url == "" {
+		return nil, errors.New("empty url")
+	}
+	if path, ok := assetPath(url); ok {
+		return os.ReadFile(path)
+	}
+	return imageio.Fetch(url)
 }
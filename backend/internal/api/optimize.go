@@ -0,0 +1,258 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"sort"
+)
+
+// paletteSizes are the palettes tried in turn when a file is still over its
+// size target after lossless compression.
+var paletteSizes = []int{256, 128, 64, 32, 16}
+
+// maxPaletteSamples caps how many pixels the palette is built from.
+const maxPaletteSamples = 1 << 16
+
+// compressPNG encodes img with the best zlib compression, which Go's
+// default png.Encode does not use.
+func compressPNG(img image.Image) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := &png.Encoder{CompressionLevel: png.BestCompression}
+	if err := enc.Encode(buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// optimizePNG shrinks a still PNG toward target bytes: first losslessly,
+// then by quantizing to ever smaller palettes. Data already within target
+// is returned unchanged; a target of 0 only recompresses.
+func optimizePNG(data []byte, target int) ([]byte, *PNGOptimization, error) {
+	opt := &PNGOptimization{OriginalBytes: len(data), TargetBytes: target}
+	if target > 0 && len(data) <= target {
+		return data, opt, nil
+	}
+	src, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	img := toNRGBA(src)
+	best, err := compressPNG(img)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(best) < len(data) {
+		data = best
+	}
+	data, err = reduceColors(data, opt, func(colors int) ([]byte, error) {
+		pal, transparent := buildPalette([]*image.NRGBA{img}, colors)
+		return compressPNG(quantize(img, pal, transparent))
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, opt, nil
+}
+
+// reduceColors re-encodes with ever smaller palettes while the data is
+// over the target, keeping the smallest result. It stops once a palette
+// no longer makes the file smaller; opt records the palette kept.
+func reduceColors(data []byte, opt *PNGOptimization, encode func(colors int) ([]byte, error)) ([]byte, error) {
+	for _, colors := range paletteSizes {
+		if opt.TargetBytes == 0 || len(data) <= opt.TargetBytes {
+			break
+		}
+		out, err := encode(colors)
+		if err != nil {
+			return nil, err
+		}
+		if len(out) >= len(data) {
+			break
+		}
+		data = out
+		opt.Colors = colors
+		opt.QualityReduced = true
+	}
+	return data, nil
+}
+
+// noteOptimization records how a file was shrunk on its validation, with a
+// warning when colours were lost.
+func noteOptimization(fv *FileValidation, opt *PNGOptimization) {
+	fv.Optimization = opt
+	if opt != nil && opt.QualityReduced {
+		message := fmt.Sprintf("reduced to %d colours to fit %s", opt.Colors, formatBytes(opt.TargetBytes))
+		fv.Findings = append(fv.Findings, ValidationFinding{Rule: "quality", Severity: severityWarning, Message: message})
+	}
+}
+
+// buildPalette picks up to n colours for the images by median cut over
+// their visible pixels, alpha included. When any pixel is fully
+// transparent, entry 0 is kept for it and transparent is set.
+func buildPalette(imgs []*image.NRGBA, n int) (pal color.Palette, transparent bool) {
+	total := 0
+	for _, img := range imgs {
+		total += len(img.Pix) / 4
+	}
+	step := total/maxPaletteSamples + 1
+	samples := [][4]uint8{}
+	for _, img := range imgs {
+		for i := 0; i < len(img.Pix); i += 4 {
+			if img.Pix[i+3] == 0 {
+				transparent = true
+				continue
+			}
+			if (i/4)%step == 0 {
+				samples = append(samples, [4]uint8{img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3]})
+			}
+		}
+	}
+	if transparent {
+		pal = append(pal, color.NRGBA{})
+		n--
+	}
+	for _, box := range medianCut(samples, n) {
+		pal = append(pal, boxAverage(box))
+	}
+	if len(pal) == 0 {
+		pal = append(pal, color.NRGBA{})
+	}
+	return pal, transparent
+}
+
+// medianCut splits the samples into at most n boxes, each time halving the
+// box with the widest channel range at its median.
+func medianCut(samples [][4]uint8, n int) [][][4]uint8 {
+	if len(samples) == 0 || n <= 0 {
+		return nil
+	}
+	boxes := [][][4]uint8{samples}
+	for len(boxes) < n {
+		pick, channel, widest := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			if c, r := widestChannel(box); r > widest {
+				pick, channel, widest = i, c, r
+			}
+		}
+		if pick < 0 {
+			break
+		}
+		box := boxes[pick]
+		sort.Slice(box, func(a, b int) bool { return box[a][channel] < box[b][channel] })
+		mid := len(box) / 2
+		boxes[pick] = box[:mid]
+		boxes = append(boxes, box[mid:])
+	}
+	return boxes
+}
+
+func widestChannel(box [][4]uint8) (channel, width int) {
+	lo := [4]uint8{255, 255, 255, 255}
+	hi := [4]uint8{}
+	for _, p := range box {
+		for c := 0; c < 4; c++ {
+			lo[c] = min(lo[c], p[c])
+			hi[c] = max(hi[c], p[c])
+		}
+	}
+	for c := 0; c < 4; c++ {
+		if r := int(hi[c]) - int(lo[c]); r > width {
+			channel, width = c, r
+		}
+	}
+	return channel, width
+}
+
+func boxAverage(box [][4]uint8) color.NRGBA {
+	var sum [4]int
+	for _, p := range box {
+		for c := 0; c < 4; c++ {
+			sum[c] += int(p[c])
+		}
+	}
+	n := len(box)
+	return color.NRGBA{
+		R: uint8((sum[0] + n/2) / n),
+		G: uint8((sum[1] + n/2) / n),
+		B: uint8((sum[2] + n/2) / n),
+		A: uint8((sum[3] + n/2) / n),
+	}
+}
+
+// quantize maps img onto the palette with Floyd-Steinberg dithering over
+// all four channels. Fully transparent pixels take entry 0 when
+// transparent is set and neither take nor spread error.
+func quantize(img *image.NRGBA, pal color.Palette, transparent bool) *image.Paletted {
+	b := img.Bounds()
+	out := image.NewPaletted(b, pal)
+	entries := make([][4]float32, len(pal))
+	for i, c := range pal {
+		n := c.(color.NRGBA)
+		entries[i] = [4]float32{float32(n.R), float32(n.G), float32(n.B), float32(n.A)}
+	}
+	first := 0
+	if transparent {
+		first = 1
+	}
+	// nearest entries are cached per colour at 5 bits a channel
+	cache := make([]int16, 1<<20)
+	for i := range cache {
+		cache[i] = -1
+	}
+	nearest := func(v [4]float32) int {
+		key := int(v[0])>>3<<15 | int(v[1])>>3<<10 | int(v[2])>>3<<5 | int(v[3])>>3
+		if idx := cache[key]; idx >= 0 {
+			return int(idx)
+		}
+		idx, dist := first, float32(-1)
+		for i := first; i < len(entries); i++ {
+			d := float32(0)
+			for c := 0; c < 4; c++ {
+				diff := v[c] - entries[i][c]
+				d += diff * diff
+			}
+			if dist < 0 || d < dist {
+				idx, dist = i, d
+			}
+		}
+		cache[key] = int16(idx)
+		return idx
+	}
+
+	w := b.Dx()
+	cur := make([][4]float32, w+2)
+	next := make([][4]float32, w+2)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < w; x++ {
+			off := y*img.Stride + x*4
+			if transparent && img.Pix[off+3] == 0 {
+				out.Pix[y*out.Stride+x] = 0
+				continue
+			}
+			var v [4]float32
+			for c := 0; c < 4; c++ {
+				v[c] = min(max(float32(img.Pix[off+c])+cur[x+1][c], 0), 255)
+			}
+			idx := nearest(v)
+			out.Pix[y*out.Stride+x] = uint8(idx)
+			for c := 0; c < 4; c++ {
+				e := v[c] - entries[idx][c]
+				cur[x+2][c] += e * 7 / 16
+				next[x][c] += e * 3 / 16
+				next[x+1][c] += e * 5 / 16
+				next[x+2][c] += e * 1 / 16
+			}
+		}
+		cur, next = next, cur
+		for i := range next {
+			next[i] = [4]float32{}
+		}
+	}
+	return out
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"testing"
+)
+
+// noisyPNG is a 370x320 sticker-sized image of random colours with a fully
+// transparent border, encoded as an RGBA PNG.
+func noisyPNG(t *testing.T) ([]byte, *image.NRGBA) {
+	t.Helper()
+	r := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, 370, 320))
+	for y := 0; y < 320; y++ {
+		for x := 0; x < 370; x++ {
+			if x < 40 || x >= 330 || y < 40 || y >= 280 {
+				continue
+			}
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(r.Intn(256)), G: uint8(r.Intn(256)), B: uint8(r.Intn(256)), A: uint8(128 + r.Intn(128))})
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), img
+}
+
+func TestOptimizePNGReducesColors(t *testing.T) {
+	data, src := noisyPNG(t)
+	target := len(data) / 4
+	out, opt, err := optimizePNG(data, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) > target {
+		t.Fatalf("optimized to %d bytes, want at most %d", len(out), target)
+	}
+	if !opt.QualityReduced || opt.Colors == 0 {
+		t.Fatalf("optimization = %+v, want a reduced palette", opt)
+	}
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if img.Bounds() != src.Bounds() {
+		t.Fatalf("bounds %v, want %v", img.Bounds(), src.Bounds())
+	}
+	for y := 0; y < 320; y++ {
+		for x := 0; x < 370; x++ {
+			if src.NRGBAAt(x, y).A != 0 {
+				continue
+			}
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0 {
+				t.Fatalf("pixel (%d,%d) has alpha %d, want transparent", x, y, a)
+			}
+		}
+	}
+}
+
+func TestOptimizePNGKeepsDataWithinTarget(t *testing.T) {
+	data, _ := noisyPNG(t)
+	out, opt, err := optimizePNG(data, len(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Fatal("data within the target was re-encoded")
+	}
+	if opt.QualityReduced {
+		t.Fatal("quality reduced for data within the target")
+	}
+}
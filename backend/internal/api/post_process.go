@@ -0,0 +1,202 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"image"
+)
+
+const (
+	maxOutlineStroke = 24
+	maxTrimMargin    = 40
+
+	trimFit       = "fit"
+	trimFill      = "fill"
+	trimSmartCrop = "smart-crop"
+)
+
+// defaultPostProcess recolours the semi-transparent cut-out edges and trims
+// to the subject with LINE's recommended 10px margin. Speck removal, alpha
+// hardening and the outline change the artwork itself, so they are opt-in.
+func defaultPostProcess() PostProcessConfig {
+	return PostProcessConfig{
+		Defringe: DefringeConfig{
+			Enabled:    true,
+			Radius:     2,
+			HardenLow:  24,
+			HardenHigh: 232,
+		},
+		Trim: TrimConfig{
+			Enabled:        true,
+			Mode:           trimFit,
+			Margin:         10,
+			AlphaThreshold: 8,
+		},
+		Outline: OutlineConfig{
+			Width:    8,
+			Color:    "#FFFFFF",
+			Softness: 1,
+			Shadow: ShadowConfig{
+				OffsetY: 4,
+				Blur:    4,
+				Color:   "#00000059",
+			},
+		},
+	}
+}
+
+// mergeStage decodes a partial stage update onto its current value.
+func mergeStage(raw json.RawMessage, stage interface{}) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	return json.Unmarshal(raw, stage)
+}
+
+func validateDefringe(cfg DefringeConfig) error {
+	if cfg.Radius < 0 || cfg.Radius > 8 {
+		return errors.New("defringe.radius must be between 0 and 8")
+	}
+	if cfg.SpeckSize < 0 || cfg.SpeckSize > 10000 {
+		return errors.New("defringe.speckSize must be between 0 and 10000")
+	}
+	if cfg.SpeckAlpha < 0 || cfg.SpeckAlpha > 255 {
+		return errors.New("defringe.speckAlpha must be between 0 and 255")
+	}
+	if cfg.HardenLow < 0 || cfg.HardenHigh > 255 || cfg.HardenLow >= cfg.HardenHigh {
+		return errors.New("defringe.hardenLow must be below hardenHigh, both within 0-255")
+	}
+	return nil
+}
+
+func validateTrim(cfg TrimConfig) error {
+	switch cfg.Mode {
+	case trimFit, trimFill, trimSmartCrop:
+	default:
+		return errors.New("trim.mode must be fit, fill or smart-crop")
+	}
+	if cfg.Margin < 0 || cfg.Margin > maxTrimMargin {
+		return errors.New("trim.margin must be between 0 and 40")
+	}
+	if cfg.AlphaThreshold < 0 || cfg.AlphaThreshold > 254 {
+		return errors.New("trim.alphaThreshold must be between 0 and 254")
+	}
+	return nil
+}
+
+func validateOutline(cfg OutlineConfig) error {
+	if cfg.Width < 1 || cfg.Width > maxOutlineStroke {
+		return errors.New("outline.width must be between 1 and 24")
+	}
+	if cfg.Softness < 0 || cfg.Softness > 8 {
+		return errors.New("outline.softness must be between 0 and 8")
+	}
+	if _, err := parseHexColor(cfg.Color); err != nil {
+		return errors.New("outline.color: " + err.Error())
+	}
+	sh := cfg.Shadow
+	if sh.OffsetX < -20 || sh.OffsetX > 20 || sh.OffsetY < -20 || sh.OffsetY > 20 {
+		return errors.New("outline.shadow offsets must be between -20 and 20")
+	}
+	if sh.Blur < 0 || sh.Blur > 16 {
+		return errors.New("outline.shadow.blur must be between 0 and 16")
+	}
+	if _, err := parseHexColor(sh.Color); err != nil {
+		return errors.New("outline.shadow.color: " + err.Error())
+	}
+	return nil
+}
+
+// validatePostProcess checks cfg against the smallest side of the canvas
+// the project lays stickers out on.
+func validatePostProcess(cfg PostProcessConfig, canvasSide int) error {
+	if err := validateDefringe(cfg.Defringe); err != nil {
+		return err
+	}
+	if err := validateTrim(cfg.Trim); err != nil {
+		return err
+	}
+	if err := validateOutline(cfg.Outline); err != nil {
+		return err
+	}
+	if 2*cfg.inset() >= canvasSide/2 {
+		return errors.New("margin, outline and shadow leave no room for the sticker")
+	}
+	return nil
+}
+
+// inset is the space the enabled stages keep free around the subject.
+func (cfg PostProcessConfig) inset() int {
+	inset := 0
+	if cfg.Trim.Enabled {
+		inset += cfg.Trim.Margin
+	}
+	if cfg.Outline.Enabled {
+		inset += outlinePadding(cfg.Outline)
+	}
+	return inset
+}
+
+// postProcessSticker runs the enabled local stages on a background-removed
+// image: defringe, trim to the subject, then outline, laid out on a
+// canvasW x canvasH canvas. It returns imageURL unchanged when no stage is
+// enabled.
+func postProcessSticker(imageURL string, cfg PostProcessConfig, canvasW, canvasH int) (string, error) {
+	if !cfg.Defringe.Enabled && !cfg.Trim.Enabled && !cfg.Outline.Enabled {
+		return imageURL, nil
+	}
+	img, err := loadImage(imageURL)
+	if err != nil {
+		return "", err
+	}
+	out, err := postProcessFrames([]image.Image{img}, cfg, canvasW, canvasH)
+	if err != nil {
+		return "", err
+	}
+	return pngDataURL(out[0])
+}
+
+// postProcessFrames runs the stages over images that share one layout, such
+// as the frames of an animation, and lays each out on a canvasW x canvasH
+// canvas. Frames of a different size are first fitted to the first one.
+func postProcessFrames(frames []image.Image, cfg PostProcessConfig, canvasW, canvasH int) ([]*image.RGBA, error) {
+	if len(frames) == 0 {
+		return nil, errors.New("no frames")
+	}
+	size := frames[0].Bounds().Size()
+	imgs := make([]image.Image, len(frames))
+	for i, img := range frames {
+		if img.Bounds().Size() != size {
+			fitted, err := fitImage(img, size.X, size.Y)
+			if err != nil {
+				return nil, err
+			}
+			img = fitted
+		}
+		if cfg.Defringe.Enabled {
+			clean := toNRGBA(img)
+			defringe(clean, cfg.Defringe)
+			img = clean
+		}
+		imgs[i] = img
+	}
+	margin := 0
+	if cfg.Trim.Enabled {
+		inset := cfg.inset()
+		imgs = trimSubject(imgs, cfg.Trim, canvasW-2*inset, canvasH-2*inset)
+		margin = cfg.Trim.Margin
+	}
+	out := make([]*image.RGBA, len(imgs))
+	for i, img := range imgs {
+		var err error
+		if cfg.Outline.Enabled {
+			out[i], err = applyOutline(img, cfg.Outline, canvasW, canvasH, margin)
+		} else {
+			out[i], err = placeOnCanvas(img, canvasW, canvasH, margin)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
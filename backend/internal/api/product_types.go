@@ -0,0 +1,233 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	projectTypeSticker  = "sticker"
+	projectTypeAnimated = "animated"
+	projectTypeEmoji    = "emoji"
+	projectTypePopup    = "popup"
+	projectTypeEffect   = "effect"
+	projectTypeMessage  = "message"
+)
+
+// Canvas and image sizes of the LINE products.
+const (
+	stickerWidth   = 370
+	stickerHeight  = 320
+	animatedWidth  = 320
+	animatedHeight = 270
+	emojiSize      = 180
+	popupSize      = 480
+	lineMainSize   = 240
+	lineTabWidth   = 96
+	lineTabHeight  = 74
+)
+
+// productSpec is one LINE product type. Its item rule sets the canvas that
+// images are normalized to, and together with the counts, naming and extra
+// files it drives validation and the export layout.
+type productSpec struct {
+	ID   string
+	Name string
+	// Counts lists the allowed set sizes; when empty any size from MinCount
+	// to MaxCount is accepted.
+	Counts             []int
+	MinCount, MaxCount int
+	// FileFormat names item i (counting from 1).
+	FileFormat string
+	Item       fileRule
+	// PreviewFormat, when set, names the still preview exported next to
+	// each item, rendered with the Preview rule.
+	PreviewFormat string
+	Preview       fileRule
+	// Extras are the store images made from the first item.
+	Extras []extraFile
+	// TextArea, when set, is the default area each item keeps clear for
+	// text the sender types.
+	TextArea *TextArea
+	// Note is added to the README.
+	Note string
+}
+
+type extraFile struct {
+	Name string
+	Rule fileRule
+}
+
+var productSpecs = map[string]productSpec{
+	projectTypeSticker: {
+		ID:         projectTypeSticker,
+		Name:       "Stickers",
+		Counts:     []int{8, 16, 24, 32, 40},
+		FileFormat: "%02d.png",
+		Item:       fileRule{width: stickerWidth, height: stickerHeight, maxBytes: lineMaxFileBytes, margin: true},
+		Extras: []extraFile{
+			{"main.png", fileRule{width: lineMainSize, height: lineMainSize, exact: true, maxBytes: lineMaxFileBytes}},
+			{"tab.png", tabRule},
+		},
+	},
+	projectTypeAnimated: {
+		ID:         projectTypeAnimated,
+		Name:       "Animated Stickers",
+		Counts:     []int{8, 16, 24},
+		FileFormat: "%02d.png",
+		Item:       fileRule{width: animatedWidth, height: animatedHeight, minSide: animatedHeight, maxBytes: lineMaxAPNGBytes, margin: true, animation: &lineAnimation},
+		Extras: []extraFile{
+			{"main.png", fileRule{width: lineMainSize, height: lineMainSize, exact: true, maxBytes: lineMaxAPNGBytes, animation: &lineAnimation}},
+			{"tab.png", tabRule},
+		},
+	},
+	projectTypePopup: {
+		ID:            projectTypePopup,
+		Name:          "Pop-Up Stickers",
+		Counts:        []int{8, 16, 24},
+		FileFormat:    "%02d.png",
+		Item:          fileRule{width: popupSize, height: popupSize, maxBytes: lineMaxAPNGBytes, margin: true, animation: &popupAnimation},
+		PreviewFormat: "%02d_key.png",
+		Preview:       stillPreviewRule,
+		Extras: []extraFile{
+			{"main.png", fileRule{width: lineMainSize, height: lineMainSize, exact: true, maxBytes: lineMaxAPNGBytes, animation: &popupAnimation}},
+			{"tab.png", tabRule},
+		},
+		Note: "Each animation pops out over the chat; the _key image is the still shown in the sticker keyboard.",
+	},
+	projectTypeEffect: {
+		ID:            projectTypeEffect,
+		Name:          "Effect Stickers",
+		Counts:        []int{8, 16, 24},
+		FileFormat:    "%02d.png",
+		Item:          fileRule{width: popupSize, height: popupSize, maxBytes: lineMaxAPNGBytes, animation: &popupAnimation},
+		PreviewFormat: "%02d_key.png",
+		Preview:       stillPreviewRule,
+		Extras: []extraFile{
+			{"main.png", fileRule{width: lineMainSize, height: lineMainSize, exact: true, maxBytes: lineMaxAPNGBytes, animation: &popupAnimation}},
+			{"tab.png", tabRule},
+		},
+		Note: "Each animation plays as a full-screen effect behind the chat; the _key image is the still shown in the sticker keyboard.",
+	},
+	projectTypeEmoji: {
+		ID:         projectTypeEmoji,
+		Name:       "Emoji",
+		MinCount:   8,
+		MaxCount:   40,
+		FileFormat: "%03d.png",
+		Item:       fileRule{width: emojiSize, height: emojiSize, exact: true, maxBytes: lineMaxFileBytes},
+		Extras:     []extraFile{{"tab.png", tabRule}},
+	},
+	projectTypeMessage: {
+		ID:         projectTypeMessage,
+		Name:       "Message Stickers",
+		Counts:     []int{8, 16, 24},
+		FileFormat: "%02d.png",
+		Item:       fileRule{width: stickerWidth, height: stickerHeight, exact: true, maxBytes: lineMaxFileBytes, margin: true},
+		Extras: []extraFile{
+			{"main.png", fileRule{width: lineMainSize, height: lineMainSize, exact: true, maxBytes: lineMaxFileBytes}},
+			{"tab.png", tabRule},
+		},
+		TextArea: &TextArea{X: 20, Y: 200, Width: 330, Height: 100},
+		Note:     "The text area of each item is listed in " + textAreasFile + "; keep it free of artwork.",
+	},
+}
+
+var (
+	tabRule = fileRule{width: lineTabWidth, height: lineTabHeight, exact: true, maxBytes: lineMaxFileBytes}
+	// stillPreviewRule is the keyboard image of pop-up and effect stickers.
+	stillPreviewRule = fileRule{width: stickerWidth, height: stickerHeight, maxBytes: lineMaxFileBytes, margin: true}
+
+	// popupAnimation is shorter than regular animations: 5-20 frames, 1-3
+	// loops and at most 3 seconds of playback.
+	popupAnimation = animationLimits{minFrames: 5, maxFrames: 20, maxLoops: 3, maxPlaybackMs: 3000}
+)
+
+func validProjectType(t string) bool {
+	_, ok := productSpecs[t]
+	return t == "" || ok
+}
+
+func projectTypeNames() string {
+	ids := []string{}
+	for id := range productSpecs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ", ")
+}
+
+// specFor returns the spec of a project type, falling back to stickers.
+func specFor(projectType string) productSpec {
+	if spec, ok := productSpecs[projectType]; ok {
+		return spec
+	}
+	return productSpecs[projectTypeSticker]
+}
+
+func (spec productSpec) fileName(i int) string {
+	return fmt.Sprintf(spec.FileFormat, i)
+}
+
+func (spec productSpec) previewName(i int) string {
+	return fmt.Sprintf(spec.PreviewFormat, i)
+}
+
+func (spec productSpec) countAllowed(n int) bool {
+	if len(spec.Counts) == 0 {
+		return n >= spec.MinCount && n <= spec.MaxCount
+	}
+	for _, c := range spec.Counts {
+		if n == c {
+			return true
+		}
+	}
+	return false
+}
+
+func (spec productSpec) countRange() string {
+	if len(spec.Counts) == 0 {
+		return fmt.Sprintf("%d to %d", spec.MinCount, spec.MaxCount)
+	}
+	allowed := make([]string, len(spec.Counts))
+	for i, c := range spec.Counts {
+		allowed[i] = fmt.Sprint(c)
+	}
+	return strings.Join(allowed, ", ")
+}
+
+func describeFile(r fileRule) string {
+	kind := "PNG"
+	if a := r.animation; a != nil {
+		kind = fmt.Sprintf("APNG, %d-%d frames, 1-%d loops, up to %ds", a.minFrames, a.maxFrames, a.maxLoops, a.maxPlaybackMs/1000)
+	}
+	return fmt.Sprintf("%dx%d %s", r.width, r.height, kind)
+}
+
+// readme describes the files of an export.
+func (spec productSpec) readme() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "LINE %s Export\n", spec.Name)
+	fmt.Fprintf(b, "- %s ... (%s items): %s (transparent)\n", spec.fileName(1), spec.countRange(), describeFile(spec.Item))
+	if spec.PreviewFormat != "" {
+		fmt.Fprintf(b, "- %s ...: %s still preview of each item\n", spec.previewName(1), describeFile(spec.Preview))
+	}
+	for _, extra := range spec.Extras {
+		fmt.Fprintf(b, "- %s: %s\n", extra.Name, describeFile(extra.Rule))
+	}
+	fmt.Fprintf(b, "- %s: every item numbered with its caption, for review; not part of the upload\n", contactSheetFile)
+	if spec.Note != "" {
+		fmt.Fprintf(b, "\n%s\n", spec.Note)
+	}
+	return b.String()
+}
+
+// projectSpec is the spec of a project's type. Caller holds s.mu.
+func (s *Store) projectSpec(projectID string) productSpec {
+	p, ok := s.getProject(projectID)
+	if !ok {
+		return specFor("")
+	}
+	return specFor(p.Type)
+}
@@ -16,5 +16,6 @@ func ListProviders() []Provider {
 		{ID: "kimi", Name: "Kimi", Models: []string{"kimi-k2"}},
 		{ID: "deepseek", Name: "DeepSeek", Models: []string{"deepseek-chat", "deepseek-reasoner"}},
 		{ID: "other", Name: "Other (Custom)", Models: []string{}},
+		{ID: "local", Name: "Local (background removal only)", Models: []string{"auto", "flood-fill", "chroma-key"}},
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
 )
 
@@ -32,8 +33,12 @@ func apiHandler(w http.ResponseWriter, r *http.Request) {
 			if !decodeJSON(w, r, &req) {
 				return
 			}
+			if !validProjectType(req.Type) {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "type must be one of " + projectTypeNames()})
+				return
+			}
 			log.Printf("create project title=%s", req.Title)
-			p := store.CreateProject(req.Title, req.StickerCount)
+			p := store.CreateProject(req.Title, req.StickerCount, req.Type)
 			writeJSON(w, http.StatusOK, p)
 		default:
 			writeStatus(w, http.StatusMethodNotAllowed)
@@ -56,7 +61,11 @@ func apiHandler(w http.ResponseWriter, r *http.Request) {
 			if !decodeJSON(w, r, &req) {
 				return
 			}
-			if p, ok := store.UpdateProjectTheme(projectID, req.Theme); ok {
+			if !validProjectType(req.Type) {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "type must be one of " + projectTypeNames()})
+				return
+			}
+			if p, ok := store.UpdateProject(projectID, req); ok {
 				writeJSON(w, http.StatusOK, p)
 				return
 			}
@@ -69,17 +78,180 @@ func apiHandler(w http.ResponseWriter, r *http.Request) {
 
 	// /projects/{projectId}/character
 	if len(segments) == 3 && segments[0] == "projects" && segments[2] == "character" {
+		if r.Method == http.MethodGet {
+			if c, ok := store.GetProjectCharacter(segments[1]); ok {
+				writeJSON(w, http.StatusOK, c)
+				return
+			}
+			writeStatus(w, http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodPatch {
+			var req CharacterUpdateRequest
+			if !decodeJSON(w, r, &req) {
+				return
+			}
+			if c, ok := store.UpdateProjectCharacter(segments[1], req); ok {
+				writeJSON(w, http.StatusOK, c)
+				return
+			}
+			writeStatus(w, http.StatusNotFound)
+			return
+		}
 		if r.Method == http.MethodPost {
 			var req CharacterCreateRequest
 			if !decodeJSON(w, r, &req) {
 				return
 			}
 			log.Printf("create character project=%s", segments[1])
-			if c, ok := store.CreateCharacter(segments[1], req); ok {
+			c, err := store.CreateCharacter(segments[1], req)
+			if err == errProjectNotFound || err == errLibraryCharacterNotFound {
+				writeStatus(w, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, c)
+			return
+		}
+		writeStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	// /characters
+	if len(segments) == 1 && segments[0] == "characters" {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, store.ListLibraryCharacters())
+		case http.MethodPost:
+			var req CharacterUpdateRequest
+			if !decodeJSON(w, r, &req) {
+				return
+			}
+			log.Printf("create library character name=%s", req.Name)
+			writeJSON(w, http.StatusOK, store.CreateLibraryCharacter(req))
+		default:
+			writeStatus(w, http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	// /characters/{characterId}
+	if len(segments) == 2 && segments[0] == "characters" {
+		switch r.Method {
+		case http.MethodGet:
+			if c, ok := store.GetLibraryCharacter(segments[1]); ok {
 				writeJSON(w, http.StatusOK, c)
 				return
 			}
 			writeStatus(w, http.StatusNotFound)
+		case http.MethodPatch:
+			var req CharacterUpdateRequest
+			if !decodeJSON(w, r, &req) {
+				return
+			}
+			if c, ok := store.UpdateLibraryCharacter(segments[1], req); ok {
+				writeJSON(w, http.StatusOK, c)
+				return
+			}
+			writeStatus(w, http.StatusNotFound)
+		case http.MethodDelete:
+			if store.DeleteLibraryCharacter(segments[1]) {
+				writeStatus(w, http.StatusNoContent)
+				return
+			}
+			writeStatus(w, http.StatusNotFound)
+		default:
+			writeStatus(w, http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	// /characters/{characterId}/versions
+	if len(segments) == 3 && segments[0] == "characters" && segments[2] == "versions" {
+		if r.Method == http.MethodGet {
+			if list, ok := store.ListLibraryCharacterVersions(segments[1]); ok {
+				writeJSON(w, http.StatusOK, list)
+				return
+			}
+			writeStatus(w, http.StatusNotFound)
+			return
+		}
+		writeStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	// /projects/{projectId}/characters
+	if len(segments) == 3 && segments[0] == "projects" && segments[2] == "characters" {
+		switch r.Method {
+		case http.MethodGet:
+			if list, ok := store.ListProjectCharacters(segments[1]); ok {
+				writeJSON(w, http.StatusOK, list)
+				return
+			}
+			writeStatus(w, http.StatusNotFound)
+		case http.MethodPost:
+			var req CharacterCreateRequest
+			if !decodeJSON(w, r, &req) {
+				return
+			}
+			log.Printf("add character project=%s", segments[1])
+			c, err := store.AddProjectCharacter(segments[1], req)
+			if err == errProjectNotFound || err == errLibraryCharacterNotFound {
+				writeStatus(w, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, c)
+		default:
+			writeStatus(w, http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	// /projects/{projectId}/characters/{characterId}
+	if len(segments) == 4 && segments[0] == "projects" && segments[2] == "characters" {
+		switch r.Method {
+		case http.MethodPatch:
+			var req CharacterUpdateRequest
+			if !decodeJSON(w, r, &req) {
+				return
+			}
+			if c, ok := store.UpdateProjectCastCharacter(segments[1], segments[3], req); ok {
+				writeJSON(w, http.StatusOK, c)
+				return
+			}
+			writeStatus(w, http.StatusNotFound)
+		case http.MethodDelete:
+			if store.RemoveProjectCharacter(segments[1], segments[3]) {
+				writeStatus(w, http.StatusNoContent)
+				return
+			}
+			writeStatus(w, http.StatusNotFound)
+		default:
+			writeStatus(w, http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	// /projects/{projectId}/character/reference
+	if len(segments) == 4 && segments[0] == "projects" && segments[2] == "character" && segments[3] == "reference" {
+		if r.Method == http.MethodPost {
+			urls, ok := readUploads(w, r, "file", 0)
+			if !ok {
+				return
+			}
+			if c, ok := store.AddCharacterReferences(segments[1], urls); ok {
+				writeJSON(w, http.StatusOK, c)
+				return
+			}
+			removeAssets(urls)
+			writeStatus(w, http.StatusNotFound)
 			return
 		}
 		writeStatus(w, http.StatusMethodNotAllowed)
@@ -96,6 +268,287 @@ func apiHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// /style-presets
+	if len(segments) == 1 && segments[0] == "style-presets" {
+		if r.Method == http.MethodGet {
+			writeJSON(w, http.StatusOK, ListStylePresets())
+			return
+		}
+		writeStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	// /projects/{projectId}/style
+	if len(segments) == 3 && segments[0] == "projects" && segments[2] == "style" {
+		if r.Method == http.MethodPatch {
+			var req ProjectStyleRequest
+			if !decodeJSON(w, r, &req) {
+				return
+			}
+			if !validStylePreset(req.StylePreset) {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unknown style preset"})
+				return
+			}
+			if p, ok := store.UpdateProjectStyle(segments[1], req); ok {
+				writeJSON(w, http.StatusOK, p)
+				return
+			}
+			writeStatus(w, http.StatusNotFound)
+			return
+		}
+		writeStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	// /projects/{projectId}/post-process
+	if len(segments) == 3 && segments[0] == "projects" && segments[2] == "post-process" {
+		switch r.Method {
+		case http.MethodGet:
+			if cfg, ok := store.GetPostProcess(segments[1]); ok {
+				writeJSON(w, http.StatusOK, cfg)
+				return
+			}
+			writeStatus(w, http.StatusNotFound)
+		case http.MethodPatch:
+			var req PostProcessUpdateRequest
+			if !decodeJSON(w, r, &req) {
+				return
+			}
+			cfg, err := store.UpdatePostProcess(segments[1], req)
+			if err == errProjectNotFound {
+				writeStatus(w, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, cfg)
+		default:
+			writeStatus(w, http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	// /projects/{projectId}/caption-style
+	if len(segments) == 3 && segments[0] == "projects" && segments[2] == "caption-style" {
+		switch r.Method {
+		case http.MethodGet:
+			if st, ok := store.GetCaptionStyle(segments[1]); ok {
+				writeJSON(w, http.StatusOK, st)
+				return
+			}
+			writeStatus(w, http.StatusNotFound)
+		case http.MethodPatch:
+			var req CaptionStyle
+			if !decodeJSON(w, r, &req) {
+				return
+			}
+			st, err := store.UpdateCaptionStyle(segments[1], req)
+			if err == errProjectNotFound {
+				writeStatus(w, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, st)
+		default:
+			writeStatus(w, http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	// /projects/{projectId}/caption-style:preview
+	if len(segments) == 3 && segments[0] == "projects" && segments[2] == "caption-style:preview" {
+		if r.Method == http.MethodPost {
+			var req CaptionPreviewRequest
+			if !decodeOptionalJSON(w, r, &req) {
+				return
+			}
+			data, err := store.PreviewCaptionStyle(segments[1], req)
+			if err == errProjectNotFound || err == errStickerNotFound {
+				writeStatus(w, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			writePNG(w, data)
+			return
+		}
+		writeStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	// /projects/{projectId}/preview.png
+	if len(segments) == 3 && segments[0] == "projects" && segments[2] == "preview.png" {
+		if r.Method == http.MethodGet {
+			data, err := store.ContactSheet(segments[1])
+			if err == errProjectNotFound {
+				writeStatus(w, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			writePNG(w, data)
+			return
+		}
+		writeStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	// /stickers/{stickerId}/caption
+	if len(segments) == 3 && segments[0] == "stickers" && segments[2] == "caption" {
+		if r.Method == http.MethodPatch {
+			var req StickerCaptionRequest
+			if !decodeJSON(w, r, &req) {
+				return
+			}
+			if st, ok := store.SetStickerCaption(segments[1], req); ok {
+				writeJSON(w, http.StatusOK, st)
+				return
+			}
+			writeStatus(w, http.StatusNotFound)
+			return
+		}
+		writeStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	// /stickers/{stickerId}/preview.png?caption=on|off
+	if len(segments) == 3 && segments[0] == "stickers" && segments[2] == "preview.png" {
+		if r.Method == http.MethodGet {
+			var show *bool
+			switch r.URL.Query().Get("caption") {
+			case "on", "1", "true":
+				v := true
+				show = &v
+			case "off", "0", "false":
+				v := false
+				show = &v
+			}
+			data, err := store.StickerPreview(segments[1], show)
+			if err == errStickerNotFound {
+				writeStatus(w, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			writePNG(w, data)
+			return
+		}
+		writeStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	// /fonts
+	if len(segments) == 1 && segments[0] == "fonts" {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, store.ListFonts())
+		case http.MethodPost:
+			data, name, ok := readFontUpload(w, r)
+			if !ok {
+				return
+			}
+			f, err := store.AddFont(name, data)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, f)
+		default:
+			writeStatus(w, http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	// /fonts/{fontId}
+	if len(segments) == 2 && segments[0] == "fonts" {
+		if r.Method == http.MethodDelete {
+			if store.DeleteFont(segments[1]) {
+				writeStatus(w, http.StatusNoContent)
+				return
+			}
+			writeStatus(w, http.StatusNotFound)
+			return
+		}
+		writeStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	// /prompt-templates
+	if len(segments) == 1 && segments[0] == "prompt-templates" {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, store.ListPromptTemplates(promptScopeGlobal))
+		case http.MethodPatch:
+			var req PromptTemplatesUpdateRequest
+			if !decodeJSON(w, r, &req) {
+				return
+			}
+			if err := store.UpdatePromptTemplates(promptScopeGlobal, req); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, store.ListPromptTemplates(promptScopeGlobal))
+		default:
+			writeStatus(w, http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	// /projects/{projectId}/prompt-templates
+	if len(segments) == 3 && segments[0] == "projects" && segments[2] == "prompt-templates" {
+		if _, ok := store.GetProject(segments[1]); !ok {
+			writeStatus(w, http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, store.ListPromptTemplates(segments[1]))
+		case http.MethodPatch:
+			var req PromptTemplatesUpdateRequest
+			if !decodeJSON(w, r, &req) {
+				return
+			}
+			if err := store.UpdatePromptTemplates(segments[1], req); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, store.ListPromptTemplates(segments[1]))
+		default:
+			writeStatus(w, http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	// /prompts:render
+	if len(segments) == 1 && segments[0] == "prompts:render" {
+		if r.Method == http.MethodPost {
+			var req PromptRenderRequest
+			if !decodeJSON(w, r, &req) {
+				return
+			}
+			res, err := store.RenderPrompt(req)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, res)
+			return
+		}
+		writeStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
 	// /projects/{projectId}/ai-config
 	if len(segments) == 3 && segments[0] == "projects" && segments[2] == "ai-config" {
 		if r.Method == http.MethodPatch {
@@ -177,9 +630,15 @@ func apiHandler(w http.ResponseWriter, r *http.Request) {
 	// /projects/{projectId}/theme:suggest
 	if len(segments) == 3 && segments[0] == "projects" && segments[2] == "theme:suggest" {
 		if r.Method == http.MethodPost {
-			writeJSON(w, http.StatusOK, ThemeSuggestResponse{
-				Suggestions: []string{"Office Life", "Commute", "Weekend"},
-			})
+			var req ThemeSuggestRequest
+			if !decodeOptionalJSON(w, r, &req) {
+				return
+			}
+			if res, ok := store.SuggestThemes(segments[1], req); ok {
+				writeJSON(w, http.StatusOK, res)
+				return
+			}
+			writeStatus(w, http.StatusNotFound)
 			return
 		}
 		writeStatus(w, http.StatusMethodNotAllowed)
@@ -217,6 +676,10 @@ func apiHandler(w http.ResponseWriter, r *http.Request) {
 			if !decodeJSON(w, r, &req) {
 				return
 			}
+			if req.StylePreset != nil && !validStylePreset(*req.StylePreset) {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unknown style preset"})
+				return
+			}
 			if d, ok := store.UpdateDraft(segments[1], req); ok {
 				writeJSON(w, http.StatusOK, d)
 				return
@@ -228,6 +691,35 @@ func apiHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// /drafts/{draftId}/candidates
+	if len(segments) == 3 && segments[0] == "drafts" && segments[2] == "candidates" {
+		if r.Method == http.MethodGet {
+			if list, ok := store.ListCandidates(segments[1]); ok {
+				writeJSON(w, http.StatusOK, list)
+				return
+			}
+			writeStatus(w, http.StatusNotFound)
+			return
+		}
+		writeStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	// /drafts/{draftId}/candidates/{candidateId}:select
+	if len(segments) == 4 && segments[0] == "drafts" && segments[2] == "candidates" && strings.HasSuffix(segments[3], ":select") {
+		if r.Method == http.MethodPost {
+			candidateID := strings.TrimSuffix(segments[3], ":select")
+			if st, ok := store.SelectCandidate(segments[1], candidateID); ok {
+				writeJSON(w, http.StatusOK, st)
+				return
+			}
+			writeStatus(w, http.StatusNotFound)
+			return
+		}
+		writeStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
 	// /projects/{projectId}/stickers:generate
 	if len(segments) == 3 && segments[0] == "projects" && segments[2] == "stickers:generate" {
 		if r.Method == http.MethodPost {
@@ -246,10 +738,165 @@ func apiHandler(w http.ResponseWriter, r *http.Request) {
 	if len(segments) == 2 && segments[0] == "stickers" && strings.HasSuffix(segments[1], ":regenerate") {
 		if r.Method == http.MethodPost {
 			stickerID := strings.TrimSuffix(segments[1], ":regenerate")
-			if job, ok := store.RegenerateSticker(stickerID); ok {
-				writeJSON(w, http.StatusOK, job)
+			var req StickerRegenerateRequest
+			if !decodeOptionalJSON(w, r, &req) {
+				return
+			}
+			job, err := store.RegenerateSticker(stickerID, req)
+			if err == errStickerNotFound {
+				writeStatus(w, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, job)
+			return
+		}
+		writeStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	// /stickers/{stickerId}/frames:generate
+	if len(segments) == 3 && segments[0] == "stickers" && segments[2] == "frames:generate" {
+		if r.Method == http.MethodPost {
+			var req FramesGenerateRequest
+			if !decodeOptionalJSON(w, r, &req) {
+				return
+			}
+			job, err := store.GenerateFrames(segments[1], req)
+			if err == errStickerNotFound {
+				writeStatus(w, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, job)
+			return
+		}
+		writeStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	// /stickers/{stickerId}/animation
+	if len(segments) == 3 && segments[0] == "stickers" && segments[2] == "animation" {
+		if r.Method == http.MethodPatch {
+			var req StickerAnimation
+			if !decodeJSON(w, r, &req) {
+				return
+			}
+			st, err := store.UpdateAnimation(segments[1], req)
+			if err == errStickerNotFound {
+				writeStatus(w, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, st)
+			return
+		}
+		writeStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	// /stickers/{stickerId}/animation.png
+	if len(segments) == 3 && segments[0] == "stickers" && segments[2] == "animation.png" {
+		if r.Method == http.MethodGet {
+			data, err := store.AnimationPreview(segments[1])
+			if err == errStickerNotFound {
+				writeStatus(w, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			writePNG(w, data)
+			return
+		}
+		writeStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	// /stickers/{stickerId}/text-area
+	if len(segments) == 3 && segments[0] == "stickers" && segments[2] == "text-area" {
+		if r.Method == http.MethodPatch {
+			var req TextArea
+			if !decodeJSON(w, r, &req) {
+				return
+			}
+			st, err := store.UpdateTextArea(segments[1], req)
+			if err == errStickerNotFound {
+				writeStatus(w, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, st)
+			return
+		}
+		writeStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	// /stickers/{stickerId}/text-area.png?text=...
+	if len(segments) == 3 && segments[0] == "stickers" && segments[2] == "text-area.png" {
+		if r.Method == http.MethodGet {
+			data, err := store.TextAreaPreview(segments[1], r.URL.Query().Get("text"))
+			if err == errStickerNotFound {
+				writeStatus(w, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			writePNG(w, data)
+			return
+		}
+		writeStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	// /stickers/{stickerId}/image
+	if len(segments) == 3 && segments[0] == "stickers" && segments[2] == "image" {
+		if r.Method == http.MethodPost {
+			urls, ok := readUploads(w, r, "file", 1)
+			if !ok {
+				return
+			}
+			if st, ok := store.ReplaceStickerImage(segments[1], urls[0]); ok {
+				writeJSON(w, http.StatusOK, st)
+				return
+			}
+			removeAssets(urls)
+			writeStatus(w, http.StatusNotFound)
+			return
+		}
+		writeStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	// /projects/{projectId}/stickers:upload
+	if len(segments) == 3 && segments[0] == "projects" && segments[2] == "stickers:upload" {
+		if r.Method == http.MethodPost {
+			urls, ok := readUploads(w, r, "file", 0)
+			if !ok {
+				return
+			}
+			list, ok := store.UploadStickers(segments[1], urls, r.MultipartForm.Value["draftId"], r.MultipartForm.Value["caption"])
+			if ok {
+				writeJSON(w, http.StatusOK, list)
 				return
 			}
+			removeAssets(urls)
 			writeStatus(w, http.StatusNotFound)
 			return
 		}
@@ -284,18 +931,62 @@ func apiHandler(w http.ResponseWriter, r *http.Request) {
 	// /projects/{projectId}/export
 	if len(segments) == 3 && segments[0] == "projects" && segments[2] == "export" {
 		if r.Method == http.MethodPost {
-			if res, ok := store.Export(segments[1]); ok {
-				writeJSON(w, http.StatusOK, res)
+			var req ExportRequest
+			if !decodeOptionalJSON(w, r, &req) {
 				return
 			}
-			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "export failed"})
+			res, err := store.Export(segments[1], req)
+			if err == errExportBlocked {
+				writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+					"error":      err.Error() + ": " + res.Validation.summary(),
+					"validation": res.Validation,
+				})
+				return
+			}
+			if err == errProjectNotFound {
+				writeStatus(w, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "export failed: " + err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, res)
 			return
 		}
 		writeStatus(w, http.StatusMethodNotAllowed)
 		return
 	}
 
-	// /exports/{projectId}.zip
+	// /projects/{projectId}/validation?target=line|telegram|whatsapp|discord|slack&format=&packName=&packTitle=&publisher=&names[{stickerId}]=
+	// POST takes the same body as /export instead.
+	if len(segments) == 3 && segments[0] == "projects" && segments[2] == "validation" {
+		var req ExportRequest
+		switch r.Method {
+		case http.MethodGet:
+			req = exportRequestFromQuery(r.URL.Query())
+		case http.MethodPost:
+			if !decodeOptionalJSON(w, r, &req) {
+				return
+			}
+		default:
+			writeStatus(w, http.StatusMethodNotAllowed)
+			return
+		}
+		report, err := store.ValidateExport(segments[1], req)
+		if err == errProjectNotFound {
+			writeStatus(w, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+
+	// /exports/{projectId}.zip, /exports/{projectId}-{target}.zip
 	if len(segments) == 2 && segments[0] == "exports" {
 		if r.Method == http.MethodGet {
 			serveExport(w, r, segments[1])
@@ -305,6 +996,16 @@ func apiHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// /assets/{name}
+	if len(segments) == 2 && segments[0] == "assets" {
+		if r.Method == http.MethodGet {
+			serveAsset(w, r, segments[1])
+			return
+		}
+		writeStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
 	// /jobs/{jobId}
 	if len(segments) == 2 && segments[0] == "jobs" {
 		if r.Method == http.MethodGet {
@@ -356,3 +1057,45 @@ func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
 	}
 	return true
 }
+
+// exportRequestFromQuery reads an ExportRequest from query parameters, with
+// sticker names given as names[{stickerId}]=name.
+func exportRequestFromQuery(q url.Values) ExportRequest {
+	req := ExportRequest{
+		Target:    q.Get("target"),
+		Format:    q.Get("format"),
+		PackName:  q.Get("packName"),
+		PackTitle: q.Get("packTitle"),
+		Publisher: q.Get("publisher"),
+	}
+	for key, values := range q {
+		id, open := strings.CutPrefix(key, "names[")
+		id, closed := strings.CutSuffix(id, "]")
+		if !open || !closed || id == "" || len(values) == 0 {
+			continue
+		}
+		if req.Names == nil {
+			req.Names = map[string]string{}
+		}
+		req.Names[id] = values[0]
+	}
+	return req
+}
+
+// decodeOptionalJSON accepts an empty body for endpoints whose payload is optional.
+func decodeOptionalJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeStatus(w, http.StatusBadRequest)
+		return false
+	}
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return true
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		writeStatus(w, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestExportRequestFromQuery(t *testing.T) {
+	q, err := url.ParseQuery("target=discord&format=webp&packName=cats&packTitle=Cats&publisher=me" +
+		"&names[stk_1]=hello&names%5Bstk_2%5D=good_night&names[]=skipped&names=skipped&names[stk_3=skipped")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ExportRequest{
+		Target:    "discord",
+		Format:    "webp",
+		PackName:  "cats",
+		PackTitle: "Cats",
+		Publisher: "me",
+		Names:     map[string]string{"stk_1": "hello", "stk_2": "good_night"},
+	}
+	if got := exportRequestFromQuery(q); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if got := exportRequestFromQuery(url.Values{"target": {"line"}}); got.Names != nil {
+		t.Fatalf("names %v, want nil without names parameters", got.Names)
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -52,7 +53,39 @@ func (s *Store) migrate() {
 			project_id TEXT,
 			source_type TEXT,
 			reference_image_url TEXT,
-			status TEXT
+			status TEXT,
+			name TEXT,
+			prompt TEXT,
+			personality TEXT,
+			palette TEXT,
+			style_notes TEXT,
+			reference_images TEXT,
+			library_id TEXT,
+			library_version INTEGER DEFAULT 0
+		);`,
+		`CREATE TABLE IF NOT EXISTS library_characters (
+			id TEXT PRIMARY KEY,
+			latest_version INTEGER,
+			created_at TEXT,
+			updated_at TEXT
+		);`,
+		`CREATE TABLE IF NOT EXISTS library_character_versions (
+			character_id TEXT,
+			version INTEGER,
+			name TEXT,
+			prompt TEXT,
+			personality TEXT,
+			palette TEXT,
+			style_notes TEXT,
+			reference_images TEXT,
+			created_at TEXT,
+			PRIMARY KEY (character_id, version)
+		);`,
+		`CREATE TABLE IF NOT EXISTS project_characters (
+			project_id TEXT,
+			character_id TEXT,
+			position INTEGER,
+			PRIMARY KEY (project_id, character_id)
 		);`,
 		`CREATE TABLE IF NOT EXISTS drafts (
 			id TEXT PRIMARY KEY,
@@ -60,7 +93,8 @@ func (s *Store) migrate() {
 			idx INTEGER,
 			caption TEXT,
 			image_prompt TEXT,
-			status TEXT
+			status TEXT,
+			character_ids TEXT
 		);`,
 		`CREATE TABLE IF NOT EXISTS stickers (
 			id TEXT PRIMARY KEY,
@@ -68,7 +102,31 @@ func (s *Store) migrate() {
 			draft_id TEXT,
 			image_url TEXT,
 			transparent_url TEXT,
-			status TEXT
+			status TEXT,
+			created_at TEXT,
+			source TEXT
+		);`,
+		`CREATE TABLE IF NOT EXISTS candidates (
+			id TEXT PRIMARY KEY,
+			project_id TEXT,
+			draft_id TEXT,
+			image_url TEXT,
+			selected INTEGER,
+			created_at TEXT
+		);`,
+		`CREATE TABLE IF NOT EXISTS fonts (
+			id TEXT PRIMARY KEY,
+			name TEXT,
+			family TEXT,
+			path TEXT,
+			created_at TEXT
+		);`,
+		`CREATE TABLE IF NOT EXISTS prompt_templates (
+			scope TEXT,
+			kind TEXT,
+			body TEXT,
+			updated_at TEXT,
+			PRIMARY KEY (scope, kind)
 		);`,
 		`CREATE TABLE IF NOT EXISTS jobs (
 			id TEXT PRIMARY KEY,
@@ -95,41 +153,104 @@ func (s *Store) migrate() {
 	s.ensureColumn("projects", "image_model", "TEXT")
 	s.ensureColumn("projects", "bg_provider", "TEXT")
 	s.ensureColumn("projects", "bg_model", "TEXT")
+	s.ensureColumn("projects", "candidate_count", "INTEGER DEFAULT 1")
+	s.ensureColumn("projects", "style_preset", "TEXT")
+	s.ensureColumn("projects", "locale", "TEXT")
+	s.ensureColumn("stickers", "created_at", "TEXT")
+	s.ensureColumn("characters", "name", "TEXT")
+	s.ensureColumn("characters", "prompt", "TEXT")
+	s.ensureColumn("characters", "personality", "TEXT")
+	s.ensureColumn("characters", "palette", "TEXT")
+	s.ensureColumn("characters", "style_notes", "TEXT")
+	s.ensureColumn("characters", "reference_images", "TEXT")
+	s.ensureColumn("characters", "library_id", "TEXT")
+	s.ensureColumn("characters", "library_version", "INTEGER DEFAULT 0")
+	s.ensureColumn("drafts", "character_ids", "TEXT")
+	s.ensureColumn("drafts", "style_preset", "TEXT")
+	s.ensureColumn("stickers", "source", "TEXT")
+	s.ensureColumn("stickers", "generation", "TEXT")
+	s.ensureColumn("candidates", "generation", "TEXT")
+	s.ensureColumn("stickers", "show_caption", "INTEGER")
+	s.ensureColumn("projects", "caption_style", "TEXT")
+	s.ensureColumn("projects", "post_process", "TEXT")
+	s.ensureColumn("projects", "type", "TEXT")
+	s.ensureColumn("stickers", "animation", "TEXT")
+	s.ensureColumn("stickers", "text_area", "TEXT")
+	s.ensureColumn("drafts", "emoji", "TEXT")
+}
+
+const stickerColumns = `id,project_id,draft_id,image_url,transparent_url,status,COALESCE(created_at,''),COALESCE(source,'AI'),COALESCE(generation,''),show_caption,COALESCE(animation,''),COALESCE(text_area,'')`
+
+func scanSticker(row rowScanner) (*Sticker, error) {
+	st := &Sticker{}
+	var generation string
+	var showCaption sql.NullBool
+	var animation, textArea string
+	if err := row.Scan(&st.ID, &st.ProjectID, &st.DraftID, &st.ImageURL, &st.TransparentURL, &st.Status, &st.CreatedAt, &st.Source, &generation, &showCaption, &animation, &textArea); err != nil {
+		return nil, err
+	}
+	st.Generation = decodeGeneration(generation)
+	st.Animation = decodeAnimation(animation)
+	st.TextArea = decodeTextArea(textArea)
+	if showCaption.Valid {
+		st.ShowCaption = &showCaption.Bool
+	}
+	return st, nil
 }
 
 func newID(prefix string) string {
 	return fmt.Sprintf("%s_%d", prefix, time.Now().UnixNano())
 }
 
-func (s *Store) CreateProject(title string, stickerCount int) *Project {
+// nowStamp returns a fixed-width UTC timestamp so rows sort correctly as text.
+func nowStamp() string {
+	return time.Now().UTC().Format("2006-01-02T15:04:05.000000Z")
+}
+
+func (s *Store) CreateProject(title string, stickerCount int, projectType string) *Project {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	id := newID("proj")
+	if projectType == "" {
+		projectType = projectTypeSticker
+	}
 	_, _ = s.db.Exec(
-		`INSERT INTO projects (id,title,theme,sticker_count,status,character_id,ai_provider,ai_model,text_provider,text_model,image_provider,image_model,bg_provider,bg_model)
-		 VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
-		id, title, "", stickerCount, "DRAFT", "", "", "", "", "", "", "", "", "",
+		`INSERT INTO projects (id,title,theme,sticker_count,status,character_id,ai_provider,ai_model,text_provider,text_model,image_provider,image_model,bg_provider,bg_model,type)
+		 VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+		id, title, "", stickerCount, "DRAFT", "", "", "", "", "", "", "", "", "", projectType,
 	)
-	return &Project{ID: id, Title: title, StickerCount: stickerCount, Status: "DRAFT"}
+	return &Project{ID: id, Title: title, StickerCount: stickerCount, Status: "DRAFT", CandidateCount: 1, Type: projectType}
 }
 
-func (s *Store) UpdateProjectTheme(projectID string, theme string) (*Project, bool) {
+func (s *Store) UpdateProject(projectID string, req ProjectUpdateRequest) (*Project, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	res, _ := s.db.Exec(`UPDATE projects SET theme=? WHERE id=?`, theme, projectID)
+	res, _ := s.db.Exec(`UPDATE projects SET theme=COALESCE(NULLIF(?,''),theme), locale=COALESCE(NULLIF(?,''),locale), type=COALESCE(NULLIF(?,''),type) WHERE id=?`, req.Theme, req.Locale, req.Type, projectID)
 	aff, _ := res.RowsAffected()
 	if aff == 0 {
 		return nil, false
 	}
-	return s.GetProject(projectID)
+	return s.getProject(projectID)
+}
+
+const projectColumns = `id,title,theme,sticker_count,status,character_id,ai_provider,ai_model,text_provider,text_model,image_provider,image_model,bg_provider,bg_model,COALESCE(candidate_count,1),COALESCE(style_preset,''),COALESCE(locale,''),COALESCE(NULLIF(type,''),'sticker')`
+
+func scanProject(row rowScanner) (*Project, error) {
+	p := &Project{}
+	err := row.Scan(&p.ID, &p.Title, &p.Theme, &p.StickerCount, &p.Status, &p.CharacterID, &p.AIProvider, &p.AIModel, &p.TextProvider, &p.TextModel, &p.ImageProvider, &p.ImageModel, &p.BgProvider, &p.BgModel, &p.CandidateCount, &p.StylePreset, &p.Locale, &p.Type)
+	return p, err
 }
 
 func (s *Store) GetProject(projectID string) (*Project, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	row := s.db.QueryRow(`SELECT id,title,theme,sticker_count,status,character_id,ai_provider,ai_model,text_provider,text_model,image_provider,image_model,bg_provider,bg_model FROM projects WHERE id=?`, projectID)
-	p := &Project{}
-	if err := row.Scan(&p.ID, &p.Title, &p.Theme, &p.StickerCount, &p.Status, &p.CharacterID, &p.AIProvider, &p.AIModel, &p.TextProvider, &p.TextModel, &p.ImageProvider, &p.ImageModel, &p.BgProvider, &p.BgModel); err != nil {
+	return s.getProject(projectID)
+}
+
+// getProject expects the caller to hold s.mu.
+func (s *Store) getProject(projectID string) (*Project, bool) {
+	p, err := scanProject(s.db.QueryRow(`SELECT `+projectColumns+` FROM projects WHERE id=?`, projectID))
+	if err != nil {
 		return nil, false
 	}
 	return p, true
@@ -138,43 +259,37 @@ func (s *Store) GetProject(projectID string) (*Project, bool) {
 func (s *Store) ListProjects() []*Project {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	rows, _ := s.db.Query(`SELECT id,title,theme,sticker_count,status,character_id,ai_provider,ai_model,text_provider,text_model,image_provider,image_model,bg_provider,bg_model FROM projects`)
+	rows, _ := s.db.Query(`SELECT ` + projectColumns + ` FROM projects`)
 	defer rows.Close()
 	out := []*Project{}
 	for rows.Next() {
-		p := &Project{}
-		_ = rows.Scan(&p.ID, &p.Title, &p.Theme, &p.StickerCount, &p.Status, &p.CharacterID, &p.AIProvider, &p.AIModel, &p.TextProvider, &p.TextModel, &p.ImageProvider, &p.ImageModel, &p.BgProvider, &p.BgModel)
-		out = append(out, p)
+		if p, err := scanProject(rows); err == nil {
+			out = append(out, p)
+		}
 	}
 	return out
 }
 
-func (s *Store) CreateCharacter(projectID string, req CharacterCreateRequest) (*Character, bool) {
+func (s *Store) CreateCharacter(projectID string, req CharacterCreateRequest) (*Character, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	row := s.db.QueryRow(`SELECT id FROM projects WHERE id=?`, projectID)
 	var pid string
 	if err := row.Scan(&pid); err != nil {
-		return nil, false
+		return nil, errProjectNotFound
 	}
-	id := newID("char")
-	c := &Character{
-		ID:                id,
-		SourceType:        req.SourceType,
-		ReferenceImageURL: req.ReferenceImageURL,
-		Status:            "READY",
+	c, err := s.insertCharacter(projectID, req)
+	if err != nil {
+		return nil, err
 	}
-	_, _ = s.db.Exec(`INSERT INTO characters (id,project_id,source_type,reference_image_url,status) VALUES (?,?,?,?,?)`,
-		c.ID, projectID, c.SourceType, c.ReferenceImageURL, c.Status,
-	)
-	_, _ = s.db.Exec(`UPDATE projects SET character_id=? WHERE id=?`, c.ID, projectID)
-	return c, true
+	s.setPrimaryCharacter(projectID, c.ID)
+	return c, nil
 }
 
 func (s *Store) GenerateDrafts(projectID string) (*Job, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	p, ok := s.GetProject(projectID)
+	p, ok := s.getProject(projectID)
 	if !ok {
 		return nil, false
 	}
@@ -184,7 +299,13 @@ func (s *Store) GenerateDrafts(projectID string) (*Job, bool) {
 	fbProvider, fbModel := p.AIProvider, p.AIModel
 	textProvider, textModel := resolveProviderModel(p.TextProvider, p.TextModel, fbProvider, fbModel)
 	pipeline, _ := s.getTaskPipeline(projectID, textProvider, textModel)
-	ideas, _ := pipeline.GenerateDrafts(p.Theme, p.StickerCount, charInput)
+	ideas, _ := pipeline.GenerateDrafts(ai.DraftRequest{
+		Theme:    p.Theme,
+		Count:    p.StickerCount,
+		Locale:   p.Locale,
+		Style:    stylePresetName(p.StylePreset),
+		Template: s.getPromptTemplate(projectID, promptKindDrafts).Body,
+	}, charInput)
 	for i := 1; i <= p.StickerCount; i++ {
 		id := newID("draft")
 		caption := fmt.Sprintf("草稿 %d", i)
@@ -207,13 +328,13 @@ func (s *Store) GenerateDrafts(projectID string) (*Job, bool) {
 func (s *Store) ListDrafts(projectID string) []*Draft {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	rows, _ := s.db.Query(`SELECT id,project_id,idx,caption,image_prompt,status FROM drafts WHERE project_id=? ORDER BY idx`, projectID)
+	rows, _ := s.db.Query(`SELECT `+draftColumns+` FROM drafts WHERE project_id=? ORDER BY idx`, projectID)
 	defer rows.Close()
 	out := []*Draft{}
 	for rows.Next() {
-		d := &Draft{}
-		_ = rows.Scan(&d.ID, &d.ProjectID, &d.Index, &d.Caption, &d.ImagePrompt, &d.Status)
-		out = append(out, d)
+		if d, err := scanDraft(rows); err == nil {
+			out = append(out, d)
+		}
 	}
 	return out
 }
@@ -224,9 +345,17 @@ func (s *Store) UpdateDraft(draftID string, req DraftUpdateRequest) (*Draft, boo
 	_, _ = s.db.Exec(`UPDATE drafts SET caption=COALESCE(NULLIF(?,''),caption), image_prompt=COALESCE(NULLIF(?,''),image_prompt) WHERE id=?`,
 		req.Caption, req.ImagePrompt, draftID,
 	)
-	row := s.db.QueryRow(`SELECT id,project_id,idx,caption,image_prompt,status FROM drafts WHERE id=?`, draftID)
-	d := &Draft{}
-	if err := row.Scan(&d.ID, &d.ProjectID, &d.Index, &d.Caption, &d.ImagePrompt, &d.Status); err != nil {
+	if req.CharacterIDs != nil {
+		_, _ = s.db.Exec(`UPDATE drafts SET character_ids=? WHERE id=?`, encodeList(req.CharacterIDs), draftID)
+	}
+	if req.StylePreset != nil {
+		_, _ = s.db.Exec(`UPDATE drafts SET style_preset=? WHERE id=?`, *req.StylePreset, draftID)
+	}
+	if req.Emoji != nil {
+		_, _ = s.db.Exec(`UPDATE drafts SET emoji=? WHERE id=?`, encodeList(cleanEmoji(req.Emoji)), draftID)
+	}
+	d, err := scanDraft(s.db.QueryRow(`SELECT `+draftColumns+` FROM drafts WHERE id=?`, draftID))
+	if err != nil {
 		return nil, false
 	}
 	return d, true
@@ -235,24 +364,37 @@ func (s *Store) UpdateDraft(draftID string, req DraftUpdateRequest) (*Draft, boo
 func (s *Store) GenerateStickers(projectID string) (*Job, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	p, ok := s.GetProject(projectID)
+	p, ok := s.getProject(projectID)
 	if !ok {
 		return nil, false
 	}
 	_, _ = s.db.Exec(`UPDATE projects SET status=? WHERE id=?`, "GENERATING_IMAGES", projectID)
-	rows, _ := s.db.Query(`SELECT id,image_prompt FROM drafts WHERE project_id=?`, projectID)
+	rows, _ := s.db.Query(`SELECT `+draftColumns+` FROM drafts WHERE project_id=? ORDER BY idx`, projectID)
 	defer rows.Close()
-	charInput := s.getCharacterInput(projectID)
 	fbProvider, fbModel := p.AIProvider, p.AIModel
 	imageProvider, imageModel := resolveProviderModel(p.ImageProvider, p.ImageModel, fbProvider, fbModel)
 	pipeline, _ := s.getTaskPipeline(projectID, imageProvider, imageModel)
+	drafts := []*Draft{}
 	for rows.Next() {
-		var draftID, prompt string
-		_ = rows.Scan(&draftID, &prompt)
-		imageURL, _ := pipeline.GenerateImage(prompt, charInput)
+		if d, err := scanDraft(rows); err == nil {
+			drafts = append(drafts, d)
+		}
+	}
+	for _, d := range drafts {
+		charInput := s.getCastInput(projectID, d.CharacterIDs)
+		req := s.draftImageRequest(p, d, imageProvider)
+		req.N = p.CandidateCount
+		images, _ := pipeline.GenerateImages(req, charInput)
+		first := ai.GeneratedImage{}
+		for i, img := range images {
+			s.insertCandidate(projectID, d.ID, img, i == 0)
+			if i == 0 {
+				first = img
+			}
+		}
 		id := newID("stk")
-		_, _ = s.db.Exec(`INSERT INTO stickers (id,project_id,draft_id,image_url,transparent_url,status) VALUES (?,?,?,?,?,?)`,
-			id, projectID, draftID, imageURL, "", "READY",
+		_, _ = s.db.Exec(`INSERT INTO stickers (id,project_id,draft_id,image_url,transparent_url,status,created_at,source,generation) VALUES (?,?,?,?,?,?,?,?,?)`,
+			id, projectID, d.ID, first.URL, "", "READY", nowStamp(), "AI", encodeGeneration(first),
 		)
 	}
 	_, _ = s.db.Exec(`UPDATE projects SET status=? WHERE id=?`, "IMAGES_READY", projectID)
@@ -265,13 +407,13 @@ func (s *Store) GenerateStickers(projectID string) (*Job, bool) {
 func (s *Store) ListStickers(projectID string) []*Sticker {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	rows, _ := s.db.Query(`SELECT id,project_id,draft_id,image_url,transparent_url,status FROM stickers WHERE project_id=?`, projectID)
+	rows, _ := s.db.Query(`SELECT `+stickerColumns+` FROM stickers WHERE project_id=? ORDER BY created_at`, projectID)
 	defer rows.Close()
 	out := []*Sticker{}
 	for rows.Next() {
-		st := &Sticker{}
-		_ = rows.Scan(&st.ID, &st.ProjectID, &st.DraftID, &st.ImageURL, &st.TransparentURL, &st.Status)
-		out = append(out, st)
+		if st, err := scanSticker(rows); err == nil {
+			out = append(out, st)
+		}
 	}
 	return out
 }
@@ -279,24 +421,40 @@ func (s *Store) ListStickers(projectID string) []*Sticker {
 func (s *Store) RemoveBackground(projectID string) (*Job, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	p, ok := s.GetProject(projectID)
+	p, ok := s.getProject(projectID)
 	if !ok {
 		return nil, false
 	}
-	rows, _ := s.db.Query(`SELECT id,image_url FROM stickers WHERE project_id=?`, projectID)
+	rows, _ := s.db.Query(`SELECT id,image_url,COALESCE(text_area,'') FROM stickers WHERE project_id=?`, projectID)
 	defer rows.Close()
 	fbProvider, fbModel := p.AIProvider, p.AIModel
 	bgProvider, bgModel := resolveProviderModel(p.BgProvider, p.BgModel, fbProvider, fbModel)
 	pipeline, _ := s.getTaskPipeline(projectID, bgProvider, bgModel)
+	post := s.getPostProcess(projectID)
+	spec := specFor(p.Type)
+	canvas := spec.Item
+	// read everything first: sqlite rejects the updates below while rows is open
+	type stickerImage struct{ id, imageURL, textArea string }
+	list := []stickerImage{}
 	for rows.Next() {
-		var id, imageURL string
-		_ = rows.Scan(&id, &imageURL)
+		var st stickerImage
+		_ = rows.Scan(&st.id, &st.imageURL, &st.textArea)
+		list = append(list, st)
+	}
+	rows.Close()
+	for _, st := range list {
+		id, imageURL := st.id, st.imageURL
+		// message stickers are laid out beside their text area, not over it
+		layout := subjectArea(textAreaFor(&Sticker{TextArea: decodeTextArea(st.textArea)}, spec), canvas.width, canvas.height)
 		// NOTE: keep subject intact when removing background
-		transparentURL, _ := pipeline.RemoveBackground(imageURL)
+		transparentURL, _ := pipeline.RemoveBackground(providerImageURL(imageURL))
 		if transparentURL == "" {
 			transparentURL = imageURL
 		}
-		if normalized, err := normalizeStickerImage(transparentURL); err == nil {
+		if processed, err := postProcessSticker(transparentURL, post, layout.Dx(), layout.Dy()); err == nil {
+			transparentURL = processed
+		}
+		if normalized, err := normalizeImageInArea(transparentURL, layout, canvas.width, canvas.height); err == nil {
 			transparentURL = normalized
 		}
 		_, _ = s.db.Exec(`UPDATE stickers SET transparent_url=? WHERE id=?`, transparentURL, id)
@@ -307,53 +465,215 @@ func (s *Store) RemoveBackground(projectID string) (*Job, bool) {
 	return job, true
 }
 
-func (s *Store) Export(projectID string) (*ExportResponse, bool) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	rows, _ := s.db.Query(`SELECT id,project_id,draft_id,image_url,transparent_url,created_at FROM stickers WHERE project_id=?`, projectID)
+// exportPackage builds and validates a project's ZIP for the requested
+// target. Caller holds s.mu.
+func (s *Store) exportPackage(projectID string, req ExportRequest) ([]byte, *ValidationReport, error) {
+	p, ok := s.getProject(projectID)
+	if !ok {
+		return nil, nil, errProjectNotFound
+	}
+	list, err := s.projectStickers(projectID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(list) == 0 {
+		return nil, nil, errors.New("no stickers")
+	}
+	notes := s.captionFontFindings(projectID, list, req.Target == exportTargetLine)
+	s.applyCaptions(projectID, list)
+	switch req.Target {
+	case exportTargetLine:
+		sheet, err := s.contactSheet(projectID, list)
+		if err != nil {
+			return nil, nil, err
+		}
+		return buildExportZip(projectID, specFor(p.Type), list, sheet, notes)
+	case exportTargetTelegram:
+		pack, err := telegramPackFor(p, req)
+		if err != nil {
+			return nil, nil, err
+		}
+		return buildTelegramZip(projectID, pack, list, s.draftEmoji(projectID), notes)
+	case exportTargetWhatsApp:
+		return buildWhatsAppZip(projectID, whatsappPackFor(p, req), list, s.draftEmoji(projectID), notes)
+	case exportTargetDiscord, exportTargetSlack:
+		return buildCustomZip(projectID, req.Target, specFor(p.Type), list, s.draftCaptions(projectID), s.draftEmoji(projectID), req.Names, notes)
+	}
+	return nil, nil, errors.New("target must be line, telegram, whatsapp, discord or slack")
+}
+
+// draftCaptions maps a project's draft IDs to their captions. Caller holds
+// s.mu.
+func (s *Store) draftCaptions(projectID string) map[string]string {
+	out := map[string]string{}
+	rows, err := s.db.Query(`SELECT id,caption FROM drafts WHERE project_id=?`, projectID)
+	if err != nil {
+		return out
+	}
 	defer rows.Close()
+	for rows.Next() {
+		var id, caption string
+		if rows.Scan(&id, &caption) == nil {
+			out[id] = caption
+		}
+	}
+	return out
+}
+
+// projectStickers lists a project's stickers. A failed query is returned
+// rather than read as an empty set. Caller holds s.mu.
+func (s *Store) projectStickers(projectID string) ([]Sticker, error) {
 	list := []Sticker{}
+	rows, err := s.db.Query(`SELECT `+stickerColumns+` FROM stickers WHERE project_id=?`, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("list stickers: %w", err)
+	}
+	defer rows.Close()
 	for rows.Next() {
-		var st Sticker
-		_ = rows.Scan(&st.ID, &st.ProjectID, &st.DraftID, &st.ImageURL, &st.TransparentURL, &st.CreatedAt)
-		list = append(list, st)
+		if st, err := scanSticker(rows); err == nil {
+			list = append(list, *st)
+		}
 	}
-	if len(list) == 0 {
-		return nil, false
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list stickers: %w", err)
 	}
-	zipPath, warnings, err := buildExportZip(projectID, list)
+	return list, nil
+}
+
+// draftEmoji maps a project's draft IDs to their emoji. Caller holds s.mu.
+func (s *Store) draftEmoji(projectID string) map[string][]string {
+	out := map[string][]string{}
+	rows, err := s.db.Query(`SELECT id,COALESCE(emoji,'') FROM drafts WHERE project_id=?`, projectID)
 	if err != nil {
-		return nil, false
+		return out
 	}
-	if zipPath == "" {
-		return nil, false
+	defer rows.Close()
+	for rows.Next() {
+		var id, emoji string
+		if rows.Scan(&id, &emoji) == nil {
+			out[id] = decodeList(emoji)
+		}
+	}
+	return out
+}
+
+func withExportDefaults(req ExportRequest) ExportRequest {
+	if req.Target == "" {
+		req.Target = exportTargetLine
+	}
+	return req
+}
+
+// Export publishes the ZIP unless validation found errors, in which case
+// the report comes back with errExportBlocked.
+func (s *Store) Export(projectID string, req ExportRequest) (*ExportResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	req = withExportDefaults(req)
+	data, report, err := s.exportPackage(projectID, req)
+	if err != nil {
+		return nil, err
+	}
+	if !report.Valid {
+		return &ExportResponse{Target: req.Target, Validation: report}, errExportBlocked
+	}
+	name := exportName(projectID, req.Target)
+	if err := writeExportZip(name, data); err != nil {
+		return nil, err
 	}
 	_, _ = s.db.Exec(`UPDATE projects SET status=? WHERE id=?`, "DONE", projectID)
-	return &ExportResponse{DownloadURL: "/api/v1/exports/" + projectID + ".zip", Warnings: warnings}, true
+	return &ExportResponse{
+		Target:      req.Target,
+		DownloadURL: "/api/v1/exports/" + name,
+		Warnings:    report.messages(severityWarning),
+		Validation:  report,
+	}, nil
 }
 
-func (s *Store) RegenerateSticker(stickerID string) (*Job, bool) {
+// ValidateExport runs the export checks without publishing anything.
+func (s *Store) ValidateExport(projectID string, req ExportRequest) (*ValidationReport, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	row := s.db.QueryRow(`SELECT id,project_id,draft_id FROM stickers WHERE id=?`, stickerID)
-	var id, projectID, draftID string
-	if err := row.Scan(&id, &projectID, &draftID); err != nil {
-		return nil, false
+	_, report, err := s.exportPackage(projectID, withExportDefaults(req))
+	return report, err
+}
+
+const (
+	regenerateSameSeed = "same-seed"
+	regenerateNewSeed  = "new-seed"
+)
+
+var (
+	errProjectNotFound = errors.New("project not found")
+	errStickerNotFound = errors.New("sticker not found")
+	errExportBlocked   = errors.New("export blocked by validation errors")
+)
+
+// RegenerateSticker makes a new image for the sticker's draft. The
+// "same-seed" and "new-seed" modes replay the recorded generation on the
+// provider and model that made it.
+func (s *Store) RegenerateSticker(stickerID string, req StickerRegenerateRequest) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.getSticker(stickerID)
+	if !ok {
+		return nil, errStickerNotFound
 	}
-	promptRow := s.db.QueryRow(`SELECT image_prompt FROM drafts WHERE id=?`, draftID)
-	var prompt string
-	_ = promptRow.Scan(&prompt)
-	charInput := s.getCharacterInput(projectID)
-	p, _ := s.GetProject(projectID)
+	projectID, draftID := st.ProjectID, st.DraftID
+	d, err := scanDraft(s.db.QueryRow(`SELECT `+draftColumns+` FROM drafts WHERE id=?`, draftID))
+	if err != nil {
+		d = &Draft{ID: draftID, ProjectID: projectID}
+	}
+	charInput := s.getCastInput(projectID, d.CharacterIDs)
+	p, _ := s.getProject(projectID)
 	fbProvider, fbModel := p.AIProvider, p.AIModel
 	imageProvider, imageModel := resolveProviderModel(p.ImageProvider, p.ImageModel, fbProvider, fbModel)
+	imgReq := s.draftImageRequest(p, d, imageProvider)
+	if req.Mode != "" {
+		prev := st.Generation
+		if prev == nil {
+			return nil, errors.New("sticker has no generation record")
+		}
+		if prev.Provider != "" && prev.Provider != "mock" {
+			imageProvider, imageModel = prev.Provider, prev.Model
+		}
+		switch req.Mode {
+		case regenerateSameSeed:
+			if prev.Seed == 0 {
+				return nil, errors.New("provider did not record a seed")
+			}
+			imgReq.Seed = prev.Seed
+			imgReq.NegativePrompt = prev.NegativePrompt
+			imgReq.Params = prev.Params
+			if req.Prompt != "" {
+				imgReq = verbatimImageRequest(imgReq, req.Prompt)
+			}
+		case regenerateNewSeed:
+			imgReq = verbatimImageRequest(imgReq, prev.Prompt)
+			imgReq.NegativePrompt = prev.NegativePrompt
+			imgReq.Params = prev.Params
+		default:
+			return nil, errors.New("mode must be same-seed or new-seed")
+		}
+	}
 	pipeline, _ := s.getTaskPipeline(projectID, imageProvider, imageModel)
-	imageURL, _ := pipeline.GenerateImage(prompt, charInput)
-	_, _ = s.db.Exec(`UPDATE stickers SET image_url=?, status=? WHERE id=?`, imageURL, "READY", stickerID)
+	imgReq.N = 1
 	job := s.newJob("GENERATE_IMAGE", projectID, stickerID)
+	images, err := pipeline.GenerateImages(imgReq, charInput)
+	if err == nil && (len(images) == 0 || images[0].URL == "") {
+		err = errors.New("provider returned no image")
+	}
+	if err != nil {
+		// keep the current image and its seed
+		s.failJob(job, err)
+		return job, nil
+	}
+	img := images[0]
+	s.insertCandidate(projectID, draftID, img, true)
+	_, _ = s.db.Exec(`UPDATE stickers SET image_url=?, transparent_url=?, status=?, source=?, generation=? WHERE id=?`, img.URL, "", "READY", "AI", encodeGeneration(img), stickerID)
 	// regenerate finished
 	s.setJobProgress(job.ID, 100, "SUCCESS")
-	return job, true
+	return job, nil
 }
 
 func (s *Store) GetJob(jobID string) (*Job, bool) {
@@ -379,3 +699,9 @@ func (s *Store) newJob(jobType string, projectID string, targetID string) *Job {
 func (s *Store) setJobProgress(jobID string, progress int, status string) {
 	_, _ = s.db.Exec(`UPDATE jobs SET progress=?, status=? WHERE id=?`, progress, status, jobID)
 }
+
+// failJob ends a job as FAILED with the error for the client to show.
+func (s *Store) failJob(j *Job, err error) {
+	j.Status, j.Progress, j.ErrorMessage = "FAILED", 100, err.Error()
+	_, _ = s.db.Exec(`UPDATE jobs SET progress=?, status=?, error_message=? WHERE id=?`, j.Progress, j.Status, j.ErrorMessage, j.ID)
+}
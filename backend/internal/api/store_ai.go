@@ -8,5 +8,5 @@ func (s *Store) UpdateProjectAI(projectID string, req AIConfigUpdateRequest) (*P
 	if aff == 0 {
 		return nil, false
 	}
-	return s.GetProject(projectID)
+	return s.getProject(projectID)
 }
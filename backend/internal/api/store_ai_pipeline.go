@@ -4,7 +4,7 @@ import "example.com/app/internal/ai"
 
 func (s *Store) getPipeline(projectID string) (ai.Pipeline, error) {
 	provider, model := s.getProjectAI(projectID)
-	cred, ok := s.GetAICredentials(projectID)
+	cred, ok := s.getAICredentials(projectID)
 	if !ok {
 		return ai.BYOKPipeline{}, aiErr("missing credentials")
 	}
@@ -9,8 +9,8 @@ type TaskPipeline struct {
 }
 
 func (s *Store) getTaskPipeline(projectID string, provider string, model string) (ai.BYOKPipeline, error) {
-	cred, ok := s.GetAICredentials(projectID)
-	if !ok {
+	cred, ok := s.getAICredentials(projectID)
+	if !ok && !ai.Keyless(provider) {
 		return ai.BYOKPipeline{}, aiErr("missing credentials")
 	}
 	p := ai.BYOKPipeline{
@@ -4,7 +4,7 @@ package api
 func (s *Store) StoreAICredentials(projectID string, req AICredentialsRequest) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if _, ok := s.GetProject(projectID); !ok {
+	if _, ok := s.getProject(projectID); !ok {
 		return false
 	}
 	s.aiSecrets[projectID] = req
@@ -36,6 +36,11 @@ func (s *Store) ListVerifiedProviders(projectID string) []Provider {
 func (s *Store) GetAICredentials(projectID string) (AICredentialsRequest, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	return s.getAICredentials(projectID)
+}
+
+// getAICredentials expects the caller to hold s.mu.
+func (s *Store) getAICredentials(projectID string) (AICredentialsRequest, bool) {
 	v, ok := s.aiSecrets[projectID]
 	return v, ok
 }
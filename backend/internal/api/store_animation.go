@@ -0,0 +1,376 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"strings"
+
+	"example.com/app/internal/ai"
+)
+
+// animationLimits bound an APNG's frame count, loop count and total
+// playback time including loops.
+type animationLimits struct {
+	minFrames, maxFrames int
+	maxLoops             int
+	maxPlaybackMs        int
+}
+
+// lineAnimation is the LINE animated sticker limit: 5-20 frames, 1-4 loops
+// and at most 4 seconds of playback.
+var lineAnimation = animationLimits{minFrames: 5, maxFrames: 20, maxLoops: 4, maxPlaybackMs: 4000}
+
+const (
+	defaultFrames     = 8
+	defaultDurationMs = 1000
+	defaultLoops      = 2
+	defaultMotion     = "a subtle looping idle motion"
+
+	framesProvider    = "provider"
+	framesInterpolate = "interpolate"
+)
+
+func encodeAnimation(anim *StickerAnimation) string {
+	if anim == nil {
+		return ""
+	}
+	b, _ := json.Marshal(anim)
+	return string(b)
+}
+
+func decodeAnimation(v string) *StickerAnimation {
+	if v == "" {
+		return nil
+	}
+	anim := &StickerAnimation{}
+	if err := json.Unmarshal([]byte(v), anim); err != nil {
+		return nil
+	}
+	return anim
+}
+
+func validateAnimation(anim StickerAnimation, limits animationLimits) error {
+	delays := make([]int, len(anim.Frames))
+	for i, f := range anim.Frames {
+		if f.ImageURL == "" {
+			return errors.New("every frame needs an imageUrl")
+		}
+		if err := checkLocalImageURL(f.ImageURL); err != nil {
+			return fmt.Errorf("frame %d: %w", i+1, err)
+		}
+		delays[i] = f.DelayMs
+	}
+	return validateTiming(delays, anim.Loops, limits)
+}
+
+func validateFrameCount(n int, limits animationLimits) error {
+	if n < limits.minFrames || n > limits.maxFrames {
+		return fmt.Errorf("animation needs %d to %d frames", limits.minFrames, limits.maxFrames)
+	}
+	return nil
+}
+
+func validateTiming(delays []int, loops int, limits animationLimits) error {
+	if err := validateFrameCount(len(delays), limits); err != nil {
+		return err
+	}
+	if loops < 1 || loops > limits.maxLoops {
+		return fmt.Errorf("loops must be between 1 and %d", limits.maxLoops)
+	}
+	total := 0
+	for _, d := range delays {
+		if d <= 0 {
+			return errors.New("every frame needs a positive delayMs")
+		}
+		total += d
+	}
+	if total*loops > limits.maxPlaybackMs {
+		return fmt.Errorf("playback is %d ms over %d loops; the limit is %d ms", total*loops, loops, limits.maxPlaybackMs)
+	}
+	return nil
+}
+
+// frameDelays spreads durationMs over n frames, n at least 1; the last
+// frame takes the rounding remainder.
+func frameDelays(n, durationMs int) []int {
+	delays := make([]int, n)
+	for i := range delays {
+		delays[i] = durationMs / n
+	}
+	delays[n-1] += durationMs % n
+	return delays
+}
+
+func withFrameDefaults(req FramesGenerateRequest, limits animationLimits) FramesGenerateRequest {
+	if req.Mode == "" {
+		req.Mode = framesProvider
+	}
+	if req.Frames == 0 {
+		req.Frames = defaultFrames
+	}
+	if req.DurationMs == 0 {
+		req.DurationMs = defaultDurationMs
+	}
+	if req.Loops == 0 {
+		req.Loops = defaultLoops
+		if req.Loops > limits.maxLoops {
+			req.Loops = limits.maxLoops
+		}
+	}
+	if req.Motion == "" {
+		req.Motion = defaultMotion
+	}
+	return req
+}
+
+// animatedSticker returns a sticker whose product type is animated, with
+// the canvas its frames are laid out on. Caller holds s.mu.
+func (s *Store) animatedSticker(stickerID string) (*Sticker, fileRule, error) {
+	st, ok := s.getSticker(stickerID)
+	if !ok {
+		return nil, fileRule{}, errStickerNotFound
+	}
+	canvas := s.projectSpec(st.ProjectID).Item
+	if canvas.animation == nil {
+		return nil, fileRule{}, errors.New("animation needs an animated product type")
+	}
+	return st, canvas, nil
+}
+
+// GenerateFrames builds and stores a sticker's animation frames.
+func (s *Store) GenerateFrames(stickerID string, req FramesGenerateRequest) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, canvas, err := s.animatedSticker(stickerID)
+	if err != nil {
+		return nil, err
+	}
+	p, _ := s.getProject(st.ProjectID)
+	req = withFrameDefaults(req, *canvas.animation)
+	if err := validateFrameCount(req.Frames, *canvas.animation); err != nil {
+		return nil, err
+	}
+	delays := frameDelays(req.Frames, req.DurationMs)
+	if err := validateTiming(delays, req.Loops, *canvas.animation); err != nil {
+		return nil, err
+	}
+	for i, url := range req.Keyframes {
+		if err := checkLocalImageURL(url); err != nil {
+			return nil, fmt.Errorf("keyframe %d: %w", i+1, err)
+		}
+	}
+
+	post := s.getPostProcess(p.ID)
+	var frames []*image.RGBA
+	switch req.Mode {
+	case framesProvider:
+		raw, err := s.providerFrames(p, st, req)
+		if err != nil {
+			return nil, err
+		}
+		frames, err = postProcessFrames(raw, post, canvas.width, canvas.height)
+		if err != nil {
+			return nil, err
+		}
+	case framesInterpolate:
+		keys, err := s.loadKeyframes(p, st, req.Keyframes)
+		if err != nil {
+			return nil, err
+		}
+		laid, err := postProcessFrames(keys, post, canvas.width, canvas.height)
+		if err != nil {
+			return nil, err
+		}
+		frames = interpolateFrames(laid, req.Frames)
+	default:
+		return nil, errors.New("mode must be provider or interpolate")
+	}
+
+	anim := StickerAnimation{Loops: req.Loops}
+	for i, frame := range frames {
+		data, err := encodePNG(frame)
+		if err != nil {
+			return nil, err
+		}
+		url, err := saveAsset(data)
+		if err != nil {
+			return nil, err
+		}
+		anim.Frames = append(anim.Frames, AnimationFrame{ImageURL: url, DelayMs: delays[i]})
+	}
+	_, _ = s.db.Exec(`UPDATE stickers SET animation=? WHERE id=?`, encodeAnimation(&anim), stickerID)
+	job := s.newJob("GENERATE_FRAMES", p.ID, stickerID)
+	s.setJobProgress(job.ID, 100, "SUCCESS")
+	return job, nil
+}
+
+// providerFrames asks the image provider for each frame with one seed and
+// the frame's place in the motion appended to the sticker's prompt, then
+// removes the backgrounds. Caller holds s.mu.
+func (s *Store) providerFrames(p *Project, st *Sticker, req FramesGenerateRequest) ([]image.Image, error) {
+	d, err := scanDraft(s.db.QueryRow(`SELECT `+draftColumns+` FROM drafts WHERE id=?`, st.DraftID))
+	if err != nil {
+		d = &Draft{ID: st.DraftID, ProjectID: p.ID}
+	}
+	charInput := s.getCastInput(p.ID, d.CharacterIDs)
+	imageProvider, imageModel := resolveProviderModel(p.ImageProvider, p.ImageModel, p.AIProvider, p.AIModel)
+	base := s.draftImageRequest(p, d, imageProvider)
+	prompt := ""
+	if prev := st.Generation; prev != nil {
+		if prev.Provider != "" && prev.Provider != "mock" {
+			imageProvider, imageModel = prev.Provider, prev.Model
+		}
+		base.Seed = prev.Seed
+		base.NegativePrompt = prev.NegativePrompt
+		base.Params = prev.Params
+		prompt = prev.Prompt
+	}
+	if base.Seed == 0 {
+		// one seed for every frame keeps the character consistent
+		base.Seed = ai.RandomSeed()
+	}
+	base.N = 1
+	pipeline, _ := s.getTaskPipeline(p.ID, imageProvider, imageModel)
+	bgProvider, bgModel := resolveProviderModel(p.BgProvider, p.BgModel, p.AIProvider, p.AIModel)
+	bgPipeline, _ := s.getTaskPipeline(p.ID, bgProvider, bgModel)
+
+	frames := []image.Image{}
+	for i := 0; i < req.Frames; i++ {
+		motion := fmt.Sprintf(". Animation frame %d of %d: %s. Keep the character, framing and colours identical to the other frames.", i+1, req.Frames, req.Motion)
+		frameReq := base
+		if prompt != "" {
+			frameReq = verbatimImageRequest(base, strings.TrimRight(prompt, ". ")+motion)
+		} else {
+			frameReq.Prompt = strings.TrimRight(base.Prompt, ". ") + motion
+		}
+		images, err := pipeline.GenerateImages(frameReq, charInput)
+		if err != nil {
+			return nil, fmt.Errorf("frame %d: %w", i+1, err)
+		}
+		if len(images) == 0 || images[0].URL == "" {
+			return nil, fmt.Errorf("frame %d: provider returned no image", i+1)
+		}
+		url := images[0].URL
+		if cut, err := bgPipeline.RemoveBackground(providerImageURL(url)); err == nil && cut != "" {
+			url = cut
+		}
+		img, err := loadImage(url)
+		if err != nil {
+			return nil, fmt.Errorf("frame %d: %w", i+1, err)
+		}
+		frames = append(frames, img)
+	}
+	return frames, nil
+}
+
+// loadKeyframes returns the interpolation keyframes: the given ones when
+// there are at least two, otherwise the sticker's own cut-out followed by
+// them. The stored cut-out is already post-processed and the stages run
+// again over every keyframe, so the background is removed afresh from the
+// generated image instead. Caller holds s.mu.
+func (s *Store) loadKeyframes(p *Project, st *Sticker, urls []string) ([]image.Image, error) {
+	if len(urls) < 2 {
+		if st.ImageURL == "" {
+			return nil, errors.New("sticker has no image to start from")
+		}
+		own := st.ImageURL
+		bgProvider, bgModel := resolveProviderModel(p.BgProvider, p.BgModel, p.AIProvider, p.AIModel)
+		bgPipeline, _ := s.getTaskPipeline(p.ID, bgProvider, bgModel)
+		if cut, err := bgPipeline.RemoveBackground(providerImageURL(own)); err == nil && cut != "" {
+			own = cut
+		}
+		urls = append([]string{own}, urls...)
+	}
+	if len(urls) < 2 {
+		return nil, errors.New("interpolate needs at least one keyframe besides the sticker")
+	}
+	keys := make([]image.Image, len(urls))
+	for i, url := range urls {
+		img, err := loadImage(url)
+		if err != nil {
+			return nil, fmt.Errorf("keyframe %d: %w", i+1, err)
+		}
+		keys[i] = img
+	}
+	return keys, nil
+}
+
+// interpolateFrames cross-fades between keyframes around a closed loop, so
+// the last frame leads smoothly back into the first.
+func interpolateFrames(keys []*image.RGBA, n int) []*image.RGBA {
+	m := len(keys)
+	out := make([]*image.RGBA, n)
+	for i := range out {
+		t := float64(i*m) / float64(n)
+		k := int(t)
+		out[i] = blendFrames(keys[k], keys[(k+1)%m], t-float64(k))
+	}
+	return out
+}
+
+// blendFrames mixes two same-sized frames; premultiplied RGBA blends
+// linearly without dark fringes.
+func blendFrames(a, b *image.RGBA, f float64) *image.RGBA {
+	dst := image.NewRGBA(a.Rect)
+	for i := range dst.Pix {
+		dst.Pix[i] = uint8(float64(a.Pix[i])*(1-f) + float64(b.Pix[i])*f + 0.5)
+	}
+	return dst
+}
+
+// loadAnimationFrames decodes the frames fitted to w x h along with their
+// delays.
+func loadAnimationFrames(anim StickerAnimation, w, h int) ([]image.Image, []int, error) {
+	frames := make([]image.Image, len(anim.Frames))
+	delays := make([]int, len(anim.Frames))
+	for i, f := range anim.Frames {
+		img, err := loadImage(f.ImageURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("frame %d: %w", i+1, err)
+		}
+		fitted, err := fitImage(img, w, h)
+		if err != nil {
+			return nil, nil, err
+		}
+		frames[i], delays[i] = fitted, f.DelayMs
+	}
+	return frames, delays, nil
+}
+
+// UpdateAnimation replaces a sticker's frames, delays or loop count, for
+// example to retime generated frames or reorder them.
+func (s *Store) UpdateAnimation(stickerID string, anim StickerAnimation) (*Sticker, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, canvas, err := s.animatedSticker(stickerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateAnimation(anim, *canvas.animation); err != nil {
+		return nil, err
+	}
+	_, _ = s.db.Exec(`UPDATE stickers SET animation=? WHERE id=?`, encodeAnimation(&anim), stickerID)
+	st, _ := s.getSticker(stickerID)
+	return st, nil
+}
+
+// AnimationPreview encodes a sticker's frames as the APNG that export
+// would write.
+func (s *Store) AnimationPreview(stickerID string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, canvas, err := s.animatedSticker(stickerID)
+	if err != nil {
+		return nil, err
+	}
+	if st.Animation == nil {
+		return nil, errors.New("sticker has no frames yet")
+	}
+	frames, delays, err := loadAnimationFrames(*st.Animation, canvas.width, canvas.height)
+	if err != nil {
+		return nil, err
+	}
+	return encodeAPNG(frames, delays, st.Animation.Loops)
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateAnimationFrameURLs(t *testing.T) {
+	data, err := encodePNG(movingFrames(1)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset, err := saveAsset(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer removeAssets([]string{asset})
+	dataURL, err := pngDataURL(movingFrames(1)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		ok   bool
+	}{
+		{"asset", asset, true},
+		{"data url", dataURL, true},
+		{"remote", "http://169.254.169.254/latest/meta-data", false},
+		{"missing asset", assetURLPrefix + "asset_missing.png", false},
+		{"path traversal", assetURLPrefix + "../data.db", false},
+		{"bad data url", "data:image/png,raw", false},
+		{"file", "file:///etc/passwd", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			anim := StickerAnimation{Loops: 1}
+			for i := 0; i < 5; i++ {
+				url := asset
+				if i == 3 {
+					url = tt.url
+				}
+				anim.Frames = append(anim.Frames, AnimationFrame{ImageURL: url, DelayMs: 100})
+			}
+			err := validateAnimation(anim, lineAnimation)
+			if (err == nil) != tt.ok {
+				t.Fatalf("err = %v, want ok %v", err, tt.ok)
+			}
+			if err != nil && !strings.HasPrefix(err.Error(), "frame 4:") {
+				t.Fatalf("err = %v, want it to name frame 4", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"example.com/app/internal/ai"
+)
+
+const maxCandidateCount = 8
+
+func clampCandidateCount(n int) int {
+	if n < 0 {
+		return 0
+	}
+	if n > maxCandidateCount {
+		return maxCandidateCount
+	}
+	return n
+}
+
+// insertCandidate records one generated image for a draft. When selected is
+// true every other candidate of the draft is deselected. Caller holds s.mu.
+func (s *Store) insertCandidate(projectID, draftID string, img ai.GeneratedImage, selected bool) string {
+	id, _ := insertCandidateTx(s.db, projectID, draftID, img, selected)
+	return id
+}
+
+// dbExecer is the part of *sql.DB and *sql.Tx that writes.
+type dbExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// insertCandidateTx is insertCandidate within a transaction, reporting
+// failures so the caller can roll back.
+func insertCandidateTx(db dbExecer, projectID, draftID string, img ai.GeneratedImage, selected bool) (string, error) {
+	if selected {
+		if _, err := db.Exec(`UPDATE candidates SET selected=0 WHERE draft_id=?`, draftID); err != nil {
+			return "", err
+		}
+	}
+	id := newID("cand")
+	_, err := db.Exec(`INSERT INTO candidates (id,project_id,draft_id,image_url,selected,created_at,generation) VALUES (?,?,?,?,?,?,?)`,
+		id, projectID, draftID, img.URL, boolToInt(selected), nowStamp(), encodeGeneration(img),
+	)
+	return id, err
+}
+
+// encodeGeneration stores the reproducibility record of an image; uploads
+// have none.
+func encodeGeneration(img ai.GeneratedImage) string {
+	if img.Prompt == "" {
+		return ""
+	}
+	b, _ := json.Marshal(img)
+	return string(b)
+}
+
+func decodeGeneration(v string) *ai.GeneratedImage {
+	if v == "" {
+		return nil
+	}
+	img := &ai.GeneratedImage{}
+	if err := json.Unmarshal([]byte(v), img); err != nil {
+		return nil
+	}
+	return img
+}
+
+func (s *Store) ListCandidates(draftID string) ([]*Candidate, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var exists string
+	if err := s.db.QueryRow(`SELECT id FROM drafts WHERE id=?`, draftID).Scan(&exists); err != nil {
+		return nil, false
+	}
+	rows, _ := s.db.Query(`SELECT id,project_id,draft_id,image_url,selected,created_at,COALESCE(generation,'') FROM candidates WHERE draft_id=? ORDER BY created_at`, draftID)
+	defer rows.Close()
+	out := []*Candidate{}
+	for rows.Next() {
+		c := &Candidate{}
+		var selected int
+		var generation string
+		_ = rows.Scan(&c.ID, &c.ProjectID, &c.DraftID, &c.ImageURL, &selected, &c.CreatedAt, &generation)
+		c.Selected = selected == 1
+		c.Generation = decodeGeneration(generation)
+		out = append(out, c)
+	}
+	return out, true
+}
+
+// SelectCandidate makes the candidate the image of the draft's sticker. The
+// transparent image is cleared so background removal runs on the new pick.
+func (s *Store) SelectCandidate(draftID, candidateID string) (*Sticker, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	row := s.db.QueryRow(`SELECT project_id,image_url,COALESCE(generation,'') FROM candidates WHERE id=? AND draft_id=?`, candidateID, draftID)
+	var projectID, imageURL, generation string
+	if err := row.Scan(&projectID, &imageURL, &generation); err != nil {
+		return nil, false
+	}
+	_, _ = s.db.Exec(`UPDATE candidates SET selected=CASE WHEN id=? THEN 1 ELSE 0 END WHERE draft_id=?`, candidateID, draftID)
+
+	var stickerID string
+	_ = s.db.QueryRow(`SELECT id FROM stickers WHERE draft_id=? ORDER BY created_at DESC LIMIT 1`, draftID).Scan(&stickerID)
+	if stickerID == "" {
+		stickerID = newID("stk")
+		_, _ = s.db.Exec(`INSERT INTO stickers (id,project_id,draft_id,image_url,transparent_url,status,created_at,source,generation) VALUES (?,?,?,?,?,?,?,?,?)`,
+			stickerID, projectID, draftID, imageURL, "", "READY", nowStamp(), "AI", generation,
+		)
+	} else {
+		_, _ = s.db.Exec(`UPDATE stickers SET image_url=?, transparent_url=?, status=?, generation=? WHERE id=?`, imageURL, "", "READY", generation, stickerID)
+	}
+
+	return s.getSticker(stickerID)
+}
+
+func boolToInt(v bool) int {
+	if v {
+		return 1
+	}
+	return 0
+}
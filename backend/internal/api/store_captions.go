@@ -0,0 +1,376 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"strings"
+
+	"golang.org/x/image/font/opentype"
+)
+
+// getCaptionStyle returns the project's caption style with defaults filled
+// in. Caller holds s.mu.
+func (s *Store) getCaptionStyle(projectID string) CaptionStyle {
+	var raw string
+	_ = s.db.QueryRow(`SELECT COALESCE(caption_style,'') FROM projects WHERE id=?`, projectID).Scan(&raw)
+	st := CaptionStyle{}
+	if raw != "" {
+		_ = json.Unmarshal([]byte(raw), &st)
+	}
+	st = withCaptionDefaults(st)
+	st.FallbackFontIDs = nonNilList(st.FallbackFontIDs)
+	return st
+}
+
+func (s *Store) GetCaptionStyle(projectID string) (*CaptionStyle, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.getProject(projectID); !ok {
+		return nil, false
+	}
+	st := s.getCaptionStyle(projectID)
+	return &st, true
+}
+
+// checkCaptionStyle validates a style and the fonts it names. Caller holds
+// s.mu.
+func (s *Store) checkCaptionStyle(st CaptionStyle) error {
+	if err := validateCaptionStyle(st); err != nil {
+		return err
+	}
+	for _, id := range append([]string{st.FontID}, st.FallbackFontIDs...) {
+		if _, ok := s.fontPath(id); !ok {
+			return errors.New("unknown font " + id)
+		}
+	}
+	return nil
+}
+
+func (s *Store) UpdateCaptionStyle(projectID string, req CaptionStyle) (*CaptionStyle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.getProject(projectID); !ok {
+		return nil, errProjectNotFound
+	}
+	st := withCaptionDefaults(req)
+	st.FallbackFontIDs = nonNilList(st.FallbackFontIDs)
+	if err := s.checkCaptionStyle(st); err != nil {
+		return nil, err
+	}
+	raw, _ := json.Marshal(st)
+	_, _ = s.db.Exec(`UPDATE projects SET caption_style=? WHERE id=?`, string(raw), projectID)
+	return &st, nil
+}
+
+// SetStickerCaption turns the caption on or off for one sticker; nil goes
+// back to the project setting.
+func (s *Store) SetStickerCaption(stickerID string, req StickerCaptionRequest) (*Sticker, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var show interface{}
+	if req.ShowCaption != nil {
+		show = boolToInt(*req.ShowCaption)
+	}
+	res, _ := s.db.Exec(`UPDATE stickers SET show_caption=? WHERE id=?`, show, stickerID)
+	if aff, _ := res.RowsAffected(); aff == 0 {
+		return nil, false
+	}
+	return s.getSticker(stickerID)
+}
+
+// fontPath returns the stored file of an uploaded font, or "" for a bundled
+// one. Caller holds s.mu.
+func (s *Store) fontPath(id string) (string, bool) {
+	if _, ok := bundledFonts[id]; ok {
+		return "", true
+	}
+	var path string
+	if err := s.db.QueryRow(`SELECT path FROM fonts WHERE id=?`, id).Scan(&path); err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// captionFonts builds the fallback chain for a style: the chosen font, its
+// listed fallbacks, every uploaded font, the bundled default and finally
+// the bundled CJK subset. Caller holds s.mu.
+func (s *Store) captionFonts(st CaptionStyle) []*opentype.Font {
+	ids := append([]string{st.FontID}, st.FallbackFontIDs...)
+	rows, _ := s.db.Query(`SELECT id FROM fonts ORDER BY created_at`)
+	for rows.Next() {
+		var id string
+		_ = rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	rows.Close()
+	ids = append(ids, defaultCaptionFont, cjkCaptionFont)
+
+	seen := map[string]bool{}
+	fonts := []*opentype.Font{}
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		path, ok := s.fontPath(id)
+		if !ok {
+			continue
+		}
+		if f, err := loadFont(id, path); err == nil {
+			fonts = append(fonts, f)
+		}
+	}
+	return fonts
+}
+
+// draftCaption returns the caption of the sticker's draft. Caller holds s.mu.
+func (s *Store) draftCaption(draftID string) string {
+	var caption string
+	_ = s.db.QueryRow(`SELECT caption FROM drafts WHERE id=?`, draftID).Scan(&caption)
+	return caption
+}
+
+func showCaption(st *Sticker, style CaptionStyle) bool {
+	if st.ShowCaption != nil {
+		return *st.ShowCaption
+	}
+	return style.Enabled
+}
+
+// renderSticker draws the sticker at its final size with the caption on
+// top. Caller holds s.mu.
+func (s *Store) renderSticker(st *Sticker, style CaptionStyle, caption string) ([]byte, error) {
+	dst, err := s.drawSticker(st, style, caption)
+	if err != nil {
+		return nil, err
+	}
+	return encodePNG(dst)
+}
+
+// drawSticker is renderSticker before encoding. Caller holds s.mu.
+func (s *Store) drawSticker(st *Sticker, style CaptionStyle, caption string) (*image.RGBA, error) {
+	url := st.TransparentURL
+	if url == "" {
+		url = st.ImageURL
+	}
+	src, err := loadImage(url)
+	if err != nil {
+		return nil, err
+	}
+	spec := s.projectSpec(st.ProjectID)
+	canvas := spec.Item
+	// message stickers keep the artwork and caption clear of the text area;
+	// background removal already laid a cut-out out that way
+	layout := subjectArea(textAreaFor(st, spec), canvas.width, canvas.height)
+	var dst *image.RGBA
+	if st.TransparentURL == "" {
+		dst, err = placeInArea(src, layout, canvas.width, canvas.height)
+	} else {
+		dst, err = fitImage(src, canvas.width, canvas.height)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if caption != "" {
+		if err := drawCaption(dst.SubImage(layout).(*image.RGBA), caption, style, s.captionFonts(style)); err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+// StickerPreview renders a sticker as it will be exported. show overrides
+// the caption setting when not nil.
+func (s *Store) StickerPreview(stickerID string, show *bool) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.getSticker(stickerID)
+	if !ok {
+		return nil, errStickerNotFound
+	}
+	style := s.getCaptionStyle(st.ProjectID)
+	caption := ""
+	if (show == nil && showCaption(st, style)) || (show != nil && *show) {
+		caption = s.draftCaption(st.DraftID)
+	}
+	return s.renderSticker(st, style, caption)
+}
+
+// PreviewCaptionStyle renders an unsaved style on a sticker or a blank
+// canvas.
+func (s *Store) PreviewCaptionStyle(projectID string, req CaptionPreviewRequest) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.getProject(projectID); !ok {
+		return nil, errProjectNotFound
+	}
+	style := s.getCaptionStyle(projectID)
+	if req.Style != nil {
+		style = withCaptionDefaults(*req.Style)
+		if err := s.checkCaptionStyle(style); err != nil {
+			return nil, err
+		}
+	}
+	text := req.Text
+	if req.StickerID != "" {
+		st, ok := s.getSticker(req.StickerID)
+		if !ok || st.ProjectID != projectID {
+			return nil, errStickerNotFound
+		}
+		if text == "" {
+			text = s.draftCaption(st.DraftID)
+		}
+		return s.renderSticker(st, style, text)
+	}
+	if strings.TrimSpace(text) == "" {
+		text = "Sample"
+	}
+	canvas := s.projectSpec(projectID).Item
+	dst := image.NewRGBA(image.Rect(0, 0, canvas.width, canvas.height))
+	if err := drawCaption(dst, text, style, s.captionFonts(style)); err != nil {
+		return nil, err
+	}
+	return encodePNG(dst)
+}
+
+// applyCaptions swaps in captioned images for stickers that show their
+// caption, so the export gets the final artwork; animated stickers get the
+// caption on every frame. Caller holds s.mu.
+func (s *Store) applyCaptions(projectID string, list []Sticker) {
+	style := s.getCaptionStyle(projectID)
+	for i := range list {
+		st := &list[i]
+		if !showCaption(st, style) {
+			continue
+		}
+		caption := s.draftCaption(st.DraftID)
+		if caption == "" {
+			continue
+		}
+		if st.Animation != nil {
+			if anim, err := s.captionFrames(st, style, caption); err == nil {
+				st.Animation = anim
+			}
+			continue
+		}
+		data, err := s.renderSticker(st, style, caption)
+		if err != nil {
+			continue
+		}
+		st.TransparentURL = "data:image/png;base64," + base64.StdEncoding.EncodeToString(data)
+	}
+}
+
+// captionFrames draws the caption on every frame of an animated sticker,
+// where it stays still while the artwork moves. Caller holds s.mu.
+func (s *Store) captionFrames(st *Sticker, style CaptionStyle, caption string) (*StickerAnimation, error) {
+	canvas := s.projectSpec(st.ProjectID).Item
+	fonts := s.captionFonts(style)
+	anim := &StickerAnimation{Loops: st.Animation.Loops}
+	for _, f := range st.Animation.Frames {
+		src, err := loadImage(f.ImageURL)
+		if err != nil {
+			return nil, err
+		}
+		dst, err := fitImage(src, canvas.width, canvas.height)
+		if err != nil {
+			return nil, err
+		}
+		if err := drawCaption(dst, caption, style, fonts); err != nil {
+			return nil, err
+		}
+		url, err := pngDataURL(dst)
+		if err != nil {
+			return nil, err
+		}
+		anim.Frames = append(anim.Frames, AnimationFrame{ImageURL: url, DelayMs: f.DelayMs})
+	}
+	return anim, nil
+}
+
+// captionFontFindings warns about captions drawn on the export that have
+// characters no caption font covers, such as Hangul or hanzi outside the
+// bundled CJK subset; those would show as empty boxes. withSheet counts every caption,
+// as the contact sheet labels each sticker with its own. Caller holds s.mu.
+func (s *Store) captionFontFindings(projectID string, list []Sticker, withSheet bool) []ValidationFinding {
+	style := s.getCaptionStyle(projectID)
+	fonts := s.captionFonts(style)
+	findings := []ValidationFinding{}
+	seen := map[string]bool{}
+	for i := range list {
+		st := &list[i]
+		if !withSheet && !showCaption(st, style) {
+			continue
+		}
+		caption := s.draftCaption(st.DraftID)
+		if caption == "" || seen[caption] {
+			continue
+		}
+		seen[caption] = true
+		if missing := missingGlyphs(caption, fonts); len(missing) > 0 {
+			message := fmt.Sprintf("caption %q has characters no caption font covers (%s); upload a font for them", caption, string(missing))
+			findings = append(findings, ValidationFinding{Rule: "caption-font", Severity: severityWarning, Message: message})
+		}
+	}
+	return findings
+}
+
+func (s *Store) ListFonts() []Font {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []Font{}
+	for _, id := range bundledFontIDs {
+		b := bundledFonts[id]
+		out = append(out, Font{ID: id, Name: b.name, Family: b.family, Bundled: true})
+	}
+	rows, _ := s.db.Query(`SELECT id,name,family,created_at FROM fonts ORDER BY created_at`)
+	defer rows.Close()
+	for rows.Next() {
+		var f Font
+		_ = rows.Scan(&f.ID, &f.Name, &f.Family, &f.CreatedAt)
+		out = append(out, f)
+	}
+	return out
+}
+
+// AddFont stores an uploaded TrueType/OpenType font for captions.
+func (s *Store) AddFont(name string, data []byte) (*Font, error) {
+	id := newID("font")
+	path, f, err := saveFont(id, data)
+	if err != nil {
+		return nil, err
+	}
+	family := fontFamily(f)
+	if name == "" {
+		name = family
+	}
+	if name == "" {
+		name = id
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	font := &Font{ID: id, Name: name, Family: family, CreatedAt: nowStamp()}
+	_, _ = s.db.Exec(`INSERT INTO fonts (id,name,family,path,created_at) VALUES (?,?,?,?,?)`,
+		font.ID, font.Name, font.Family, path, font.CreatedAt,
+	)
+	return font, nil
+}
+
+// DeleteFont removes an uploaded font. Styles that still name it fall back
+// along their chain.
+func (s *Store) DeleteFont(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path, ok := s.fontPath(id)
+	if !ok || path == "" {
+		return false
+	}
+	_, _ = s.db.Exec(`DELETE FROM fonts WHERE id=?`, id)
+	forgetFont(id)
+	_ = os.Remove(path)
+	return true
+}
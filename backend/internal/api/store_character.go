@@ -0,0 +1,276 @@
+package api
+
+import "encoding/json"
+
+const characterColumns = `id,source_type,COALESCE(name,''),COALESCE(prompt,''),COALESCE(personality,''),COALESCE(palette,''),COALESCE(style_notes,''),COALESCE(reference_image_url,''),COALESCE(reference_images,''),status,COALESCE(library_id,''),COALESCE(library_version,0)`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCharacter(row rowScanner) (*Character, error) {
+	c := &Character{}
+	var palette, refs string
+	if err := row.Scan(&c.ID, &c.SourceType, &c.Name, &c.Prompt, &c.Personality, &palette, &c.StyleNotes, &c.ReferenceImageURL, &refs, &c.Status, &c.LibraryCharacterID, &c.LibraryVersion); err != nil {
+		return nil, err
+	}
+	c.LibraryPinned = c.LibraryVersion > 0
+	c.Palette = decodeList(palette)
+	c.ReferenceImages = decodeList(refs)
+	if len(c.ReferenceImages) == 0 && c.ReferenceImageURL != "" {
+		c.ReferenceImages = []string{c.ReferenceImageURL}
+	}
+	return c, nil
+}
+
+// getProjectCharacter returns the character referenced by the project, or the
+// most recently created one for older rows. Caller holds s.mu.
+func (s *Store) getProjectCharacter(projectID string) (*Character, bool) {
+	var characterID string
+	_ = s.db.QueryRow(`SELECT COALESCE(character_id,'') FROM projects WHERE id=?`, projectID).Scan(&characterID)
+	if characterID != "" {
+		if c, err := scanCharacter(s.db.QueryRow(`SELECT `+characterColumns+` FROM characters WHERE id=?`, characterID)); err == nil {
+			return s.resolveLibraryCharacter(c), true
+		}
+	}
+	c, err := scanCharacter(s.db.QueryRow(`SELECT `+characterColumns+` FROM characters WHERE project_id=? ORDER BY rowid DESC LIMIT 1`, projectID))
+	if err != nil {
+		return nil, false
+	}
+	return s.resolveLibraryCharacter(c), true
+}
+
+func (s *Store) GetProjectCharacter(projectID string) (*Character, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.getProjectCharacter(projectID)
+}
+
+func (s *Store) UpdateProjectCharacter(projectID string, req CharacterUpdateRequest) (*Character, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resolved, ok := s.getProjectCharacter(projectID)
+	if !ok {
+		return nil, false
+	}
+	return s.updateCharacter(resolved.ID, req)
+}
+
+// updateCharacter patches the stored row; for library characters these are
+// project overrides. Caller holds s.mu.
+func (s *Store) updateCharacter(characterID string, req CharacterUpdateRequest) (*Character, bool) {
+	c, err := scanCharacter(s.db.QueryRow(`SELECT `+characterColumns+` FROM characters WHERE id=?`, characterID))
+	if err != nil {
+		return nil, false
+	}
+	if req.Palette != nil {
+		c.Palette = req.Palette
+	}
+	if req.ReferenceImages != nil {
+		c.ReferenceImages = req.ReferenceImages
+		c.ReferenceImageURL = firstOf(req.ReferenceImages)
+	}
+	_, _ = s.db.Exec(`UPDATE characters SET
+		name=COALESCE(NULLIF(?,''),name),
+		prompt=COALESCE(NULLIF(?,''),prompt),
+		personality=COALESCE(NULLIF(?,''),personality),
+		style_notes=COALESCE(NULLIF(?,''),style_notes),
+		palette=?, reference_images=?, reference_image_url=?
+		WHERE id=?`,
+		req.Name, req.Prompt, req.Personality, req.StyleNotes,
+		encodeList(c.Palette), encodeList(c.ReferenceImages), c.ReferenceImageURL, c.ID,
+	)
+	updated, err := scanCharacter(s.db.QueryRow(`SELECT `+characterColumns+` FROM characters WHERE id=?`, c.ID))
+	if err != nil {
+		return nil, false
+	}
+	return s.resolveLibraryCharacter(updated), true
+}
+
+func encodeList(v []string) string {
+	if len(v) == 0 {
+		return ""
+	}
+	buf, _ := json.Marshal(v)
+	return string(buf)
+}
+
+func decodeList(v string) []string {
+	out := []string{}
+	if v == "" {
+		return out
+	}
+	_ = json.Unmarshal([]byte(v), &out)
+	return out
+}
+
+func nonNilList(v []string) []string {
+	if v == nil {
+		return []string{}
+	}
+	return v
+}
+
+func firstOf(v []string) string {
+	if len(v) == 0 {
+		return ""
+	}
+	return v[0]
+}
+
+const draftColumns = `id,project_id,idx,caption,image_prompt,status,COALESCE(character_ids,''),COALESCE(style_preset,''),COALESCE(emoji,'')`
+
+func scanDraft(row rowScanner) (*Draft, error) {
+	d := &Draft{}
+	var characterIDs, emoji string
+	if err := row.Scan(&d.ID, &d.ProjectID, &d.Index, &d.Caption, &d.ImagePrompt, &d.Status, &characterIDs, &d.StylePreset, &emoji); err != nil {
+		return nil, err
+	}
+	d.CharacterIDs = decodeList(characterIDs)
+	d.Emoji = decodeList(emoji)
+	return d, nil
+}
+
+// insertCharacter stores a new character row for the project without changing
+// the cast. Caller holds s.mu.
+func (s *Store) insertCharacter(projectID string, req CharacterCreateRequest) (*Character, error) {
+	if req.LibraryCharacterID != "" {
+		return s.attachLibraryCharacter(projectID, req)
+	}
+	refs := req.ReferenceImages
+	if len(refs) == 0 && req.ReferenceImageURL != "" {
+		refs = []string{req.ReferenceImageURL}
+	}
+	c := &Character{
+		ID:              newID("char"),
+		SourceType:      req.SourceType,
+		Name:            req.Name,
+		Prompt:          req.Prompt,
+		Personality:     req.Personality,
+		Palette:         nonNilList(req.Palette),
+		StyleNotes:      req.StyleNotes,
+		ReferenceImages: nonNilList(refs),
+		Status:          "READY",
+	}
+	c.ReferenceImageURL = firstOf(c.ReferenceImages)
+	_, _ = s.db.Exec(`INSERT INTO characters (id,project_id,source_type,reference_image_url,status,name,prompt,personality,palette,style_notes,reference_images) VALUES (?,?,?,?,?,?,?,?,?,?,?)`,
+		c.ID, projectID, c.SourceType, c.ReferenceImageURL, c.Status, c.Name, c.Prompt, c.Personality, encodeList(c.Palette), c.StyleNotes, encodeList(c.ReferenceImages),
+	)
+	return c, nil
+}
+
+// setPrimaryCharacter makes the character the project's lead, replacing the
+// previous lead in the cast. Caller holds s.mu.
+func (s *Store) setPrimaryCharacter(projectID, characterID string) {
+	var previous string
+	_ = s.db.QueryRow(`SELECT COALESCE(character_id,'') FROM projects WHERE id=?`, projectID).Scan(&previous)
+	if previous != "" && previous != characterID {
+		_, _ = s.db.Exec(`DELETE FROM project_characters WHERE project_id=? AND character_id=?`, projectID, previous)
+	}
+	_, _ = s.db.Exec(`INSERT OR REPLACE INTO project_characters (project_id,character_id,position) VALUES (?,?,0)`, projectID, characterID)
+	_, _ = s.db.Exec(`UPDATE projects SET character_id=? WHERE id=?`, characterID, projectID)
+}
+
+// listProjectCharacters returns the cast, lead first. Projects created before
+// casts existed fall back to their single character. Caller holds s.mu.
+func (s *Store) listProjectCharacters(projectID string) []*Character {
+	rows, _ := s.db.Query(`SELECT c.id FROM project_characters pc JOIN characters c ON c.id=pc.character_id WHERE pc.project_id=? ORDER BY pc.position, pc.rowid`, projectID)
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		_ = rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	rows.Close()
+	out := []*Character{}
+	for _, id := range ids {
+		if c, err := scanCharacter(s.db.QueryRow(`SELECT `+characterColumns+` FROM characters WHERE id=?`, id)); err == nil {
+			out = append(out, s.resolveLibraryCharacter(c))
+		}
+	}
+	if len(out) == 0 {
+		if c, ok := s.getProjectCharacter(projectID); ok {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (s *Store) ListProjectCharacters(projectID string) ([]*Character, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.getProject(projectID); !ok {
+		return nil, false
+	}
+	return s.listProjectCharacters(projectID), true
+}
+
+// AddProjectCharacter appends a character to the cast. The first character of
+// a project also becomes its lead.
+func (s *Store) AddProjectCharacter(projectID string, req CharacterCreateRequest) (*Character, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.getProject(projectID); !ok {
+		return nil, errProjectNotFound
+	}
+	cast := s.listProjectCharacters(projectID)
+	c, err := s.insertCharacter(projectID, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(cast) == 0 {
+		s.setPrimaryCharacter(projectID, c.ID)
+		return c, nil
+	}
+	// materialize the legacy single character before extending the cast
+	_, _ = s.db.Exec(`INSERT OR IGNORE INTO project_characters (project_id,character_id,position) VALUES (?,?,0)`, projectID, cast[0].ID)
+	_, _ = s.db.Exec(`INSERT OR REPLACE INTO project_characters (project_id,character_id,position) VALUES (?,?,?)`, projectID, c.ID, len(cast))
+	return c, nil
+}
+
+func (s *Store) UpdateProjectCastCharacter(projectID, characterID string, req CharacterUpdateRequest) (*Character, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.inCast(projectID, characterID) {
+		return nil, false
+	}
+	return s.updateCharacter(characterID, req)
+}
+
+// RemoveProjectCharacter drops a character from the cast; removing the lead
+// promotes the next character.
+func (s *Store) RemoveProjectCharacter(projectID, characterID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.inCast(projectID, characterID) {
+		return false
+	}
+	cast := s.listProjectCharacters(projectID)
+	_, _ = s.db.Exec(`DELETE FROM project_characters WHERE project_id=? AND character_id=?`, projectID, characterID)
+	_, _ = s.db.Exec(`UPDATE characters SET project_id='' WHERE id=?`, characterID)
+	var primary string
+	_ = s.db.QueryRow(`SELECT COALESCE(character_id,'') FROM projects WHERE id=?`, projectID).Scan(&primary)
+	if primary == characterID {
+		next := ""
+		for _, c := range cast {
+			if c.ID != characterID {
+				next = c.ID
+				break
+			}
+		}
+		_, _ = s.db.Exec(`UPDATE projects SET character_id=? WHERE id=?`, next, projectID)
+		if next != "" {
+			_, _ = s.db.Exec(`UPDATE project_characters SET position=0 WHERE project_id=? AND character_id=?`, projectID, next)
+		}
+	}
+	return true
+}
+
+func (s *Store) inCast(projectID, characterID string) bool {
+	for _, c := range s.listProjectCharacters(projectID) {
+		if c.ID == characterID {
+			return true
+		}
+	}
+	return false
+}
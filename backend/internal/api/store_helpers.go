@@ -3,13 +3,57 @@ package api
 import "example.com/app/internal/ai"
 
 func (s *Store) getCharacterInput(projectID string) ai.CharacterInput {
-	row := s.db.QueryRow(`SELECT source_type, reference_image_url FROM characters WHERE project_id=? ORDER BY rowid DESC LIMIT 1`, projectID)
-	var sourceType, refURL string
-	_ = row.Scan(&sourceType, &refURL)
+	return s.getCastInput(projectID, nil)
+}
+
+// getCastInput builds the prompt input for the selected project characters;
+// an empty selection uses the whole cast. The first character leads and the
+// rest become companions.
+func (s *Store) getCastInput(projectID string, characterIDs []string) ai.CharacterInput {
+	cast := s.listProjectCharacters(projectID)
+	if len(characterIDs) > 0 {
+		byID := map[string]*Character{}
+		for _, c := range cast {
+			byID[c.ID] = c
+		}
+		picked := []*Character{}
+		for _, id := range characterIDs {
+			if c, ok := byID[id]; ok {
+				picked = append(picked, c)
+			}
+		}
+		if len(picked) > 0 {
+			cast = picked
+		}
+	}
+	if len(cast) == 0 {
+		return ai.CharacterInput{Prompt: "main character"}
+	}
+	in := characterInput(cast[0])
+	for _, c := range cast[1:] {
+		in.Companions = append(in.Companions, characterInput(c))
+	}
+	return in
+}
+
+func characterInput(c *Character) ai.CharacterInput {
+	prompt := c.Prompt
+	if prompt == "" && c.Name == "" {
+		prompt = "main character"
+	}
+	refs := make([]string, 0, len(c.ReferenceImages))
+	for _, u := range c.ReferenceImages {
+		refs = append(refs, providerImageURL(u))
+	}
 	return ai.CharacterInput{
-		Prompt:            "main character",
-		ReferenceImageURL: refURL,
-		SourceType:        sourceType,
+		Name:               c.Name,
+		Prompt:             prompt,
+		Personality:        c.Personality,
+		Palette:            c.Palette,
+		StyleNotes:         c.StyleNotes,
+		ReferenceImageURL:  providerImageURL(c.ReferenceImageURL),
+		ReferenceImageURLs: refs,
+		SourceType:         c.SourceType,
 	}
 }
 
@@ -20,6 +64,9 @@ func (s *Store) getProjectAI(projectID string) (string, string) {
 	return provider, model
 }
 
+// resolveProviderModel fills an unset task provider and model from the
+// project's. A keyless provider never takes another provider's model,
+// which it could not run; it gets its own default instead.
 func resolveProviderModel(primaryProvider, primaryModel, fallbackProvider, fallbackModel string) (string, string) {
 	provider := primaryProvider
 	model := primaryModel
@@ -28,6 +75,9 @@ func resolveProviderModel(primaryProvider, primaryModel, fallbackProvider, fallb
 	}
 	if model == "" {
 		model = fallbackModel
+		if ai.Keyless(provider) && provider != fallbackProvider {
+			model = ai.LocalDefaultModel
+		}
 	}
 	return provider, model
 }
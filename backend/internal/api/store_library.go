@@ -0,0 +1,234 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errLibraryCharacterNotFound = errors.New("library character not found")
+
+const libraryVersionColumns = `character_id,version,COALESCE(name,''),COALESCE(prompt,''),COALESCE(personality,''),COALESCE(palette,''),COALESCE(style_notes,''),COALESCE(reference_images,''),COALESCE(created_at,'')`
+
+func scanLibraryVersion(row rowScanner) (*LibraryCharacter, error) {
+	lc := &LibraryCharacter{}
+	var palette, refs string
+	if err := row.Scan(&lc.ID, &lc.Version, &lc.Name, &lc.Prompt, &lc.Personality, &palette, &lc.StyleNotes, &refs, &lc.CreatedAt); err != nil {
+		return nil, err
+	}
+	lc.Palette = decodeList(palette)
+	lc.ReferenceImages = decodeList(refs)
+	return lc, nil
+}
+
+// getLibraryCharacter loads a version of a library character; version 0 means
+// the latest. Caller holds s.mu.
+func (s *Store) getLibraryCharacter(id string, version int) (*LibraryCharacter, bool) {
+	var latest int
+	var createdAt, updatedAt string
+	row := s.db.QueryRow(`SELECT latest_version,COALESCE(created_at,''),COALESCE(updated_at,'') FROM library_characters WHERE id=?`, id)
+	if err := row.Scan(&latest, &createdAt, &updatedAt); err != nil {
+		return nil, false
+	}
+	if version <= 0 || version > latest {
+		version = latest
+	}
+	lc, err := scanLibraryVersion(s.db.QueryRow(`SELECT `+libraryVersionColumns+` FROM library_character_versions WHERE character_id=? AND version=?`, id, version))
+	if err != nil {
+		return nil, false
+	}
+	lc.CreatedAt = createdAt
+	lc.UpdatedAt = updatedAt
+	return lc, true
+}
+
+func (s *Store) insertLibraryVersion(lc *LibraryCharacter) {
+	_, _ = s.db.Exec(`INSERT INTO library_character_versions (character_id,version,name,prompt,personality,palette,style_notes,reference_images,created_at) VALUES (?,?,?,?,?,?,?,?,?)`,
+		lc.ID, lc.Version, lc.Name, lc.Prompt, lc.Personality, encodeList(lc.Palette), lc.StyleNotes, encodeList(lc.ReferenceImages), nowStamp(),
+	)
+}
+
+func (s *Store) CreateLibraryCharacter(req CharacterUpdateRequest) *LibraryCharacter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := nowStamp()
+	lc := &LibraryCharacter{
+		ID:              newID("lchar"),
+		Version:         1,
+		Name:            req.Name,
+		Prompt:          req.Prompt,
+		Personality:     req.Personality,
+		Palette:         nonNilList(req.Palette),
+		StyleNotes:      req.StyleNotes,
+		ReferenceImages: nonNilList(req.ReferenceImages),
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	_, _ = s.db.Exec(`INSERT INTO library_characters (id,latest_version,created_at,updated_at) VALUES (?,?,?,?)`, lc.ID, lc.Version, now, now)
+	s.insertLibraryVersion(lc)
+	return lc
+}
+
+func (s *Store) ListLibraryCharacters() []*LibraryCharacter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rows, _ := s.db.Query(`SELECT id FROM library_characters ORDER BY created_at`)
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		_ = rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	rows.Close()
+	out := []*LibraryCharacter{}
+	for _, id := range ids {
+		if lc, ok := s.getLibraryCharacter(id, 0); ok {
+			out = append(out, lc)
+		}
+	}
+	return out
+}
+
+func (s *Store) GetLibraryCharacter(id string) (*LibraryCharacter, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.getLibraryCharacter(id, 0)
+}
+
+// UpdateLibraryCharacter never edits a version in place: it stores the merged
+// definition as a new version so pinned projects keep their look.
+func (s *Store) UpdateLibraryCharacter(id string, req CharacterUpdateRequest) (*LibraryCharacter, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lc, ok := s.getLibraryCharacter(id, 0)
+	if !ok {
+		return nil, false
+	}
+	if req.Name != "" {
+		lc.Name = req.Name
+	}
+	if req.Prompt != "" {
+		lc.Prompt = req.Prompt
+	}
+	if req.Personality != "" {
+		lc.Personality = req.Personality
+	}
+	if req.StyleNotes != "" {
+		lc.StyleNotes = req.StyleNotes
+	}
+	if req.Palette != nil {
+		lc.Palette = req.Palette
+	}
+	if req.ReferenceImages != nil {
+		lc.ReferenceImages = req.ReferenceImages
+	}
+	lc.Version++
+	lc.UpdatedAt = nowStamp()
+	s.insertLibraryVersion(lc)
+	_, _ = s.db.Exec(`UPDATE library_characters SET latest_version=?, updated_at=? WHERE id=?`, lc.Version, lc.UpdatedAt, id)
+	return lc, true
+}
+
+func (s *Store) ListLibraryCharacterVersions(id string) ([]*LibraryCharacter, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.getLibraryCharacter(id, 0); !ok {
+		return nil, false
+	}
+	rows, _ := s.db.Query(`SELECT `+libraryVersionColumns+` FROM library_character_versions WHERE character_id=? ORDER BY version`, id)
+	defer rows.Close()
+	out := []*LibraryCharacter{}
+	for rows.Next() {
+		if lc, err := scanLibraryVersion(rows); err == nil {
+			out = append(out, lc)
+		}
+	}
+	return out, true
+}
+
+// DeleteLibraryCharacter detaches every project that references the character
+// by copying the resolved definition into the project's own row first.
+func (s *Store) DeleteLibraryCharacter(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.getLibraryCharacter(id, 0); !ok {
+		return false
+	}
+	rows, _ := s.db.Query(`SELECT `+characterColumns+` FROM characters WHERE library_id=?`, id)
+	attached := []*Character{}
+	for rows.Next() {
+		if c, err := scanCharacter(rows); err == nil {
+			attached = append(attached, c)
+		}
+	}
+	rows.Close()
+	for _, c := range attached {
+		r := s.resolveLibraryCharacter(c)
+		_, _ = s.db.Exec(`UPDATE characters SET name=?, prompt=?, personality=?, palette=?, style_notes=?, reference_images=?, reference_image_url=?, library_id='', library_version=0 WHERE id=?`,
+			r.Name, r.Prompt, r.Personality, encodeList(r.Palette), r.StyleNotes, encodeList(r.ReferenceImages), r.ReferenceImageURL, c.ID,
+		)
+	}
+	_, _ = s.db.Exec(`DELETE FROM library_character_versions WHERE character_id=?`, id)
+	_, _ = s.db.Exec(`DELETE FROM library_characters WHERE id=?`, id)
+	return true
+}
+
+// attachLibraryCharacter adds a project character that references the library.
+// The project row only stores overrides, so library updates reach new
+// generations. LibraryVersion 0 follows the latest; any other value pins an
+// existing version. Caller holds s.mu.
+func (s *Store) attachLibraryCharacter(projectID string, req CharacterCreateRequest) (*Character, error) {
+	var latest int
+	if err := s.db.QueryRow(`SELECT latest_version FROM library_characters WHERE id=?`, req.LibraryCharacterID).Scan(&latest); err != nil {
+		return nil, errLibraryCharacterNotFound
+	}
+	if req.LibraryVersion < 0 || req.LibraryVersion > latest {
+		return nil, fmt.Errorf("libraryVersion must be 0 (latest) or between 1 and %d", latest)
+	}
+	sourceType := req.SourceType
+	if sourceType == "" {
+		sourceType = "HISTORY"
+	}
+	id := newID("char")
+	_, _ = s.db.Exec(`INSERT INTO characters (id,project_id,source_type,reference_image_url,status,name,prompt,personality,palette,style_notes,reference_images,library_id,library_version) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+		id, projectID, sourceType, "", "READY", req.Name, req.Prompt, req.Personality, encodeList(req.Palette), req.StyleNotes, encodeList(req.ReferenceImages), req.LibraryCharacterID, req.LibraryVersion,
+	)
+	c, err := scanCharacter(s.db.QueryRow(`SELECT `+characterColumns+` FROM characters WHERE id=?`, id))
+	if err != nil {
+		return nil, err
+	}
+	return s.resolveLibraryCharacter(c), nil
+}
+
+// resolveLibraryCharacter overlays project overrides on the referenced library
+// version. Characters without a library link are returned unchanged.
+func (s *Store) resolveLibraryCharacter(c *Character) *Character {
+	if c.LibraryCharacterID == "" {
+		return c
+	}
+	lc, ok := s.getLibraryCharacter(c.LibraryCharacterID, c.LibraryVersion)
+	if !ok {
+		return c
+	}
+	r := *c
+	r.LibraryVersion = lc.Version
+	if r.Name == "" {
+		r.Name = lc.Name
+	}
+	if r.Prompt == "" {
+		r.Prompt = lc.Prompt
+	}
+	if r.Personality == "" {
+		r.Personality = lc.Personality
+	}
+	if r.StyleNotes == "" {
+		r.StyleNotes = lc.StyleNotes
+	}
+	if len(r.Palette) == 0 {
+		r.Palette = nonNilList(lc.Palette)
+	}
+	if len(r.ReferenceImages) == 0 {
+		r.ReferenceImages = nonNilList(lc.ReferenceImages)
+		r.ReferenceImageURL = firstOf(r.ReferenceImages)
+	}
+	return &r
+}
@@ -0,0 +1,46 @@
+package api
+
+import "testing"
+
+func TestAttachLibraryCharacterVersion(t *testing.T) {
+	lc := store.CreateLibraryCharacter(CharacterUpdateRequest{Name: "Mochi", Prompt: "round cat"})
+	if _, ok := store.UpdateLibraryCharacter(lc.ID, CharacterUpdateRequest{Prompt: "round cat, blue scarf"}); !ok {
+		t.Fatal("update failed")
+	}
+	p := store.CreateProject("library versions", 8, "")
+
+	tests := []struct {
+		name    string
+		version int
+		ok      bool
+		pinned  bool
+		use     int
+	}{
+		{"latest", 0, true, false, 2},
+		{"first", 1, true, true, 1},
+		{"current", 2, true, true, 2},
+		{"negative", -1, false, false, 0},
+		{"past latest", 3, false, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := store.AddProjectCharacter(p.ID, CharacterCreateRequest{LibraryCharacterID: lc.ID, LibraryVersion: tt.version})
+			if (err == nil) != tt.ok {
+				t.Fatalf("err = %v, want ok %v", err, tt.ok)
+			}
+			if err != nil {
+				return
+			}
+			if c.LibraryPinned != tt.pinned || c.LibraryVersion != tt.use {
+				t.Fatalf("pinned %v version %d, want %v and %d", c.LibraryPinned, c.LibraryVersion, tt.pinned, tt.use)
+			}
+		})
+	}
+
+	if _, err := store.AddProjectCharacter(p.ID, CharacterCreateRequest{LibraryCharacterID: "lib_missing"}); err != errLibraryCharacterNotFound {
+		t.Fatalf("missing library character: err = %v", err)
+	}
+	if _, err := store.AddProjectCharacter("proj_missing", CharacterCreateRequest{Name: "x"}); err != errProjectNotFound {
+		t.Fatalf("missing project: err = %v", err)
+	}
+}
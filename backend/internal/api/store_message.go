@@ -0,0 +1,240 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font/opentype"
+)
+
+const (
+	// textAreasFile lists each item's text area in a message sticker export.
+	textAreasFile = "text_areas.json"
+
+	minTextAreaWidth  = 80
+	minTextAreaHeight = 40
+	// textAreaCoverage is the share of visible pixels above which the
+	// artwork counts as intruding on the text area.
+	textAreaCoverage = 0.02
+
+	defaultMessageText = "Your message"
+)
+
+func encodeTextArea(area *TextArea) string {
+	if area == nil {
+		return ""
+	}
+	b, _ := json.Marshal(area)
+	return string(b)
+}
+
+func decodeTextArea(v string) *TextArea {
+	if v == "" {
+		return nil
+	}
+	area := &TextArea{}
+	if err := json.Unmarshal([]byte(v), area); err != nil {
+		return nil
+	}
+	return area
+}
+
+func (a TextArea) rect() image.Rectangle {
+	return image.Rect(a.X, a.Y, a.X+a.Width, a.Y+a.Height)
+}
+
+// subjectArea is the part of a w x h canvas the artwork and caption are
+// laid out in: the whole canvas, or beside a message sticker's text area
+// the largest band that keeps clear of it.
+func subjectArea(area *TextArea, w, h int) image.Rectangle {
+	canvas := image.Rect(0, 0, w, h)
+	if area == nil {
+		return canvas
+	}
+	r := area.rect().Intersect(canvas)
+	if r.Empty() {
+		return canvas
+	}
+	best := image.Rectangle{}
+	for _, band := range []image.Rectangle{
+		image.Rect(0, 0, w, r.Min.Y),
+		image.Rect(0, r.Max.Y, w, h),
+		image.Rect(0, 0, r.Min.X, h),
+		image.Rect(r.Max.X, 0, w, h),
+	} {
+		if band.Dx()*band.Dy() > best.Dx()*best.Dy() {
+			best = band
+		}
+	}
+	if best.Empty() {
+		return canvas
+	}
+	return best
+}
+
+func validateTextArea(area TextArea, canvas fileRule) error {
+	if area.Width < minTextAreaWidth || area.Height < minTextAreaHeight {
+		return fmt.Errorf("text area must be at least %dx%d", minTextAreaWidth, minTextAreaHeight)
+	}
+	if !area.rect().In(image.Rect(0, 0, canvas.width, canvas.height)) {
+		return fmt.Errorf("text area must lie inside the %dx%d canvas", canvas.width, canvas.height)
+	}
+	return nil
+}
+
+// textAreaFor is the sticker's own text area or the product's default.
+func textAreaFor(st *Sticker, spec productSpec) *TextArea {
+	if spec.TextArea == nil {
+		return nil
+	}
+	if st != nil && st.TextArea != nil {
+		return st.TextArea
+	}
+	return spec.TextArea
+}
+
+// draftTextArea is the text area of the sticker made from a draft, for
+// prompts generated before or after the sticker exists. Caller holds s.mu.
+func (s *Store) draftTextArea(p *Project, d *Draft) *TextArea {
+	spec := specFor(p.Type)
+	if spec.TextArea == nil {
+		return nil
+	}
+	var v string
+	_ = s.db.QueryRow(`SELECT COALESCE(text_area,'') FROM stickers WHERE draft_id=? ORDER BY created_at LIMIT 1`, d.ID).Scan(&v)
+	if area := decodeTextArea(v); area != nil {
+		return area
+	}
+	return spec.TextArea
+}
+
+// textAreaPrompt asks the model to keep the text area empty, naming where
+// it sits on the canvas and how much of it it takes up.
+func textAreaPrompt(area TextArea, w, h int) string {
+	cx, cy := area.X+area.Width/2, area.Y+area.Height/2
+	horiz := "centre"
+	switch {
+	case cx < w/3:
+		horiz = "left"
+	case cx >= w*2/3:
+		horiz = "right"
+	}
+	vert := "middle"
+	switch {
+	case cy < h/3:
+		vert = "top"
+	case cy >= h*2/3:
+		vert = "bottom"
+	}
+	place := vert + "-" + horiz
+	if vert == "middle" && horiz == "centre" {
+		place = "central"
+	}
+	return fmt.Sprintf("Leave the %s area (about %d%% of the width and %d%% of the height) completely empty: plain transparent background with no character, objects or text, because the sender's message is written there",
+		place, area.Width*100/w, area.Height*100/h)
+}
+
+// withTextArea appends the text area instruction to an image prompt.
+func withTextArea(prompt string, area *TextArea, canvas fileRule) string {
+	if area == nil {
+		return prompt
+	}
+	return strings.TrimRight(prompt, ". ") + ". " + textAreaPrompt(*area, canvas.width, canvas.height)
+}
+
+// textAreaCovered is the share of the area's pixels the artwork shows in.
+func textAreaCovered(img *image.RGBA, area TextArea) float64 {
+	r := area.rect().Intersect(img.Bounds())
+	if r.Empty() {
+		return 0
+	}
+	visible := 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if img.Pix[img.PixOffset(x, y)+3] > marginAlpha {
+				visible++
+			}
+		}
+	}
+	return float64(visible) / float64(r.Dx()*r.Dy())
+}
+
+// messageSticker returns a sticker whose product type has text areas, with
+// its spec. Caller holds s.mu.
+func (s *Store) messageSticker(stickerID string) (*Sticker, productSpec, error) {
+	st, ok := s.getSticker(stickerID)
+	if !ok {
+		return nil, productSpec{}, errStickerNotFound
+	}
+	spec := s.projectSpec(st.ProjectID)
+	if spec.TextArea == nil {
+		return nil, productSpec{}, errors.New("text areas need the message product type")
+	}
+	return st, spec, nil
+}
+
+// UpdateTextArea sets where a message sticker's text goes.
+func (s *Store) UpdateTextArea(stickerID string, area TextArea) (*Sticker, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, spec, err := s.messageSticker(stickerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateTextArea(area, spec.Item); err != nil {
+		return nil, err
+	}
+	_, _ = s.db.Exec(`UPDATE stickers SET text_area=? WHERE id=?`, encodeTextArea(&area), stickerID)
+	st, _ := s.getSticker(stickerID)
+	return st, nil
+}
+
+// TextAreaPreview renders a message sticker with placeholder text in its
+// text area, set in the project's caption style.
+func (s *Store) TextAreaPreview(stickerID, text string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, spec, err := s.messageSticker(stickerID)
+	if err != nil {
+		return nil, err
+	}
+	style := s.getCaptionStyle(st.ProjectID)
+	caption := ""
+	if showCaption(st, style) {
+		caption = s.draftCaption(st.DraftID)
+	}
+	dst, err := s.drawSticker(st, style, caption)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(text) == "" {
+		text = defaultMessageText
+	}
+	if err := drawTextArea(dst, *textAreaFor(st, spec), text, style, s.captionFonts(style)); err != nil {
+		return nil, err
+	}
+	return encodePNG(dst)
+}
+
+// drawTextArea shades the text area and sets text centred inside it.
+func drawTextArea(dst *image.RGBA, area TextArea, text string, style CaptionStyle, fonts []*opentype.Font) error {
+	r := area.rect().Intersect(dst.Bounds())
+	draw.Draw(dst, r, image.NewUniform(color.NRGBA{R: 0x40, G: 0x80, B: 0xff, A: 0x30}), image.Point{}, draw.Over)
+	edge := image.NewUniform(color.NRGBA{R: 0x40, G: 0x80, B: 0xff, A: 0xc0})
+	for _, line := range []image.Rectangle{
+		image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+1),
+		image.Rect(r.Min.X, r.Max.Y-1, r.Max.X, r.Max.Y),
+		image.Rect(r.Min.X, r.Min.Y, r.Min.X+1, r.Max.Y),
+		image.Rect(r.Max.X-1, r.Min.Y, r.Max.X, r.Max.Y),
+	} {
+		draw.Draw(dst, line, edge, image.Point{}, draw.Over)
+	}
+	style.Position = "center"
+	style.Vertical = false
+	return drawCaption(dst.SubImage(r).(*image.RGBA), text, style, fonts)
+}
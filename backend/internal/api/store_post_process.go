@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// getPostProcess returns the project's post-processing stages with defaults
+// filled in. Caller holds s.mu.
+func (s *Store) getPostProcess(projectID string) PostProcessConfig {
+	cfg := defaultPostProcess()
+	var raw string
+	_ = s.db.QueryRow(`SELECT COALESCE(post_process,'') FROM projects WHERE id=?`, projectID).Scan(&raw)
+	if raw != "" {
+		_ = json.Unmarshal([]byte(raw), &cfg)
+	}
+	return cfg
+}
+
+func (s *Store) GetPostProcess(projectID string) (*PostProcessConfig, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.getProject(projectID); !ok {
+		return nil, false
+	}
+	cfg := s.getPostProcess(projectID)
+	return &cfg, true
+}
+
+// UpdatePostProcess merges the fields present in req into the project's
+// stages. The new settings apply the next time backgrounds are removed.
+func (s *Store) UpdatePostProcess(projectID string, req PostProcessUpdateRequest) (*PostProcessConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.getProject(projectID); !ok {
+		return nil, errProjectNotFound
+	}
+	cfg := s.getPostProcess(projectID)
+	if err := mergeStage(req.Defringe, &cfg.Defringe); err != nil {
+		return nil, errors.New("defringe: " + err.Error())
+	}
+	if err := mergeStage(req.Trim, &cfg.Trim); err != nil {
+		return nil, errors.New("trim: " + err.Error())
+	}
+	if err := mergeStage(req.Outline, &cfg.Outline); err != nil {
+		return nil, errors.New("outline: " + err.Error())
+	}
+	canvas := s.projectSpec(projectID).Item
+	side := canvas.width
+	if canvas.height < side {
+		side = canvas.height
+	}
+	if err := validatePostProcess(cfg, side); err != nil {
+		return nil, err
+	}
+	raw, _ := json.Marshal(cfg)
+	_, _ = s.db.Exec(`UPDATE projects SET post_process=? WHERE id=?`, string(raw), projectID)
+	return &cfg, nil
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"errors"
+	"strings"
+
+	"example.com/app/internal/ai"
+)
+
+const (
+	promptScopeGlobal = "global"
+	promptKindDrafts  = "drafts"
+	promptKindImage   = "image"
+)
+
+// samplePromptVars is used to check that a template renders before it is
+// stored.
+var samplePromptVars = ai.PromptVars{
+	Theme:     "Office cats",
+	Character: "A round orange cat",
+	Locale:    "ja",
+	Style:     "Chibi",
+	Index:     1,
+	Count:     8,
+	Caption:   "おはよう",
+	Prompt:    "Cat waving good morning",
+}
+
+func defaultPromptTemplate(kind string) string {
+	if kind == promptKindImage {
+		return ai.DefaultImageTemplate
+	}
+	return ai.DefaultDraftsTemplate
+}
+
+func orDefault(v, fallback string) string {
+	if v != "" {
+		return v
+	}
+	return fallback
+}
+
+func validPromptKind(kind string) bool {
+	return kind == promptKindDrafts || kind == promptKindImage
+}
+
+// getPromptTemplate resolves the template in effect for a scope: the
+// project's own, then the global one, then the built-in default. Caller
+// holds s.mu.
+func (s *Store) getPromptTemplate(scope, kind string) PromptTemplate {
+	scopes := []string{scope}
+	if scope != promptScopeGlobal {
+		scopes = append(scopes, promptScopeGlobal)
+	}
+	for _, sc := range scopes {
+		var body, updatedAt string
+		err := s.db.QueryRow(`SELECT body,COALESCE(updated_at,'') FROM prompt_templates WHERE scope=? AND kind=?`, sc, kind).Scan(&body, &updatedAt)
+		if err == nil && body != "" {
+			source := "project"
+			if sc == promptScopeGlobal {
+				source = "global"
+			}
+			return PromptTemplate{Kind: kind, Body: body, Source: source, UpdatedAt: updatedAt}
+		}
+	}
+	return PromptTemplate{Kind: kind, Body: defaultPromptTemplate(kind), Source: "default"}
+}
+
+func (s *Store) ListPromptTemplates(scope string) []PromptTemplate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return []PromptTemplate{
+		s.getPromptTemplate(scope, promptKindDrafts),
+		s.getPromptTemplate(scope, promptKindImage),
+	}
+}
+
+// UpdatePromptTemplates stores templates for a scope after checking that
+// they parse and render against sample variables.
+func (s *Store) UpdatePromptTemplates(scope string, req PromptTemplatesUpdateRequest) error {
+	updates := map[string]*string{promptKindDrafts: req.Drafts, promptKindImage: req.Image}
+	for kind, body := range updates {
+		if body == nil || strings.TrimSpace(*body) == "" {
+			continue
+		}
+		if _, err := ai.RenderPrompt(*body, samplePromptVars); err != nil {
+			return errors.New(kind + " template: " + err.Error())
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for kind, body := range updates {
+		if body == nil {
+			continue
+		}
+		if strings.TrimSpace(*body) == "" {
+			_, _ = s.db.Exec(`DELETE FROM prompt_templates WHERE scope=? AND kind=?`, scope, kind)
+			continue
+		}
+		_, _ = s.db.Exec(`INSERT INTO prompt_templates (scope,kind,body,updated_at) VALUES (?,?,?,?)
+			ON CONFLICT(scope,kind) DO UPDATE SET body=excluded.body, updated_at=excluded.updated_at`,
+			scope, kind, *body, nowStamp(),
+		)
+	}
+	return nil
+}
+
+// RenderPrompt previews a template with the values a real generation would
+// use for the project and draft, overridden by any vars in the request.
+func (s *Store) RenderPrompt(req PromptRenderRequest) (*PromptRenderResponse, error) {
+	kind := req.Kind
+	if kind == "" {
+		kind = promptKindImage
+	}
+	if !validPromptKind(kind) {
+		return nil, errors.New("kind must be drafts or image")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scope := promptScopeGlobal
+	vars := ai.PromptVars{}
+	if req.ProjectID != "" {
+		p, ok := s.getProject(req.ProjectID)
+		if !ok {
+			return nil, errors.New("project not found")
+		}
+		scope = p.ID
+		vars = ai.PromptVars{
+			Theme:     p.Theme,
+			Character: s.getCastInput(p.ID, nil).Describe(),
+			Locale:    p.Locale,
+			Style:     stylePresetName(p.StylePreset),
+			Count:     p.StickerCount,
+		}
+		if req.DraftID != "" {
+			d, err := scanDraft(s.db.QueryRow(`SELECT `+draftColumns+` FROM drafts WHERE id=? AND project_id=?`, req.DraftID, p.ID))
+			if err != nil {
+				return nil, errors.New("draft not found")
+			}
+			imageReq := s.draftImageRequest(p, d, "")
+			vars = imageReq.Vars
+			vars.Prompt = imageReq.Prompt
+			vars.Character = s.getCastInput(p.ID, d.CharacterIDs).Describe()
+		}
+	}
+	if v := req.Vars; v != nil {
+		vars.Theme = orDefault(v.Theme, vars.Theme)
+		vars.Character = orDefault(v.Character, vars.Character)
+		vars.Locale = orDefault(v.Locale, vars.Locale)
+		vars.Style = orDefault(v.Style, vars.Style)
+		vars.Caption = orDefault(v.Caption, vars.Caption)
+		vars.Prompt = orDefault(v.Prompt, vars.Prompt)
+		if v.Index > 0 {
+			vars.Index = v.Index
+		}
+		if v.Count > 0 {
+			vars.Count = v.Count
+		}
+	}
+	tpl := req.Template
+	if tpl == "" {
+		tpl = s.getPromptTemplate(scope, kind).Body
+	}
+	out, err := ai.RenderPrompt(tpl, vars)
+	if err != nil {
+		return nil, err
+	}
+	return &PromptRenderResponse{Kind: kind, Prompt: out}, nil
+}
+
+// verbatimImageRequest sends prompt exactly as given, bypassing the image
+// template; the cast's reference images are still attached.
+func verbatimImageRequest(req ai.ImageRequest, prompt string) ai.ImageRequest {
+	req.Prompt = prompt
+	req.Template = "{{.Prompt}}"
+	return req
+}
+
+// draftImageRequest builds the image request for a draft: the styled prompt,
+// asking to keep any text area clear, plus the project's image template and
+// its variables. Caller holds s.mu.
+func (s *Store) draftImageRequest(p *Project, d *Draft, provider string) ai.ImageRequest {
+	presetID := resolveStylePreset(p.StylePreset, d.StylePreset)
+	req := styledImageRequest(d.ImagePrompt, presetID, provider)
+	req.Prompt = withTextArea(req.Prompt, s.draftTextArea(p, d), specFor(p.Type).Item)
+	req.Template = s.getPromptTemplate(p.ID, promptKindImage).Body
+	req.Vars = ai.PromptVars{
+		Theme:   p.Theme,
+		Locale:  p.Locale,
+		Style:   stylePresetName(presetID),
+		Index:   d.Index,
+		Count:   p.StickerCount,
+		Caption: d.Caption,
+	}
+	return req
+}
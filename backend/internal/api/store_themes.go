@@ -0,0 +1,56 @@
+package api
+
+import (
+	"log"
+	"strings"
+
+	"example.com/app/internal/ai"
+)
+
+// SuggestThemes asks the project's text provider for ranked themes and falls
+// back to the curated list when no credentials are stored or the call fails.
+func (s *Store) SuggestThemes(projectID string, req ThemeSuggestRequest) (*ThemeSuggestResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.getProject(projectID)
+	if !ok {
+		return nil, false
+	}
+	keywords := append([]string{}, req.Keywords...)
+	if seed := strings.TrimSpace(req.Seed); seed != "" {
+		keywords = append(keywords, seed)
+	}
+	if p.Theme != "" {
+		keywords = append(keywords, p.Theme)
+	}
+	themeReq := ai.ThemeRequest{
+		Locale:   req.Locale,
+		Market:   req.Market,
+		Keywords: keywords,
+		Count:    req.Count,
+	}
+	charInput := s.getCharacterInput(projectID)
+
+	items := []ai.ThemeSuggestion{}
+	source := "curated"
+	textProvider, textModel := resolveProviderModel(p.TextProvider, p.TextModel, p.AIProvider, p.AIModel)
+	if _, ok := s.getAICredentials(projectID); ok && textProvider != "" {
+		pipeline, _ := s.getTaskPipeline(projectID, textProvider, textModel)
+		list, err := pipeline.SuggestThemes(themeReq, charInput)
+		if err != nil {
+			log.Printf("theme suggest project=%s provider=%s err=%v", projectID, textProvider, err)
+		} else if len(list) > 0 {
+			items = list
+			source = "ai"
+		}
+	}
+	if len(items) == 0 {
+		items = ai.SuggestCuratedThemes(themeReq, charInput)
+	}
+
+	res := &ThemeSuggestResponse{Items: items, Source: source}
+	for _, it := range items {
+		res.Suggestions = append(res.Suggestions, it.Theme)
+	}
+	return res, true
+}
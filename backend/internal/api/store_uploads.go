@@ -0,0 +1,140 @@
+package api
+
+import (
+	"database/sql"
+
+	"example.com/app/internal/ai"
+)
+
+// AddCharacterReferences appends uploaded reference images to the project's
+// lead character, creating an UPLOAD character when the project has none.
+func (s *Store) AddCharacterReferences(projectID string, urls []string) (*Character, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.getProject(projectID); !ok {
+		return nil, false
+	}
+	c, ok := s.getProjectCharacter(projectID)
+	if !ok {
+		created, err := s.insertCharacter(projectID, CharacterCreateRequest{SourceType: "UPLOAD", ReferenceImages: urls})
+		if err != nil {
+			return nil, false
+		}
+		s.setPrimaryCharacter(projectID, created.ID)
+		return created, true
+	}
+	// extend the stored references, not the library ones resolved into c
+	row, err := scanCharacter(s.db.QueryRow(`SELECT `+characterColumns+` FROM characters WHERE id=?`, c.ID))
+	if err != nil {
+		return nil, false
+	}
+	refs := row.ReferenceImages
+	if len(refs) == 0 {
+		refs = c.ReferenceImages
+	}
+	return s.updateCharacter(c.ID, CharacterUpdateRequest{ReferenceImages: append(append([]string{}, refs...), urls...)})
+}
+
+// ReplaceStickerImage puts a user-made image into a sticker slot. It is kept
+// as a candidate of the draft and goes through background removal like AI
+// output.
+func (s *Store) ReplaceStickerImage(stickerID, imageURL string) (*Sticker, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var projectID, draftID string
+	if err := s.db.QueryRow(`SELECT project_id,draft_id FROM stickers WHERE id=?`, stickerID).Scan(&projectID, &draftID); err != nil {
+		return nil, false
+	}
+	if draftID != "" {
+		s.insertCandidate(projectID, draftID, ai.GeneratedImage{URL: imageURL}, true)
+	}
+	_, _ = s.db.Exec(`UPDATE stickers SET image_url=?, transparent_url=?, status=?, source=?, generation='' WHERE id=?`, imageURL, "", "READY", "UPLOAD", stickerID)
+	return s.getSticker(stickerID)
+}
+
+// UploadStickers creates one sticker per uploaded image. Images fill the given
+// drafts in order; extra images get new drafts appended to the project. Every
+// draft is checked before anything is written and the rows go in one
+// transaction, so a failed upload leaves the project as it was.
+func (s *Store) UploadStickers(projectID string, urls []string, draftIDs []string, captions []string) ([]*Sticker, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.getProject(projectID); !ok {
+		return nil, false
+	}
+	for i := range urls {
+		if i < len(draftIDs) && draftIDs[i] != "" {
+			var owner string
+			if err := s.db.QueryRow(`SELECT project_id FROM drafts WHERE id=?`, draftIDs[i]).Scan(&owner); err != nil || owner != projectID {
+				return nil, false
+			}
+		}
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, false
+	}
+	defer tx.Rollback()
+	var nextIdx int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(idx),0) FROM drafts WHERE project_id=?`, projectID).Scan(&nextIdx); err != nil {
+		return nil, false
+	}
+	ids := make([]string, 0, len(urls))
+	for i, url := range urls {
+		draftID := ""
+		if i < len(draftIDs) {
+			draftID = draftIDs[i]
+		}
+		if draftID == "" {
+			nextIdx++
+			caption := ""
+			if i < len(captions) {
+				caption = captions[i]
+			}
+			draftID = newID("draft")
+			if _, err := tx.Exec(`INSERT INTO drafts (id,project_id,idx,caption,image_prompt,status) VALUES (?,?,?,?,?,?)`,
+				draftID, projectID, nextIdx, caption, "", "DRAFT",
+			); err != nil {
+				return nil, false
+			}
+		}
+		if _, err := insertCandidateTx(tx, projectID, draftID, ai.GeneratedImage{URL: url}, true); err != nil {
+			return nil, false
+		}
+
+		var stickerID string
+		err := tx.QueryRow(`SELECT id FROM stickers WHERE draft_id=? ORDER BY created_at DESC LIMIT 1`, draftID).Scan(&stickerID)
+		switch {
+		case err == sql.ErrNoRows:
+			stickerID = newID("stk")
+			_, err = tx.Exec(`INSERT INTO stickers (id,project_id,draft_id,image_url,transparent_url,status,created_at,source) VALUES (?,?,?,?,?,?,?,?)`,
+				stickerID, projectID, draftID, url, "", "READY", nowStamp(), "UPLOAD",
+			)
+		case err == nil:
+			_, err = tx.Exec(`UPDATE stickers SET image_url=?, transparent_url=?, status=?, source=?, generation='' WHERE id=?`, url, "", "READY", "UPLOAD", stickerID)
+		}
+		if err != nil {
+			return nil, false
+		}
+		ids = append(ids, stickerID)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false
+	}
+	out := []*Sticker{}
+	for _, id := range ids {
+		if st, ok := s.getSticker(id); ok {
+			out = append(out, st)
+		}
+	}
+	return out, true
+}
+
+// getSticker expects the caller to hold s.mu.
+func (s *Store) getSticker(stickerID string) (*Sticker, bool) {
+	st, err := scanSticker(s.db.QueryRow(`SELECT `+stickerColumns+` FROM stickers WHERE id=?`, stickerID))
+	if err != nil {
+		return nil, false
+	}
+	return st, true
+}
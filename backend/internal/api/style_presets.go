@@ -0,0 +1,172 @@
+package api
+
+import (
+	"strings"
+
+	"example.com/app/internal/ai"
+)
+
+// StylePreset is a named look applied to every image prompt of a set.
+// ProviderParams are merged into the provider request keyed by provider id;
+// aliases such as "chatgpt" use their canonical id. For Replicate they may
+// also name the inputs that take reference images (ai.ParamReferenceImage
+// and ai.ParamReferenceImages).
+type StylePreset struct {
+	ID             string                            `json:"id"`
+	Name           string                            `json:"name"`
+	PromptPrefix   string                            `json:"promptPrefix"`
+	PromptSuffix   string                            `json:"promptSuffix"`
+	NegativePrompt string                            `json:"negativePrompt"`
+	ProviderParams map[string]map[string]interface{} `json:"providerParams"`
+}
+
+// stylePresetNone disables presets for a draft even when the project has one.
+const stylePresetNone = "none"
+
+const stickerNegativePrompt = "text, watermark, signature, cropped, busy background"
+
+func ListStylePresets() []StylePreset {
+	return []StylePreset{
+		{
+			ID:             "chibi",
+			Name:           "Chibi",
+			PromptPrefix:   "cute chibi sticker illustration, big head small body, expressive face,",
+			PromptSuffix:   "clean bold outlines, soft cel shading, plain white background",
+			NegativePrompt: stickerNegativePrompt + ", realistic proportions, photo",
+			ProviderParams: map[string]map[string]interface{}{
+				"openai":    {"style": "vivid"},
+				"replicate": {"guidance_scale": 7.5},
+			},
+		},
+		{
+			ID:             "flat-vector",
+			Name:           "Flat vector",
+			PromptPrefix:   "flat vector sticker art,",
+			PromptSuffix:   "solid colour fills, no gradients, geometric shapes, crisp edges, plain white background",
+			NegativePrompt: stickerNegativePrompt + ", gradient, texture, 3d, photo",
+			ProviderParams: map[string]map[string]interface{}{
+				"openai":    {"style": "natural"},
+				"replicate": {"guidance_scale": 8},
+			},
+		},
+		{
+			ID:             "watercolor",
+			Name:           "Watercolor",
+			PromptPrefix:   "hand painted watercolor sticker,",
+			PromptSuffix:   "soft washes, visible paper grain inside the subject, gentle ink outline, plain white background",
+			NegativePrompt: stickerNegativePrompt + ", hard vector edges, 3d render",
+			ProviderParams: map[string]map[string]interface{}{
+				"openai":    {"style": "natural"},
+				"replicate": {"guidance_scale": 6.5},
+			},
+		},
+		{
+			ID:             "pixel-art",
+			Name:           "Pixel art",
+			PromptPrefix:   "16-bit pixel art sticker,",
+			PromptSuffix:   "limited palette, crisp pixels, no anti-aliasing, plain white background",
+			NegativePrompt: stickerNegativePrompt + ", blur, smooth gradients, photo",
+			ProviderParams: map[string]map[string]interface{}{
+				"openai":    {"style": "vivid"},
+				"replicate": {"guidance_scale": 9},
+			},
+		},
+		{
+			ID:             "3d-clay",
+			Name:           "3D clay",
+			PromptPrefix:   "3d clay figure sticker, plasticine look,",
+			PromptSuffix:   "soft studio lighting, rounded shapes, subtle fingerprints, plain white background",
+			NegativePrompt: stickerNegativePrompt + ", flat 2d, line art, photo of a person",
+			ProviderParams: map[string]map[string]interface{}{
+				"openai":    {"style": "vivid", "quality": "hd"},
+				"replicate": {"guidance_scale": 7},
+			},
+		},
+		{
+			ID:             "marker-line",
+			Name:           "Marker line art",
+			PromptPrefix:   "hand drawn marker sticker,",
+			PromptSuffix:   "thick black marker outlines, simple flat colouring, playful doodle style, plain white background",
+			NegativePrompt: stickerNegativePrompt + ", 3d render, photo, detailed shading",
+			ProviderParams: map[string]map[string]interface{}{
+				"openai":    {"style": "natural"},
+				"replicate": {"guidance_scale": 7},
+			},
+		},
+	}
+}
+
+func findStylePreset(id string) (StylePreset, bool) {
+	for _, p := range ListStylePresets() {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return StylePreset{}, false
+}
+
+func validStylePreset(id string) bool {
+	if id == "" || id == stylePresetNone {
+		return true
+	}
+	_, ok := findStylePreset(id)
+	return ok
+}
+
+// resolveStylePreset picks the draft override over the project preset.
+func resolveStylePreset(projectPreset, draftPreset string) string {
+	if draftPreset == stylePresetNone {
+		return ""
+	}
+	if draftPreset != "" {
+		return draftPreset
+	}
+	if projectPreset == stylePresetNone {
+		return ""
+	}
+	return projectPreset
+}
+
+// stylePresetName is the human-readable name exposed to prompt templates.
+func stylePresetName(id string) string {
+	if preset, ok := findStylePreset(id); ok {
+		return preset.Name
+	}
+	return ""
+}
+
+// styledImageRequest wraps a draft prompt with the preset's prefix, suffix,
+// negative prompt and the parameters for the given provider.
+func styledImageRequest(prompt, presetID, provider string) ai.ImageRequest {
+	req := ai.ImageRequest{Prompt: prompt}
+	preset, ok := findStylePreset(presetID)
+	if !ok {
+		return req
+	}
+	parts := []string{}
+	for _, part := range []string{preset.PromptPrefix, prompt, preset.PromptSuffix} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	req.Prompt = strings.Join(parts, " ")
+	req.NegativePrompt = preset.NegativePrompt
+	if params, ok := preset.ProviderParams[ai.CanonicalProvider(provider)]; ok {
+		req.Params = map[string]interface{}{}
+		for k, v := range params {
+			req.Params[k] = v
+		}
+	}
+	return req
+}
+
+func (s *Store) UpdateProjectStyle(projectID string, req ProjectStyleRequest) (*Project, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res, _ := s.db.Exec(`UPDATE projects SET style_preset=? WHERE id=?`, req.StylePreset, projectID)
+	aff, _ := res.RowsAffected()
+	if aff == 0 {
+		return nil, false
+	}
+	return s.getProject(projectID)
+}
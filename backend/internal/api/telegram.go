@@ -0,0 +1,294 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	exportTargetLine     = "line"
+	exportTargetTelegram = "telegram"
+)
+
+// Telegram static sticker set limits.
+const (
+	telegramSide          = 512
+	telegramMaxBytes      = 512 << 10
+	telegramThumbSide     = 100
+	telegramThumbMaxBytes = 128 << 10
+	telegramMaxStickers   = 120
+	telegramMaxEmoji      = 20
+	telegramMaxName       = 64
+	telegramMaxTitle      = 64
+	// defaultStickerEmoji stands in for drafts without emoji.
+	defaultStickerEmoji = "🙂"
+
+	formatWebP = "webp"
+	formatPNG  = "png"
+)
+
+// telegramPack is the set as the Bot API's createNewStickerSet takes it.
+type telegramPack struct {
+	Name   string
+	Title  string
+	Format string
+}
+
+type telegramSticker struct {
+	Sticker   string   `json:"sticker"`
+	Format    string   `json:"format"`
+	EmojiList []string `json:"emoji_list"`
+}
+
+// telegramPackFor fills the pack settings from the request, defaulting to
+// WebP and a name and title derived from the project title.
+func telegramPackFor(p *Project, req ExportRequest) (telegramPack, error) {
+	pack := telegramPack{Name: req.PackName, Title: req.PackTitle, Format: req.Format}
+	if pack.Format == "" {
+		pack.Format = formatWebP
+	}
+	if pack.Format != formatWebP && pack.Format != formatPNG {
+		return pack, errors.New("format must be webp or png")
+	}
+	if pack.Title == "" {
+		pack.Title = p.Title
+	}
+	if pack.Name == "" {
+		pack.Name = telegramPackName(pack.Title)
+	}
+	return pack, nil
+}
+
+// telegramPackName turns a title into a set name: lower-case letters,
+// digits and single underscores, starting with a letter.
+func telegramPackName(title string) string {
+	name := slugName(title)
+	if name == "" {
+		return "stickers"
+	}
+	if unicode.IsDigit(rune(name[0])) {
+		name = "pack_" + name
+	}
+	return name
+}
+
+// slugName lower-cases s and keeps its ASCII letters and digits, joining
+// the runs between them with single underscores.
+func slugName(s string) string {
+	b := &strings.Builder{}
+	underscore := false
+	for _, r := range strings.ToLower(s) {
+		if r < 128 && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+			underscore = false
+			continue
+		}
+		if b.Len() > 0 && !underscore {
+			b.WriteByte('_')
+			underscore = true
+		}
+	}
+	return strings.TrimRight(b.String(), "_")
+}
+
+func validTelegramName(name string) bool {
+	if name == "" || len(name) > telegramMaxName || !unicode.IsLetter(rune(name[0])) || strings.Contains(name, "__") || strings.HasSuffix(name, "_") {
+		return false
+	}
+	for _, r := range name {
+		if r >= 128 || !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// hasBotSuffix reports whether name ends in _by_<bot username>, the suffix
+// Telegram requires of sets created through a bot. Bot usernames end in
+// "bot".
+func hasBotSuffix(name string) bool {
+	i := strings.LastIndex(name, "_by_")
+	if i <= 0 {
+		return false
+	}
+	bot := strings.ToLower(name[i+len("_by_"):])
+	return len(bot) > len("bot") && strings.HasSuffix(bot, "bot")
+}
+
+// cleanEmoji trims the entries and drops empty ones.
+func cleanEmoji(list []string) []string {
+	out := []string{}
+	for _, e := range list {
+		if e = strings.TrimSpace(e); e != "" {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// isEmoji accepts one emoji: pictographs and symbols, optionally joined
+// with ZWJ, variation selectors, skin tones, tags or a keycap.
+func isEmoji(s string) bool {
+	pictograph := false
+	for _, r := range s {
+		switch {
+		case r >= 0x1F000 && r <= 0x1FAFF, r >= 0x2190 && r <= 0x2BFF, r == 0x00A9, r == 0x00AE,
+			r == 0x203C, r == 0x2049, r == 0x2122, r == 0x2139, r == 0x3030, r == 0x303D, r == 0x3297, r == 0x3299:
+			pictograph = true
+		case r == 0x200D, r == 0xFE0F, r == 0xFE0E, r == 0x20E3, r >= 0xE0020 && r <= 0xE007F:
+		case (r >= '0' && r <= '9') || r == '#' || r == '*':
+			if !strings.ContainsRune(s, 0x20E3) {
+				return false
+			}
+			pictograph = true
+		default:
+			return false
+		}
+	}
+	return pictograph
+}
+
+// checkEmoji validates a sticker's emoji and returns the list to export:
+// the default emoji when there are none, and the first max when there are
+// too many and trim is set; otherwise too many is an error.
+func checkEmoji(fv *FileValidation, list []string, max int, trim bool) []string {
+	add := func(severity, message string) {
+		fv.Findings = append(fv.Findings, ValidationFinding{Rule: "emoji", Severity: severity, Message: message})
+	}
+	if len(list) == 0 {
+		add(severityWarning, "no emoji set on the draft; using "+defaultStickerEmoji)
+		return []string{defaultStickerEmoji}
+	}
+	if len(list) > max {
+		if !trim {
+			add(severityError, fmt.Sprintf("%d emoji, at most %d", len(list), max))
+		} else {
+			add(severityWarning, fmt.Sprintf("%d emoji, only the first %d are used", len(list), max))
+			list = list[:max]
+		}
+	}
+	for _, e := range list {
+		if !isEmoji(e) {
+			add(severityError, fmt.Sprintf("%q is not a single emoji", e))
+		}
+	}
+	return list
+}
+
+// buildTelegramZip renders the set as a Telegram sticker pack: every
+// sticker scaled so its longer side is 512px, a 100x100 thumbnail from the
+// first one and a manifest with each sticker's emoji. emoji maps draft IDs
+// to their emoji; notes are findings already made on the set.
+func buildTelegramZip(projectID string, pack telegramPack, stickers []Sticker, emoji map[string][]string, notes []ValidationFinding) ([]byte, *ValidationReport, error) {
+	if len(stickers) == 0 {
+		return nil, nil, errors.New("no stickers")
+	}
+	report := newValidationReport(projectID, exportTargetTelegram)
+	report.note(notes)
+	sorted := sortStickers(stickers)
+	if len(sorted) > telegramMaxStickers {
+		report.add(severityError, "sticker-count", fmt.Sprintf("set has %d stickers; Telegram sets hold at most %d", len(sorted), telegramMaxStickers))
+	}
+	if !validTelegramName(pack.Name) {
+		report.add(severityError, "pack-name", fmt.Sprintf("pack name %q must be 1-%d letters, digits and single underscores, starting with a letter", pack.Name, telegramMaxName))
+	} else if !hasBotSuffix(pack.Name) {
+		report.add(severityWarning, "pack-name", "pack name must end in _by_<bot username> when the set is created through a bot")
+	}
+	if n := len([]rune(pack.Title)); n == 0 || n > telegramMaxTitle {
+		report.add(severityError, "pack-title", fmt.Sprintf("pack title must be 1-%d characters", telegramMaxTitle))
+	}
+
+	files := []exportFile{}
+	manifest := []telegramSticker{}
+	for i, s := range sorted {
+		name := fmt.Sprintf("%02d.%s", i+1, pack.Format)
+		data, err := telegramImage(stickerURL(s), pack.Format, 0)
+		if err != nil {
+			report.addFile(unreadableFile(name, s.ID, err))
+			continue
+		}
+		fv := validateTargetFile(name, s.ID, data, telegramStickerRule(pack.Format))
+		list := checkEmoji(&fv, emoji[s.DraftID], telegramMaxEmoji, false)
+		report.addFile(fv)
+		files = append(files, exportFile{name: name, stickerID: s.ID, data: data})
+		manifest = append(manifest, telegramSticker{Sticker: name, Format: "static", EmojiList: list})
+	}
+
+	thumb := "thumbnail." + pack.Format
+	if data, err := telegramImage(stickerURL(sorted[0]), pack.Format, telegramThumbSide); err != nil {
+		report.addFile(unreadableFile(thumb, "", err))
+	} else {
+		report.addFile(validateTargetFile(thumb, "", data, targetRule{format: pack.Format, width: telegramThumbSide, height: telegramThumbSide, maxBytes: telegramThumbMaxBytes}))
+		files = append(files, exportFile{name: thumb, data: data})
+	}
+
+	files = append(files,
+		exportFile{name: "README.txt", data: []byte(telegramReadme(pack))},
+		jsonFile("manifest.json", map[string]interface{}{
+			"name":             pack.Name,
+			"title":            pack.Title,
+			"sticker_type":     "regular",
+			"stickers":         manifest,
+			"thumbnail":        thumb,
+			"thumbnail_format": "static",
+		}),
+		jsonFile("report.json", report),
+	)
+	data, err := zipFiles(files)
+	if err != nil {
+		return nil, nil, err
+	}
+	report.ZipBytes = len(data)
+	return data, report, nil
+}
+
+func telegramStickerRule(format string) targetRule {
+	return targetRule{format: format, width: telegramSide, height: telegramSide, oneSide: true, maxBytes: telegramMaxBytes}
+}
+
+// telegramImage encodes a sticker for the pack: scaled so its longer side
+// is 512px, or fitted inside a square thumbSide canvas when thumbSide is
+// set.
+func telegramImage(url, format string, thumbSide int) ([]byte, error) {
+	src, err := loadImage(url)
+	if err != nil {
+		return nil, err
+	}
+	w, h := thumbSide, thumbSide
+	if thumbSide == 0 {
+		w, h = scaleToSide(src.Bounds().Dx(), src.Bounds().Dy(), telegramSide)
+	}
+	dst, err := fitImage(src, w, h)
+	if err != nil {
+		return nil, err
+	}
+	if format == formatPNG {
+		return encodePNG(dst)
+	}
+	return encodeWebP(dst)
+}
+
+// scaleToSide scales w x h so the longer side becomes side.
+func scaleToSide(w, h, side int) (int, int) {
+	if w < h {
+		h, w = scaleToSide(h, w, side)
+		return w, h
+	}
+	short := (h*side + w/2) / w
+	if short < 1 {
+		short = 1
+	}
+	return side, short
+}
+
+func telegramReadme(pack telegramPack) string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "Telegram Sticker Pack Export\n")
+	fmt.Fprintf(b, "- 01.%s ...: static stickers, one side 512px, up to %s each\n", pack.Format, formatBytes(telegramMaxBytes))
+	fmt.Fprintf(b, "- thumbnail.%s: %dx%d set thumbnail\n", pack.Format, telegramThumbSide, telegramThumbSide)
+	fmt.Fprintf(b, "- manifest.json: set name %q, title and each sticker's emoji, as createNewStickerSet takes them\n", pack.Name)
+	b.WriteString("\nUpload the stickers with @Stickers, or create the set through the Bot API from manifest.json.\n")
+	return b.String()
+}
@@ -1,5 +1,11 @@
 package api
 
+import (
+	"encoding/json"
+
+	"example.com/app/internal/ai"
+)
+
 type ProjectStatus string
 
 type Project struct {
@@ -18,15 +24,25 @@ type Project struct {
 	ImageModel    string `json:"imageModel"`
 	BgProvider    string `json:"bgProvider"`
 	BgModel       string `json:"bgModel"`
+
+	CandidateCount int    `json:"candidateCount"`
+	StylePreset    string `json:"stylePreset"`
+	Locale         string `json:"locale"`
+	// Type is the LINE product: "sticker", "animated", "emoji", "popup",
+	// "effect" or "message".
+	Type string `json:"type"`
 }
 
 type ProjectCreateRequest struct {
 	Title        string `json:"title"`
 	StickerCount int    `json:"stickerCount"`
+	Type         string `json:"type"`
 }
 
 type ProjectUpdateRequest struct {
-	Theme string `json:"theme"`
+	Theme  string `json:"theme"`
+	Locale string `json:"locale"`
+	Type   string `json:"type"`
 }
 
 type AIConfigUpdateRequest struct {
@@ -41,6 +57,8 @@ type AIPipelineConfigRequest struct {
 	ImageModel    string `json:"imageModel"`
 	BgProvider    string `json:"bgProvider"`
 	BgModel       string `json:"bgModel"`
+
+	CandidateCount int `json:"candidateCount"`
 }
 
 type VerifiedProvidersResponse struct {
@@ -54,30 +72,96 @@ type AICredentialsRequest struct {
 }
 
 type CharacterCreateRequest struct {
-	SourceType        string `json:"sourceType"`
-	Prompt            string `json:"prompt"`
-	ReferenceImageURL string `json:"referenceImageUrl"`
+	SourceType        string   `json:"sourceType"`
+	Name              string   `json:"name"`
+	Prompt            string   `json:"prompt"`
+	Personality       string   `json:"personality"`
+	Palette           []string `json:"palette"`
+	StyleNotes        string   `json:"styleNotes"`
+	ReferenceImageURL string   `json:"referenceImageUrl"`
+	ReferenceImages   []string `json:"referenceImages"`
+
+	// LibraryCharacterID attaches a library character by reference. A zero
+	// LibraryVersion follows the latest version; any other value pins that
+	// version and must exist.
+	LibraryCharacterID string `json:"libraryCharacterId"`
+	LibraryVersion     int    `json:"libraryVersion"`
+}
+
+// CharacterUpdateRequest patches a character; empty strings and nil lists keep
+// the stored value.
+type CharacterUpdateRequest struct {
+	Name            string   `json:"name"`
+	Prompt          string   `json:"prompt"`
+	Personality     string   `json:"personality"`
+	Palette         []string `json:"palette"`
+	StyleNotes      string   `json:"styleNotes"`
+	ReferenceImages []string `json:"referenceImages"`
 }
 
 type Character struct {
-	ID                string `json:"id"`
-	SourceType        string `json:"sourceType"`
-	ReferenceImageURL string `json:"referenceImageUrl"`
-	Status            string `json:"status"`
+	ID                string   `json:"id"`
+	SourceType        string   `json:"sourceType"`
+	Name              string   `json:"name"`
+	Prompt            string   `json:"prompt"`
+	Personality       string   `json:"personality"`
+	Palette           []string `json:"palette"`
+	StyleNotes        string   `json:"styleNotes"`
+	ReferenceImageURL string   `json:"referenceImageUrl"`
+	ReferenceImages   []string `json:"referenceImages"`
+	Status            string   `json:"status"`
+
+	// Set when the character comes from the library. LibraryVersion is the
+	// version in use; LibraryPinned is false when the project follows the latest.
+	LibraryCharacterID string `json:"libraryCharacterId,omitempty"`
+	LibraryVersion     int    `json:"libraryVersion,omitempty"`
+	LibraryPinned      bool   `json:"libraryPinned,omitempty"`
+}
+
+type LibraryCharacter struct {
+	ID              string   `json:"id"`
+	Version         int      `json:"version"`
+	Name            string   `json:"name"`
+	Prompt          string   `json:"prompt"`
+	Personality     string   `json:"personality"`
+	Palette         []string `json:"palette"`
+	StyleNotes      string   `json:"styleNotes"`
+	ReferenceImages []string `json:"referenceImages"`
+	CreatedAt       string   `json:"createdAt"`
+	UpdatedAt       string   `json:"updatedAt,omitempty"`
 }
 
 type DraftUpdateRequest struct {
 	Caption     string `json:"caption"`
 	ImagePrompt string `json:"imagePrompt"`
+
+	// CharacterIDs picks which project characters appear; nil keeps the
+	// current selection and an empty list means every project character.
+	CharacterIDs []string `json:"characterIds"`
+
+	// StylePreset overrides the project preset; "" inherits it and "none"
+	// turns presets off for this draft. nil keeps the current value.
+	StylePreset *string `json:"stylePreset"`
+
+	// Emoji replaces the draft's emoji; nil keeps them.
+	Emoji []string `json:"emoji"`
+}
+
+type ProjectStyleRequest struct {
+	StylePreset string `json:"stylePreset"`
 }
 
 type Draft struct {
-	ID          string `json:"id"`
-	ProjectID   string `json:"projectId"`
-	Index       int    `json:"index"`
-	Caption     string `json:"caption"`
-	ImagePrompt string `json:"imagePrompt"`
-	Status      string `json:"status"`
+	ID           string   `json:"id"`
+	ProjectID    string   `json:"projectId"`
+	Index        int      `json:"index"`
+	Caption      string   `json:"caption"`
+	ImagePrompt  string   `json:"imagePrompt"`
+	Status       string   `json:"status"`
+	CharacterIDs []string `json:"characterIds"`
+	StylePreset  string   `json:"stylePreset"`
+	// Emoji are the emoji the sticker answers to on Telegram.
+	Emoji []string `json:"emoji"`
 }
 
 type Sticker struct {
@@ -88,6 +172,72 @@ type Sticker struct {
 	TransparentURL string `json:"transparentUrl"`
 	Status         string `json:"status"`
 	CreatedAt      string `json:"createdAt"`
+	Source         string `json:"source"`
+
+	Generation *ai.GeneratedImage `json:"generation,omitempty"`
+
+	// ShowCaption overrides the project's caption setting; nil follows it.
+	ShowCaption *bool `json:"showCaption"`
+
+	// Animation holds the frames of a sticker in an animated project.
+	Animation *StickerAnimation `json:"animation,omitempty"`
+
+	// TextArea is where a message sticker's text goes; nil uses the
+	// product's default area.
+	TextArea *TextArea `json:"textArea,omitempty"`
+}
+
+type StickerAnimation struct {
+	Frames []AnimationFrame `json:"frames"`
+	// Loops is how many times the animation plays.
+	Loops int `json:"loops"`
+}
+
+type AnimationFrame struct {
+	ImageURL string `json:"imageUrl"`
+	DelayMs  int    `json:"delayMs"`
+}
+
+// TextArea is a rectangle in canvas pixels, measured from the top-left.
+type TextArea struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// FramesGenerateRequest builds a sticker's animation. Mode "provider" asks
+// the image provider for every frame with the sticker's seed and Motion
+// appended to its prompt; "interpolate" blends between Keyframes, starting
+// from the sticker's own image unless Keyframes already has two or more.
+// DurationMs is one loop, spread evenly over the frames.
+type FramesGenerateRequest struct {
+	Mode       string   `json:"mode"`
+	Frames     int      `json:"frames"`
+	DurationMs int      `json:"durationMs"`
+	Loops      int      `json:"loops"`
+	Motion     string   `json:"motion"`
+	Keyframes  []string `json:"keyframes"`
+}
+
+// StickerRegenerateRequest picks how a sticker is regenerated. Mode
+// "same-seed" reuses the recorded seed with Prompt (or the draft's current
+// prompt); "new-seed" reuses the recorded prompt with a fresh seed. An empty
+// mode generates from the draft as usual.
+type StickerRegenerateRequest struct {
+	Mode   string `json:"mode"`
+	Prompt string `json:"prompt"`
+}
+
+type Candidate struct {
+	ID        string `json:"id"`
+	ProjectID string `json:"projectId"`
+	DraftID   string `json:"draftId"`
+	ImageURL  string `json:"imageUrl"`
+	Selected  bool   `json:"selected"`
+	CreatedAt string `json:"createdAt"`
+
+	Generation *ai.GeneratedImage `json:"generation,omitempty"`
 }
 
 type Job struct {
@@ -98,11 +248,218 @@ type Job struct {
 	ErrorMessage string `json:"errorMessage"`
 }
 
+// CaptionStyle controls how draft captions are drawn onto stickers. Sizes
+// are pixels on the 370x320 sticker canvas.
+type CaptionStyle struct {
+	Enabled         bool     `json:"enabled"`
+	FontID          string   `json:"fontId"`
+	FallbackFontIDs []string `json:"fallbackFontIds"`
+	Size            float64  `json:"size"`
+	Color           string   `json:"color"`
+	OutlineColor    string   `json:"outlineColor"`
+	OutlineWidth    int      `json:"outlineWidth"`
+	Position        string   `json:"position"`
+	Vertical        bool     `json:"vertical"`
+}
+
+// PostProcessConfig holds the local image stages run on each sticker after
+// background removal and before it is fitted to the sticker canvas.
+type PostProcessConfig struct {
+	Defringe DefringeConfig `json:"defringe"`
+	Trim     TrimConfig     `json:"trim"`
+	Outline  OutlineConfig  `json:"outline"`
+}
+
+// DefringeConfig cleans cut-outs: Radius is how far from solid pixels
+// semi-transparent edge pixels are recoloured from them, islands under
+// SpeckSize pixels or never above SpeckAlpha are dropped (0 keeps them all),
+// and Harden snaps alpha outside HardenLow and HardenHigh to fully
+// transparent or opaque.
+type DefringeConfig struct {
+	Enabled    bool `json:"enabled"`
+	Radius     int  `json:"radius"`
+	SpeckSize  int  `json:"speckSize"`
+	SpeckAlpha int  `json:"speckAlpha"`
+	Harden     bool `json:"harden"`
+	HardenLow  int  `json:"hardenLow"`
+	HardenHigh int  `json:"hardenHigh"`
+}
+
+// TrimConfig crops the canvas to the subject. Mode "fit" keeps the whole
+// subject, "fill" covers the canvas and crops the centre, "smart-crop"
+// covers it and keeps the most opaque part.
+type TrimConfig struct {
+	Enabled        bool   `json:"enabled"`
+	Mode           string `json:"mode"`
+	Margin         int    `json:"margin"`
+	AlphaThreshold int    `json:"alphaThreshold"`
+}
+
+// OutlineConfig draws the white border around the cut-out subject. Sizes
+// are pixels on the final sticker canvas.
+type OutlineConfig struct {
+	Enabled  bool         `json:"enabled"`
+	Width    int          `json:"width"`
+	Color    string       `json:"color"`
+	Softness int          `json:"softness"`
+	Shadow   ShadowConfig `json:"shadow"`
+}
+
+type ShadowConfig struct {
+	Enabled bool   `json:"enabled"`
+	OffsetX int    `json:"offsetX"`
+	OffsetY int    `json:"offsetY"`
+	Blur    int    `json:"blur"`
+	Color   string `json:"color"`
+}
+
+// PostProcessUpdateRequest updates the fields present in each stage; the
+// rest keep their current values.
+type PostProcessUpdateRequest struct {
+	Defringe json.RawMessage `json:"defringe"`
+	Trim     json.RawMessage `json:"trim"`
+	Outline  json.RawMessage `json:"outline"`
+}
+
+type StickerCaptionRequest struct {
+	ShowCaption *bool `json:"showCaption"`
+}
+
+// CaptionPreviewRequest renders a style without saving it. Empty fields fall
+// back to the project's saved style, the sticker's draft caption and a blank
+// canvas.
+type CaptionPreviewRequest struct {
+	Style     *CaptionStyle `json:"style"`
+	Text      string        `json:"text"`
+	StickerID string        `json:"stickerId"`
+}
+
+type Font struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Family    string `json:"family"`
+	Bundled   bool   `json:"bundled"`
+	CreatedAt string `json:"createdAt,omitempty"`
+}
+
+type PromptTemplate struct {
+	Kind      string `json:"kind"`
+	Body      string `json:"body"`
+	Source    string `json:"source"`
+	UpdatedAt string `json:"updatedAt,omitempty"`
+}
+
+// PromptTemplatesUpdateRequest sets templates by kind; nil leaves a template
+// unchanged and an empty string resets it to the inherited one.
+type PromptTemplatesUpdateRequest struct {
+	Drafts *string `json:"drafts"`
+	Image  *string `json:"image"`
+}
+
+// PromptRenderRequest previews a template. An empty Template renders the one
+// in effect for ProjectID (or the global one); Vars override project values.
+type PromptRenderRequest struct {
+	ProjectID string            `json:"projectId"`
+	DraftID   string            `json:"draftId"`
+	Kind      string            `json:"kind"`
+	Template  string            `json:"template"`
+	Vars      *PromptRenderVars `json:"vars"`
+}
+
+type PromptRenderVars struct {
+	Theme     string `json:"theme"`
+	Character string `json:"character"`
+	Locale    string `json:"locale"`
+	Style     string `json:"style"`
+	Index     int    `json:"index"`
+	Count     int    `json:"count"`
+	Caption   string `json:"caption"`
+	Prompt    string `json:"prompt"`
+}
+
+type PromptRenderResponse struct {
+	Kind   string `json:"kind"`
+	Prompt string `json:"prompt"`
+}
+
+type ThemeSuggestRequest struct {
+	Seed     string   `json:"seed"`
+	Keywords []string `json:"keywords"`
+	Locale   string   `json:"locale"`
+	Market   string   `json:"market"`
+	Count    int      `json:"count"`
+}
+
 type ThemeSuggestResponse struct {
-	Suggestions []string `json:"suggestions"`
+	Suggestions []string             `json:"suggestions"`
+	Items       []ai.ThemeSuggestion `json:"items"`
+	Source      string               `json:"source"`
+}
+
+// ExportRequest picks where the set is exported: "line" (the default),
+// "telegram", "whatsapp", "discord" (stickers and emoji) or "slack"
+// (emoji). Format ("webp" or "png") and PackName apply to Telegram,
+// Publisher to WhatsApp and PackTitle to both; empty fields fall back to
+// WebP, the project title and, for Publisher, "LINE Sticker Platform".
+// Names maps sticker IDs to Discord and Slack names, which otherwise come
+// from the captions.
+type ExportRequest struct {
+	Target    string            `json:"target"`
+	Format    string            `json:"format"`
+	PackName  string            `json:"packName"`
+	PackTitle string            `json:"packTitle"`
+	Publisher string            `json:"publisher"`
+	Names     map[string]string `json:"names,omitempty"`
 }
 
 type ExportResponse struct {
-	DownloadURL string `json:"downloadUrl"`
-	Warnings    []string `json:"warnings,omitempty"`
+	Target      string            `json:"target"`
+	DownloadURL string            `json:"downloadUrl"`
+	Warnings    []string          `json:"warnings,omitempty"`
+	Validation  *ValidationReport `json:"validation,omitempty"`
+}
+
+type ValidationFinding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+type FileValidation struct {
+	File      string              `json:"file"`
+	StickerID string              `json:"stickerId,omitempty"`
+	Width     int                 `json:"width"`
+	Height    int                 `json:"height"`
+	Bytes     int                 `json:"bytes"`
+	Findings  []ValidationFinding `json:"findings"`
+
+	// Optimization is how the export shrank the file toward its size
+	// limit; Bytes is the final size.
+	Optimization *PNGOptimization `json:"optimization,omitempty"`
+}
+
+// PNGOptimization records a PNG's size before optimization and whether
+// colours were given up to reach TargetBytes.
+type PNGOptimization struct {
+	OriginalBytes int `json:"originalBytes"`
+	TargetBytes   int `json:"targetBytes"`
+	// Colors is the palette size when the file was quantized, 0 when it
+	// kept full colour.
+	Colors         int  `json:"colors,omitempty"`
+	QualityReduced bool `json:"qualityReduced"`
+}
+
+// ValidationReport checks an export against the rules of its target, the
+// LINE Creators Market unless Target says otherwise. Findings are
+// project-wide; Files holds the per-file ones. Errors block export,
+// warnings do not.
+type ValidationReport struct {
+	ProjectID string              `json:"projectId"`
+	Target    string              `json:"target"`
+	Valid     bool                `json:"valid"`
+	Errors    int                 `json:"errors"`
+	Warnings  int                 `json:"warnings"`
+	ZipBytes  int                 `json:"zipBytes"`
+	Findings  []ValidationFinding `json:"findings"`
+	Files     []FileValidation    `json:"files"`
 }
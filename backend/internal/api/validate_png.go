@@ -2,30 +2,146 @@ package api
 
 import (
 	"bytes"
-	"image"
-	"image/color"
-	"image/png"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
 )
 
-func validatePNGSize(data []byte, w, h int) bool {
-	img, err := png.Decode(bytes.NewReader(data))
-	if err != nil {
-		return false
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// PNG colour types from the IHDR chunk.
+const (
+	pngGray      = 0
+	pngRGB       = 2
+	pngPalette   = 3
+	pngGrayAlpha = 4
+	pngRGBA      = 6
+)
+
+// pngInfo is what the validator needs from a PNG's chunks, read without
+// decoding pixels.
+type pngInfo struct {
+	Width, Height int
+	BitDepth      int
+	ColorType     int
+	// Transparency is set by a tRNS chunk, which gives RGB, grey and
+	// palette images transparent pixels without an alpha channel.
+	Transparency bool
+	// DPI is 0 when there is no pHYs chunk in metres.
+	DPI float64
+	// Frames and Plays come from an APNG's acTL chunk; Frames is 0 for a
+	// still PNG and Plays 0 means forever. DurationMs is one loop.
+	Frames     int
+	Plays      int
+	DurationMs int
+}
+
+func (p pngInfo) hasAlpha() bool {
+	return p.ColorType == pngRGBA || p.ColorType == pngGrayAlpha || p.Transparency
+}
+
+func readPNGInfo(data []byte) (pngInfo, error) {
+	info := pngInfo{}
+	if !bytes.HasPrefix(data, pngSignature) {
+		return info, errors.New("not a png")
+	}
+	seenHeader := false
+	for pos := len(pngSignature); pos+8 <= len(data); {
+		size := int(binary.BigEndian.Uint32(data[pos:]))
+		kind := string(data[pos+4 : pos+8])
+		body := pos + 8
+		if size < 0 || body+size+4 > len(data) {
+			return info, errors.New("truncated png")
+		}
+		chunk := data[body : body+size]
+		switch kind {
+		case "IHDR":
+			if size < 13 {
+				return info, errors.New("invalid png header")
+			}
+			info.Width = int(binary.BigEndian.Uint32(chunk[0:]))
+			info.Height = int(binary.BigEndian.Uint32(chunk[4:]))
+			info.BitDepth = int(chunk[8])
+			info.ColorType = int(chunk[9])
+			seenHeader = true
+		case "tRNS":
+			info.Transparency = true
+		case "pHYs":
+			// unit 1 is metres; otherwise only the aspect ratio is known
+			if size >= 9 && chunk[8] == 1 {
+				info.DPI = float64(binary.BigEndian.Uint32(chunk[0:])) * 0.0254
+			}
+		case "acTL":
+			if size >= 8 {
+				info.Frames = int(binary.BigEndian.Uint32(chunk[0:]))
+				info.Plays = int(binary.BigEndian.Uint32(chunk[4:]))
+			}
+		case "fcTL":
+			if size >= 26 {
+				num := float64(binary.BigEndian.Uint16(chunk[20:]))
+				den := float64(binary.BigEndian.Uint16(chunk[22:]))
+				if den == 0 {
+					den = 100
+				}
+				info.DurationMs += int(num/den*1000 + 0.5)
+			}
+		case "IEND":
+			pos = len(data)
+			continue
+		}
+		pos = body + size + 4
+	}
+	if !seenHeader {
+		return info, errors.New("missing png header")
 	}
-	b := img.Bounds()
-	return b.Dx() == w && b.Dy() == h
+	return info, nil
 }
 
-func validatePNGAlpha(data []byte) bool {
-	img, err := png.Decode(bytes.NewReader(data))
-	if err != nil {
-		return false
+// setPNGDPI replaces any pHYs chunk with one for the given resolution,
+// placed right after IHDR. Go's encoder writes none, and LINE reads a
+// missing chunk inconsistently.
+func setPNGDPI(data []byte, dpi float64) ([]byte, error) {
+	if _, err := readPNGInfo(data); err != nil {
+		return nil, err
 	}
-	switch img.(type) {
-	case *image.NRGBA, *image.NRGBA64, *image.RGBA, *image.RGBA64:
-		return true
+	ppm := uint32(dpi/0.0254 + 0.5)
+	phys := make([]byte, 9)
+	binary.BigEndian.PutUint32(phys[0:], ppm)
+	binary.BigEndian.PutUint32(phys[4:], ppm)
+	phys[8] = 1
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)+21))
+	out.Write(pngSignature)
+	for pos := len(pngSignature); pos+8 <= len(data); {
+		size := int(binary.BigEndian.Uint32(data[pos:]))
+		kind := string(data[pos+4 : pos+8])
+		end := pos + 8 + size + 4
+		if end > len(data) {
+			break
+		}
+		if kind != "pHYs" {
+			out.Write(data[pos:end])
+		}
+		if kind == "IHDR" {
+			writePNGChunk(out, "pHYs", phys)
+		}
+		if kind == "IEND" {
+			break
+		}
+		pos = end
 	}
-	// Fallback: detect any alpha channel by converting a pixel (cheap check)
-	c := color.NRGBAModel.Convert(img.At(0, 0)).(color.NRGBA)
-	return c.A < 255 || c.A == 0 || c.A == 255
+	return out.Bytes(), nil
+}
+
+func writePNGChunk(buf *bytes.Buffer, kind string, body []byte) {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(body)))
+	buf.Write(n[:])
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(kind))
+	crc.Write(body)
+	buf.WriteString(kind)
+	buf.Write(body)
+	binary.BigEndian.PutUint32(n[:], crc.Sum32())
+	buf.Write(n[:])
 }
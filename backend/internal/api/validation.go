@@ -0,0 +1,279 @@
+package api
+
+import (
+	"fmt"
+	"image"
+	"net/http"
+	"strings"
+
+	"example.com/app/internal/imageio"
+)
+
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+// LINE Creators Market limits shared by all product types.
+const (
+	lineMaxFileBytes = 1 << 20
+	lineMaxAPNGBytes = 300 << 10
+	lineMaxZipBytes  = 60 << 20
+	lineMargin       = 10
+	lineDPI          = 72
+	// marginAlpha is the opacity at or below which a pixel counts as empty
+	// when checking margins, so faint shadow tails do not trip the rule.
+	marginAlpha = 16
+)
+
+// fileRule is the rule for one kind of file in the set: items may be any
+// even size up to the maximum unless exact is set. Animated files, those
+// with animation limits, must be APNGs within them.
+type fileRule struct {
+	width, height int
+	exact         bool
+	// minSide, when set, is the length at least one side must reach.
+	minSide   int
+	maxBytes  int
+	margin    bool
+	animation *animationLimits
+}
+
+func newValidationReport(projectID, target string) *ValidationReport {
+	return &ValidationReport{ProjectID: projectID, Target: target, Valid: true, Findings: []ValidationFinding{}, Files: []FileValidation{}}
+}
+
+func (r *ValidationReport) count(f ValidationFinding) {
+	if f.Severity == severityError {
+		r.Errors++
+		r.Valid = false
+	} else {
+		r.Warnings++
+	}
+}
+
+func (r *ValidationReport) add(severity, rule, message string) {
+	f := ValidationFinding{Rule: rule, Severity: severity, Message: message}
+	r.Findings = append(r.Findings, f)
+	r.count(f)
+}
+
+// note adds findings made before the files were built, such as on the
+// captions.
+func (r *ValidationReport) note(findings []ValidationFinding) {
+	for _, f := range findings {
+		r.add(f.Severity, f.Rule, f.Message)
+	}
+}
+
+func (r *ValidationReport) addFile(fv FileValidation) {
+	r.Files = append(r.Files, fv)
+	for _, f := range fv.Findings {
+		r.count(f)
+	}
+}
+
+// messages flattens the findings of one severity, prefixed with the file
+// they belong to.
+func (r *ValidationReport) messages(severity string) []string {
+	out := []string{}
+	for _, f := range r.Findings {
+		if f.Severity == severity {
+			out = append(out, f.Message)
+		}
+	}
+	for _, fv := range r.Files {
+		for _, f := range fv.Findings {
+			if f.Severity == severity {
+				out = append(out, fv.File+": "+f.Message)
+			}
+		}
+	}
+	return out
+}
+
+func (r *ValidationReport) summary() string {
+	errs := r.messages(severityError)
+	if len(errs) > 3 {
+		errs = append(errs[:3], fmt.Sprintf("and %d more", len(errs)-3))
+	}
+	return strings.Join(errs, "; ")
+}
+
+func validateStickerCount(r *ValidationReport, n int, spec productSpec) {
+	if !spec.countAllowed(n) {
+		r.add(severityError, "sticker-count", fmt.Sprintf("set has %d items; LINE %s sets have %s", n, spec.Name, spec.countRange()))
+	}
+}
+
+func validateZipSize(r *ValidationReport, size int) {
+	r.ZipBytes = size
+	if size > lineMaxZipBytes {
+		r.add(severityError, "zip-size", fmt.Sprintf("zip is %s, over the %s limit", formatBytes(size), formatBytes(lineMaxZipBytes)))
+	}
+}
+
+// validateFile checks one PNG of the set.
+func validateFile(name, stickerID string, data []byte, rule fileRule) FileValidation {
+	fv := FileValidation{File: name, StickerID: stickerID, Bytes: len(data), Findings: []ValidationFinding{}}
+	add := func(severity, r, message string) {
+		fv.Findings = append(fv.Findings, ValidationFinding{Rule: r, Severity: severity, Message: message})
+	}
+	info, err := readPNGInfo(data)
+	if err != nil {
+		add(severityError, "format", "not a valid PNG: "+err.Error())
+		return fv
+	}
+	fv.Width, fv.Height = info.Width, info.Height
+
+	if len(data) > rule.maxBytes {
+		add(severityError, "file-size", fmt.Sprintf("%s is over the %s limit", formatBytes(len(data)), formatBytes(rule.maxBytes)))
+	}
+	switch {
+	case rule.exact && (info.Width != rule.width || info.Height != rule.height):
+		add(severityError, "dimensions", fmt.Sprintf("%d×%d, must be %d×%d", info.Width, info.Height, rule.width, rule.height))
+	case !rule.exact && (info.Width > rule.width || info.Height > rule.height):
+		add(severityError, "dimensions", fmt.Sprintf("%d×%d, must be at most %d×%d", info.Width, info.Height, rule.width, rule.height))
+	case !rule.exact && (info.Width%2 != 0 || info.Height%2 != 0):
+		add(severityError, "dimensions", fmt.Sprintf("%d×%d, width and height must be even", info.Width, info.Height))
+	case rule.minSide > 0 && info.Width < rule.minSide && info.Height < rule.minSide:
+		add(severityError, "dimensions", fmt.Sprintf("%d×%d, one side must be at least %dpx", info.Width, info.Height, rule.minSide))
+	}
+	if rule.animation != nil {
+		validateAPNG(info, *rule.animation, add)
+	}
+	if info.ColorType == pngGray || info.ColorType == pngGrayAlpha {
+		add(severityWarning, "color-mode", "greyscale PNG; LINE expects RGB")
+	}
+	switch {
+	case info.DPI == 0:
+		add(severityWarning, "resolution", "no resolution set; LINE expects 72 dpi")
+	case info.DPI < lineDPI-0.5:
+		add(severityError, "resolution", fmt.Sprintf("%.0f dpi, must be at least 72 dpi", info.DPI))
+	}
+
+	if !info.hasAlpha() {
+		add(severityError, "transparency", "no alpha channel; the background must be transparent")
+		return fv
+	}
+	img, err := imageio.Decode(data)
+	if err != nil {
+		add(severityError, "format", "cannot decode: "+err.Error())
+		return fv
+	}
+	if !hasTransparentPixel(img) {
+		add(severityError, "transparency", "no transparent pixels; the background must be transparent")
+		return fv
+	}
+	if rule.margin {
+		if m := subjectMargin(img); m < lineMargin {
+			add(severityWarning, "margin", fmt.Sprintf("subject is %dpx from the edge; leave about %dpx", m, lineMargin))
+		}
+	}
+	return fv
+}
+
+// targetRule is a file rule of the Telegram and WhatsApp exports, which
+// only check the format, file size and dimensions.
+type targetRule struct {
+	format        string
+	width, height int
+	// oneSide lets the other side be smaller as long as one side matches.
+	oneSide  bool
+	maxBytes int
+}
+
+func validateTargetFile(name, stickerID string, data []byte, rule targetRule) FileValidation {
+	fv := FileValidation{File: name, StickerID: stickerID, Bytes: len(data), Findings: []ValidationFinding{}}
+	add := func(severity, r, message string) {
+		fv.Findings = append(fv.Findings, ValidationFinding{Rule: r, Severity: severity, Message: message})
+	}
+	cfg, err := imageio.DecodeConfig(data)
+	if err != nil {
+		add(severityError, "format", "cannot read image: "+err.Error())
+		return fv
+	}
+	fv.Width, fv.Height = cfg.Width, cfg.Height
+	if kind := http.DetectContentType(data); kind != "image/"+rule.format {
+		add(severityError, "format", fmt.Sprintf("%s, must be %s", kind, rule.format))
+	}
+	if len(data) > rule.maxBytes {
+		add(severityError, "file-size", fmt.Sprintf("%s is over the %s limit", formatBytes(len(data)), formatBytes(rule.maxBytes)))
+	}
+	switch {
+	case rule.oneSide && (cfg.Width > rule.width || cfg.Height > rule.height || (cfg.Width != rule.width && cfg.Height != rule.height)):
+		add(severityError, "dimensions", fmt.Sprintf("%d×%d, one side must be %dpx and the other at most %dpx", cfg.Width, cfg.Height, rule.width, rule.height))
+	case !rule.oneSide && (cfg.Width != rule.width || cfg.Height != rule.height):
+		add(severityError, "dimensions", fmt.Sprintf("%d×%d, must be %d×%d", cfg.Width, cfg.Height, rule.width, rule.height))
+	}
+	return fv
+}
+
+// validateTextAreaClear warns when the artwork of a message sticker shows
+// in the area kept for the sender's text.
+func validateTextAreaClear(fv *FileValidation, data []byte, area TextArea) {
+	img, err := imageio.Decode(data)
+	if err != nil {
+		return
+	}
+	if covered := textAreaCovered(img, area); covered > textAreaCoverage {
+		message := fmt.Sprintf("artwork covers %.0f%% of the text area; keep it clear for the message", covered*100)
+		fv.Findings = append(fv.Findings, ValidationFinding{Rule: "text-area", Severity: severityWarning, Message: message})
+	}
+}
+
+func validateAPNG(info pngInfo, limits animationLimits, add func(severity, rule, message string)) {
+	if info.Frames == 0 {
+		add(severityError, "animation", "not an animated PNG")
+		return
+	}
+	if info.Frames < limits.minFrames || info.Frames > limits.maxFrames {
+		add(severityError, "animation", fmt.Sprintf("%d frames, must be %d to %d", info.Frames, limits.minFrames, limits.maxFrames))
+	}
+	if info.Plays < 1 || info.Plays > limits.maxLoops {
+		add(severityError, "animation", fmt.Sprintf("loop count %d, must be 1 to %d", info.Plays, limits.maxLoops))
+		return
+	}
+	if total := info.DurationMs * info.Plays; total > limits.maxPlaybackMs {
+		add(severityError, "animation", fmt.Sprintf("plays for %.1fs, the limit is %ds", float64(total)/1000, limits.maxPlaybackMs/1000))
+	}
+}
+
+func unreadableFile(name, stickerID string, err error) FileValidation {
+	return FileValidation{File: name, StickerID: stickerID, Findings: []ValidationFinding{
+		{Rule: "format", Severity: severityError, Message: "cannot read image: " + err.Error()},
+	}}
+}
+
+func hasTransparentPixel(img *image.RGBA) bool {
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// subjectMargin is the smallest distance from the visible subject to an
+// edge of the canvas.
+func subjectMargin(img *image.RGBA) int {
+	b := img.Bounds()
+	box := alphaBounds(img, marginAlpha)
+	if box.Empty() {
+		return b.Dx()
+	}
+	m := box.Min.X - b.Min.X
+	for _, d := range []int{box.Min.Y - b.Min.Y, b.Max.X - box.Max.X, b.Max.Y - box.Max.Y} {
+		if d < m {
+			m = d
+		}
+	}
+	return m
+}
+
+func formatBytes(n int) string {
+	if n >= 1<<20 {
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	}
+	return fmt.Sprintf("%d KB", (n+1023)/1024)
+}
@@ -0,0 +1,474 @@
+package api
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"image"
+	"sort"
+
+	"golang.org/x/image/draw"
+)
+
+// VP8L limits and alphabet sizes.
+const (
+	vp8lSignature   = 0x2f
+	vp8lMaxSide     = 1 << 14
+	vp8lMaxLength   = 4096
+	vp8lMinLength   = 3
+	vp8lLengthCodes = 24
+	vp8lDistCodes   = 40
+	vp8lMaxCodeLen  = 15
+	vp8lMaxCLCLen   = 7
+	// vp8lChain bounds how many earlier positions with the same hash are
+	// tried for a match.
+	vp8lChain = 16
+	vp8lHash  = 1 << 16
+)
+
+// vp8lCodeLengthOrder is the order code length code lengths are written in.
+var vp8lCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// encodeWebP writes img as a lossless WebP (VP8L): the subtract-green
+// transform, LZ77 backward references for repeated runs and rows, and one
+// set of Huffman codes. Fully transparent pixels are stored as transparent
+// black so they compress to almost nothing.
+func encodeWebP(img image.Image) ([]byte, error) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w < 1 || h < 1 || w > vp8lMaxSide || h > vp8lMaxSide {
+		return nil, errors.New("webp: image size out of range")
+	}
+	src := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	argb := make([]uint32, w*h)
+	hasAlpha := false
+	for i := range argb {
+		p := src.Pix[i*4 : i*4+4]
+		if p[3] == 0 {
+			hasAlpha = true
+			continue
+		}
+		if p[3] != 0xff {
+			hasAlpha = true
+		}
+		// subtract green: red and blue are stored relative to green
+		g := p[1]
+		argb[i] = uint32(p[3])<<24 | uint32(p[0]-g)<<16 | uint32(g)<<8 | uint32(p[2]-g)
+	}
+
+	bw := &vp8lWriter{}
+	bw.write(vp8lSignature, 8)
+	bw.write(uint32(w-1), 14)
+	bw.write(uint32(h-1), 14)
+	if hasAlpha {
+		bw.write(1, 1)
+	} else {
+		bw.write(0, 1)
+	}
+	bw.write(0, 3) // version
+	bw.write(1, 1) // transform present
+	bw.write(2, 2) // subtract green
+	bw.write(0, 1) // no more transforms
+	bw.write(0, 1) // no color cache
+	bw.write(0, 1) // no meta prefix codes
+
+	tokens := vp8lTokens(argb, w)
+	hist := [5][]int{
+		make([]int, 256+vp8lLengthCodes),
+		make([]int, 256),
+		make([]int, 256),
+		make([]int, 256),
+		make([]int, vp8lDistCodes),
+	}
+	for _, t := range tokens {
+		if t.length == 0 {
+			hist[0][t.argb>>8&0xff]++
+			hist[1][t.argb>>16&0xff]++
+			hist[2][t.argb&0xff]++
+			hist[3][t.argb>>24]++
+			continue
+		}
+		code, _, _ := vp8lPrefix(t.length)
+		hist[0][256+code]++
+		code, _, _ = vp8lPrefix(t.distCode)
+		hist[4][code]++
+	}
+	codes := [5]huffmanCode{}
+	for i := range hist {
+		codes[i] = bw.writeHuffmanCode(hist[i])
+	}
+
+	for _, t := range tokens {
+		if t.length == 0 {
+			codes[0].write(bw, int(t.argb>>8&0xff))
+			codes[1].write(bw, int(t.argb>>16&0xff))
+			codes[2].write(bw, int(t.argb&0xff))
+			codes[3].write(bw, int(t.argb>>24))
+			continue
+		}
+		code, extraBits, extra := vp8lPrefix(t.length)
+		codes[0].write(bw, 256+code)
+		bw.write(extra, extraBits)
+		code, extraBits, extra = vp8lPrefix(t.distCode)
+		codes[4].write(bw, code)
+		bw.write(extra, extraBits)
+	}
+	data := bw.bytes()
+
+	out := &bytes.Buffer{}
+	pad := len(data) & 1
+	out.WriteString("RIFF")
+	binary.Write(out, binary.LittleEndian, uint32(4+8+len(data)+pad))
+	out.WriteString("WEBPVP8L")
+	binary.Write(out, binary.LittleEndian, uint32(len(data)))
+	out.Write(data)
+	if pad == 1 {
+		out.WriteByte(0)
+	}
+	return out.Bytes(), nil
+}
+
+// vp8lToken is a literal pixel or, when length is set, a copy of length
+// pixels from distCode back.
+type vp8lToken struct {
+	argb     uint32
+	length   int
+	distCode int
+}
+
+// vp8lTokens finds backward references greedily: the pixel to the left and
+// the one above are always tried, then earlier positions whose next two
+// pixels hash the same.
+func vp8lTokens(argb []uint32, width int) []vp8lToken {
+	n := len(argb)
+	head := make([]int32, vp8lHash)
+	for i := range head {
+		head[i] = -1
+	}
+	prev := make([]int32, n)
+	hash := func(i int) int {
+		return int((argb[i]*0x1e35a7bd ^ argb[i+1]*0x9e3779b1) >> 16)
+	}
+	insert := func(i int) {
+		if i+1 < n {
+			k := hash(i)
+			prev[i] = head[k]
+			head[k] = int32(i)
+		}
+	}
+	matchLen := func(i, j int) int {
+		max := n - i
+		if max > vp8lMaxLength {
+			max = vp8lMaxLength
+		}
+		l := 0
+		for l < max && argb[i+l] == argb[j+l] {
+			l++
+		}
+		return l
+	}
+
+	tokens := []vp8lToken{}
+	for i := 0; i < n; {
+		bestLen, bestDist := 0, 0
+		try := func(j int) {
+			if j < 0 || j >= i {
+				return
+			}
+			if l := matchLen(i, j); l > bestLen {
+				bestLen, bestDist = l, i-j
+			}
+		}
+		try(i - 1)
+		try(i - width)
+		if i+1 < n {
+			for j, c := int(head[hash(i)]), 0; j >= 0 && c < vp8lChain; j, c = int(prev[j]), c+1 {
+				try(j)
+			}
+		}
+		if bestLen < vp8lMinLength {
+			tokens = append(tokens, vp8lToken{argb: argb[i]})
+			insert(i)
+			i++
+			continue
+		}
+		tokens = append(tokens, vp8lToken{length: bestLen, distCode: vp8lDistanceCode(bestDist, width)})
+		for k := 0; k < bestLen; k++ {
+			insert(i + k)
+		}
+		i += bestLen
+	}
+	return tokens
+}
+
+// vp8lDistanceCode maps a pixel distance to its code: the two short 2D
+// codes for the pixel above and to the left, otherwise the distance plus
+// 120.
+func vp8lDistanceCode(dist, width int) int {
+	switch dist {
+	case width:
+		return 1
+	case 1:
+		return 2
+	}
+	return dist + 120
+}
+
+// vp8lPrefix splits a length or distance code into its prefix symbol and
+// extra bits.
+func vp8lPrefix(v int) (code, extraBits int, extra uint32) {
+	if v <= 4 {
+		return v - 1, 0, 0
+	}
+	d := v - 1
+	hb := 0
+	for d>>(hb+1) != 0 {
+		hb++
+	}
+	second := (d >> (hb - 1)) & 1
+	extraBits = hb - 1
+	return 2*hb + second, extraBits, uint32(d & (1<<extraBits - 1))
+}
+
+// vp8lWriter packs bits least significant first.
+type vp8lWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+func (bw *vp8lWriter) write(v uint32, n int) {
+	bw.acc |= uint64(v) << bw.nbits
+	bw.nbits += uint(n)
+	for bw.nbits >= 8 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc >>= 8
+		bw.nbits -= 8
+	}
+}
+
+func (bw *vp8lWriter) bytes() []byte {
+	if bw.nbits > 0 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc, bw.nbits = 0, 0
+	}
+	return bw.buf
+}
+
+// huffmanCode holds the bit-reversed canonical code of each symbol, ready
+// to be written least significant bit first.
+type huffmanCode struct {
+	lengths []int
+	codes   []uint32
+}
+
+func (c huffmanCode) write(bw *vp8lWriter, symbol int) {
+	bw.write(c.codes[symbol], c.lengths[symbol])
+}
+
+// writeHuffmanCode writes the prefix code for a histogram and returns it.
+// One or two small symbols use the simple form; anything else is written
+// as code lengths, themselves Huffman coded.
+func (bw *vp8lWriter) writeHuffmanCode(hist []int) huffmanCode {
+	used := []int{}
+	for s, n := range hist {
+		if n > 0 {
+			used = append(used, s)
+		}
+	}
+	if len(used) == 0 {
+		used = []int{0}
+	}
+	if len(used) <= 2 && used[len(used)-1] < 256 {
+		bw.write(1, 1)
+		bw.write(uint32(len(used)-1), 1)
+		if used[0] < 2 {
+			bw.write(0, 1)
+			bw.write(uint32(used[0]), 1)
+		} else {
+			bw.write(1, 1)
+			bw.write(uint32(used[0]), 8)
+		}
+		if len(used) == 2 {
+			bw.write(uint32(used[1]), 8)
+		}
+		lengths := make([]int, len(hist))
+		if len(used) == 2 {
+			lengths[used[0]], lengths[used[1]] = 1, 1
+		}
+		return canonicalCode(lengths)
+	}
+
+	lengths := huffmanLengths(hist, vp8lMaxCodeLen)
+	bw.write(0, 1)
+	type clToken struct {
+		symbol, extraBits int
+		extra             uint32
+	}
+	tokens := []clToken{}
+	for i := 0; i < len(lengths); {
+		if lengths[i] != 0 {
+			tokens = append(tokens, clToken{symbol: lengths[i]})
+			i++
+			continue
+		}
+		run := 1
+		for i+run < len(lengths) && lengths[i+run] == 0 && run < 138 {
+			run++
+		}
+		switch {
+		case run >= 11:
+			tokens = append(tokens, clToken{symbol: 18, extraBits: 7, extra: uint32(run - 11)})
+		case run >= 3:
+			tokens = append(tokens, clToken{symbol: 17, extraBits: 3, extra: uint32(run - 3)})
+		default:
+			for k := 0; k < run; k++ {
+				tokens = append(tokens, clToken{symbol: 0})
+			}
+		}
+		i += run
+	}
+	clHist := make([]int, len(vp8lCodeLengthOrder))
+	for _, t := range tokens {
+		clHist[t.symbol]++
+	}
+	clLengths := huffmanLengths(clHist, vp8lMaxCLCLen)
+	count := 4
+	for i, s := range vp8lCodeLengthOrder {
+		if clLengths[s] != 0 && i+1 > count {
+			count = i + 1
+		}
+	}
+	bw.write(uint32(count-4), 4)
+	for _, s := range vp8lCodeLengthOrder[:count] {
+		bw.write(uint32(clLengths[s]), 3)
+	}
+	bw.write(0, 1) // lengths cover the whole alphabet
+	clCode := canonicalCode(clLengths)
+	for _, t := range tokens {
+		clCode.write(bw, t.symbol)
+		bw.write(t.extra, t.extraBits)
+	}
+	return canonicalCode(lengths)
+}
+
+// huffmanLengths builds code lengths of at most maxLen bits. At least two
+// symbols get a code so the tree is always complete; when the tree is too
+// deep the counts are flattened and it is rebuilt.
+func huffmanLengths(hist []int, maxLen int) []int {
+	counts := append([]int(nil), hist...)
+	used := 0
+	for _, n := range counts {
+		if n > 0 {
+			used++
+		}
+	}
+	for s := 0; used < 2 && s < len(counts); s++ {
+		if counts[s] == 0 {
+			counts[s] = 1
+			used++
+		}
+	}
+	for {
+		lengths := buildHuffman(counts)
+		max := 0
+		for _, l := range lengths {
+			if l > max {
+				max = l
+			}
+		}
+		if max <= maxLen {
+			return lengths
+		}
+		for s, n := range counts {
+			if n > 0 {
+				counts[s] = (n + 1) / 2
+			}
+		}
+	}
+}
+
+type huffmanNode struct {
+	count       int
+	symbol      int
+	left, right *huffmanNode
+}
+
+type huffmanHeap []*huffmanNode
+
+func (h huffmanHeap) Len() int { return len(h) }
+func (h huffmanHeap) Less(i, j int) bool {
+	if h[i].count != h[j].count {
+		return h[i].count < h[j].count
+	}
+	return h[i].symbol < h[j].symbol
+}
+func (h huffmanHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *huffmanHeap) Push(x interface{}) { *h = append(*h, x.(*huffmanNode)) }
+func (h *huffmanHeap) Pop() interface{} {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
+
+func buildHuffman(counts []int) []int {
+	h := &huffmanHeap{}
+	for s, n := range counts {
+		if n > 0 {
+			*h = append(*h, &huffmanNode{count: n, symbol: s})
+		}
+	}
+	heap.Init(h)
+	for h.Len() > 1 {
+		a := heap.Pop(h).(*huffmanNode)
+		b := heap.Pop(h).(*huffmanNode)
+		sym := a.symbol
+		if b.symbol < sym {
+			sym = b.symbol
+		}
+		heap.Push(h, &huffmanNode{count: a.count + b.count, symbol: sym, left: a, right: b})
+	}
+	lengths := make([]int, len(counts))
+	var walk func(n *huffmanNode, depth int)
+	walk = func(n *huffmanNode, depth int) {
+		if n.left == nil {
+			lengths[n.symbol] = depth
+			return
+		}
+		walk(n.left, depth+1)
+		walk(n.right, depth+1)
+	}
+	walk((*h)[0], 0)
+	return lengths
+}
+
+// canonicalCode assigns canonical codes from lengths, shorter codes and
+// then lower symbols first, and bit-reverses them for the writer.
+func canonicalCode(lengths []int) huffmanCode {
+	order := []int{}
+	for s, l := range lengths {
+		if l > 0 {
+			order = append(order, s)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool { return lengths[order[i]] < lengths[order[j]] })
+	c := huffmanCode{lengths: lengths, codes: make([]uint32, len(lengths))}
+	code, prevLen := uint32(0), 0
+	for i, s := range order {
+		l := lengths[s]
+		if i > 0 {
+			code = (code + 1) << uint(l-prevLen)
+		}
+		prevLen = l
+		rev := uint32(0)
+		for k := 0; k < l; k++ {
+			rev |= (code >> uint(k) & 1) << uint(l-1-k)
+		}
+		c.codes[s] = rev
+	}
+	return c
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// testImage fills a w x h image with runs, repeated rows and noise, so the
+// encoder's literal, run and row-copy paths are all exercised. alpha picks
+// each pixel's opacity.
+func testImage(w, h int, seed int64, alpha func(x, y int, r *rand.Rand) uint8) *image.NRGBA {
+	r := rand.New(rand.NewSource(seed))
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		if y > 0 && y%7 == 3 {
+			copy(img.Pix[y*img.Stride:(y+1)*img.Stride], img.Pix[(y-1)*img.Stride:y*img.Stride])
+			continue
+		}
+		for x := 0; x < w; x++ {
+			c := color.NRGBA{R: uint8(x * 5), G: uint8(y * 3), B: uint8(x ^ y), A: alpha(x, y, r)}
+			if x%11 < 4 {
+				c.R, c.G, c.B = 0x40, 0x80, 0xc0
+			}
+			if (x+y)%5 == 0 {
+				c.R, c.G, c.B = uint8(r.Intn(256)), uint8(r.Intn(256)), uint8(r.Intn(256))
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestEncodeWebPRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		w, h  int
+		alpha func(x, y int, r *rand.Rand) uint8
+	}{
+		{"opaque", 97, 61, func(x, y int, r *rand.Rand) uint8 { return 0xff }},
+		{"partly transparent", 128, 90, func(x, y int, r *rand.Rand) uint8 {
+			switch {
+			case x < 20:
+				return 0
+			case x < 40:
+				return uint8(r.Intn(256))
+			}
+			return 0xff
+		}},
+		{"fully transparent", 64, 33, func(x, y int, r *rand.Rand) uint8 { return 0 }},
+		{"sticker size", 512, 512, func(x, y int, r *rand.Rand) uint8 {
+			if (x-256)*(x-256)+(y-256)*(y-256) > 200*200 {
+				return 0
+			}
+			return 0xff
+		}},
+		{"single pixel", 1, 1, func(x, y int, r *rand.Rand) uint8 { return 0x80 }},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := testImage(tt.w, tt.h, int64(i), tt.alpha)
+			data, err := encodeWebP(src)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			decoded, err := webp.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if decoded.Bounds() != src.Bounds() {
+				t.Fatalf("bounds %v, want %v", decoded.Bounds(), src.Bounds())
+			}
+			got := image.NewNRGBA(decoded.Bounds())
+			draw.Draw(got, got.Bounds(), decoded, image.Point{}, draw.Src)
+			for y := 0; y < tt.h; y++ {
+				for x := 0; x < tt.w; x++ {
+					want, have := src.NRGBAAt(x, y), got.NRGBAAt(x, y)
+					if want.A == 0 {
+						if have.A != 0 {
+							t.Fatalf("pixel (%d,%d) = %v, want transparent", x, y, have)
+						}
+						continue
+					}
+					if want != have {
+						t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, have, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeWebPRejectsEmpty(t *testing.T) {
+	if _, err := encodeWebP(image.NewNRGBA(image.Rect(0, 0, 0, 4))); err == nil {
+		t.Fatal("want an error for an empty image")
+	}
+}
@@ -0,0 +1,242 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+const exportTargetWhatsApp = "whatsapp"
+
+// WhatsApp static sticker pack limits.
+const (
+	whatsappSide         = 512
+	whatsappMaxBytes     = 100 << 10
+	whatsappTraySide     = 96
+	whatsappTrayMaxBytes = 50 << 10
+	whatsappMinStickers  = 3
+	whatsappMaxStickers  = 30
+	whatsappMaxEmoji     = 3
+	whatsappMaxName      = 128
+	// whatsappPublisher is the publisher shown when the request names none.
+	whatsappPublisher = "LINE Sticker Platform"
+	// whatsappMaxPosterize is how many low bits per channel may be dropped
+	// to bring a sticker under the size limit.
+	whatsappMaxPosterize = 5
+)
+
+var (
+	whatsappStickerRule = targetRule{format: formatWebP, width: whatsappSide, height: whatsappSide, maxBytes: whatsappMaxBytes}
+	whatsappTrayRule    = targetRule{format: formatPNG, width: whatsappTraySide, height: whatsappTraySide, maxBytes: whatsappTrayMaxBytes}
+)
+
+type whatsappPack struct {
+	Name      string
+	Publisher string
+}
+
+// whatsappContents is one pack of contents.json, the manifest the WhatsApp
+// sticker app template reads.
+type whatsappContents struct {
+	Identifier       string            `json:"identifier"`
+	Name             string            `json:"name"`
+	Publisher        string            `json:"publisher"`
+	TrayImageFile    string            `json:"tray_image_file"`
+	ImageDataVersion string            `json:"image_data_version"`
+	AvoidCache       bool              `json:"avoid_cache"`
+	Animated         bool              `json:"animated_sticker_pack"`
+	Stickers         []whatsappSticker `json:"stickers"`
+}
+
+type whatsappSticker struct {
+	ImageFile string   `json:"image_file"`
+	Emojis    []string `json:"emojis"`
+}
+
+func whatsappPackFor(p *Project, req ExportRequest) whatsappPack {
+	pack := whatsappPack{Name: req.PackTitle, Publisher: req.Publisher}
+	if pack.Name == "" {
+		pack.Name = p.Title
+	}
+	if pack.Publisher == "" {
+		pack.Publisher = whatsappPublisher
+	}
+	return pack
+}
+
+// splitPacks spreads n stickers evenly over as few packs of at most max as
+// possible, so a 40-sticker set becomes two packs of 20.
+func splitPacks(n, max int) []int {
+	packs := (n + max - 1) / max
+	if packs == 0 {
+		return nil
+	}
+	sizes := make([]int, packs)
+	for i := range sizes {
+		sizes[i] = n / packs
+		if i < n%packs {
+			sizes[i]++
+		}
+	}
+	return sizes
+}
+
+// buildWhatsAppZip renders the set as WhatsApp sticker packs, split into
+// packs of at most 30. Each pack gets a folder named by its identifier
+// with its stickers and tray icon; contents.json lists them all. notes are
+// findings already made on the set.
+func buildWhatsAppZip(projectID string, pack whatsappPack, stickers []Sticker, emoji map[string][]string, notes []ValidationFinding) ([]byte, *ValidationReport, error) {
+	if len(stickers) == 0 {
+		return nil, nil, errors.New("no stickers")
+	}
+	report := newValidationReport(projectID, exportTargetWhatsApp)
+	report.note(notes)
+	sorted := sortStickers(stickers)
+	if len(sorted) < whatsappMinStickers {
+		report.add(severityError, "sticker-count", fmt.Sprintf("set has %d stickers; WhatsApp packs need at least %d", len(sorted), whatsappMinStickers))
+	}
+	if n := len([]rune(pack.Name)); n == 0 || n > whatsappMaxName {
+		report.add(severityError, "pack-name", fmt.Sprintf("pack name must be 1-%d characters", whatsappMaxName))
+	}
+	if n := len([]rune(pack.Publisher)); n == 0 || n > whatsappMaxName {
+		report.add(severityError, "publisher", fmt.Sprintf("publisher must be 1-%d characters", whatsappMaxName))
+	}
+
+	files := []exportFile{}
+	packs := []whatsappContents{}
+	sizes := splitPacks(len(sorted), whatsappMaxStickers)
+	start := 0
+	for p, size := range sizes {
+		id := fmt.Sprint(p + 1)
+		contents := whatsappContents{
+			Identifier:       id,
+			Name:             pack.Name,
+			Publisher:        pack.Publisher,
+			TrayImageFile:    "tray.png",
+			ImageDataVersion: "1",
+			Stickers:         []whatsappSticker{},
+		}
+		if len(sizes) > 1 {
+			contents.Name = fmt.Sprintf("%s (%d/%d)", pack.Name, p+1, len(sizes))
+		}
+		members := sorted[start : start+size]
+		start += size
+
+		for i, s := range members {
+			name := fmt.Sprintf("%02d.webp", i+1)
+			path := id + "/" + name
+			data, dropped, err := whatsappImage(stickerURL(s))
+			if err != nil {
+				report.addFile(unreadableFile(path, s.ID, err))
+				continue
+			}
+			fv := validateTargetFile(path, s.ID, data, whatsappStickerRule)
+			if dropped > 0 {
+				message := fmt.Sprintf("colours reduced to %d bits per channel to fit %s", 8-dropped, formatBytes(whatsappMaxBytes))
+				fv.Findings = append(fv.Findings, ValidationFinding{Rule: "quality", Severity: severityWarning, Message: message})
+			}
+			list := checkEmoji(&fv, emoji[s.DraftID], whatsappMaxEmoji, true)
+			report.addFile(fv)
+			files = append(files, exportFile{name: path, stickerID: s.ID, data: data})
+			contents.Stickers = append(contents.Stickers, whatsappSticker{ImageFile: name, Emojis: list})
+		}
+
+		tray := id + "/" + contents.TrayImageFile
+		if data, err := whatsappTray(stickerURL(members[0])); err != nil {
+			report.addFile(unreadableFile(tray, "", err))
+		} else {
+			report.addFile(validateTargetFile(tray, "", data, whatsappTrayRule))
+			files = append(files, exportFile{name: tray, data: data})
+		}
+		packs = append(packs, contents)
+	}
+
+	files = append(files,
+		exportFile{name: "README.txt", data: []byte(whatsappReadme(sizes))},
+		jsonFile("contents.json", map[string]interface{}{
+			"android_play_store_link": "",
+			"ios_app_store_link":      "",
+			"sticker_packs":           packs,
+		}),
+		jsonFile("report.json", report),
+	)
+	data, err := zipFiles(files)
+	if err != nil {
+		return nil, nil, err
+	}
+	report.ZipBytes = len(data)
+	return data, report, nil
+}
+
+// whatsappImage fits a sticker on the 512x512 canvas as WebP. When it is
+// over the size limit, colour precision is reduced a bit per channel at a
+// time until it fits; dropped says how many bits went.
+func whatsappImage(url string) ([]byte, int, error) {
+	src, err := loadImage(url)
+	if err != nil {
+		return nil, 0, err
+	}
+	fitted, err := fitImage(src, whatsappSide, whatsappSide)
+	if err != nil {
+		return nil, 0, err
+	}
+	img := image.NewNRGBA(fitted.Bounds())
+	draw.Draw(img, img.Bounds(), fitted, image.Point{}, draw.Src)
+	var data []byte
+	for dropped := 0; dropped <= whatsappMaxPosterize; dropped++ {
+		data, err = encodeWebP(posterize(img, dropped))
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(data) <= whatsappMaxBytes {
+			return data, dropped, nil
+		}
+	}
+	return data, whatsappMaxPosterize, nil
+}
+
+// posterize drops the low bits of each colour channel, rounding to the
+// middle of the remaining step. Fewer distinct colours compress better.
+func posterize(img *image.NRGBA, bits int) *image.NRGBA {
+	if bits == 0 {
+		return img
+	}
+	out := image.NewNRGBA(img.Rect)
+	mask := uint8(0xff << uint(bits))
+	half := uint8(1 << uint(bits-1))
+	for i := 0; i < len(img.Pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			out.Pix[i+c] = img.Pix[i+c]&mask | half
+		}
+		out.Pix[i+3] = img.Pix[i+3]
+	}
+	return out
+}
+
+func whatsappTray(url string) ([]byte, error) {
+	src, err := loadImage(url)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := fitImage(src, whatsappTraySide, whatsappTraySide)
+	if err != nil {
+		return nil, err
+	}
+	return encodePNG(dst)
+}
+
+func whatsappReadme(sizes []int) string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "WhatsApp Sticker Pack Export\n")
+	for i, n := range sizes {
+		fmt.Fprintf(b, "- %d/: pack of %d stickers (%dx%d WebP, up to %s each) and tray.png (%dx%d)\n",
+			i+1, n, whatsappSide, whatsappSide, formatBytes(whatsappMaxBytes), whatsappTraySide, whatsappTraySide)
+	}
+	b.WriteString("- contents.json: pack names, publisher and each sticker's emoji\n")
+	fmt.Fprintf(b, "\nWhatsApp packs hold %d to %d stickers; larger sets are split evenly.\n", whatsappMinStickers, whatsappMaxStickers)
+	b.WriteString("Copy the folders and contents.json into the assets of the WhatsApp sticker app template.\n")
+	return b.String()
+}
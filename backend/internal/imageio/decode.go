@@ -0,0 +1,189 @@
+// Package imageio reads the images the app accepts, whether uploaded,
+// inlined as data URLs or fetched from providers: PNG, JPEG, GIF and WebP,
+// with JPEG EXIF orientation applied.
+package imageio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+var ErrUnsupported = errors.New("unsupported image type (png, jpeg, gif or webp)")
+
+type imageCodec struct {
+	decode       func(io.Reader) (image.Image, error)
+	decodeConfig func(io.Reader) (image.Config, error)
+}
+
+// imageCodecs are picked by sniffed content type rather than through
+// image.Decode, so the accepted formats do not depend on which packages
+// happen to be imported. GIF decodes its first frame.
+var imageCodecs = map[string]imageCodec{
+	"image/png":  {png.Decode, png.DecodeConfig},
+	"image/jpeg": {jpeg.Decode, jpeg.DecodeConfig},
+	"image/gif":  {gif.Decode, gif.DecodeConfig},
+	"image/webp": {webp.Decode, webp.DecodeConfig},
+}
+
+func codecFor(data []byte) (imageCodec, string, error) {
+	kind := http.DetectContentType(data)
+	codec, ok := imageCodecs[kind]
+	if !ok {
+		return imageCodec{}, kind, ErrUnsupported
+	}
+	return codec, kind, nil
+}
+
+// DecodeConfig reads the dimensions without decoding pixels.
+func DecodeConfig(data []byte) (image.Config, error) {
+	codec, _, err := codecFor(data)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return codec.decodeConfig(bytes.NewReader(data))
+}
+
+// Decode decodes any supported format to RGBA. JPEG EXIF orientation is
+// applied so phone photos come out upright.
+func Decode(data []byte) (*image.RGBA, error) {
+	codec, kind, err := codecFor(data)
+	if err != nil {
+		return nil, err
+	}
+	src, err := codec.decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	img := ToRGBA(src)
+	if kind == "image/jpeg" {
+		img = orient(img, exifOrientation(data))
+	}
+	return img, nil
+}
+
+// ToRGBA returns src as an RGBA image anchored at the origin, copying only
+// when it is not one already.
+func ToRGBA(src image.Image) *image.RGBA {
+	if img, ok := src.(*image.RGBA); ok && img.Rect.Min == (image.Point{}) {
+		return img
+	}
+	b := src.Bounds()
+	img := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(img, img.Bounds(), src, b.Min, draw.Src)
+	return img
+}
+
+// exifOrientation returns the EXIF orientation tag (1-8) of a JPEG, or 1
+// when there is none.
+func exifOrientation(data []byte) int {
+	r := bytes.NewReader(data)
+	var marker [2]byte
+	if _, err := io.ReadFull(r, marker[:]); err != nil || marker != [2]byte{0xFF, 0xD8} {
+		return 1
+	}
+	for {
+		if _, err := io.ReadFull(r, marker[:]); err != nil || marker[0] != 0xFF {
+			return 1
+		}
+		// start of scan: no more metadata
+		if marker[1] == 0xDA {
+			return 1
+		}
+		var size uint16
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil || size < 2 {
+			return 1
+		}
+		segment := make([]byte, size-2)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return 1
+		}
+		if marker[1] == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+	}
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	// compared as uint32 so a huge offset cannot wrap around on 32-bit
+	offset := order.Uint32(tiff[4:8])
+	if offset > uint32(len(tiff)-2) {
+		return 1
+	}
+	ifd := int(offset)
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		e := ifd + 2 + i*12
+		if e+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[e:]) == 0x0112 {
+			if v := int(order.Uint16(tiff[e+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// orient undoes an EXIF orientation so the image displays upright.
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	out := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = w-1-x, y
+			case 3: // rotated 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotated 90 clockwise to display
+				dx, dy = h-1-y, x
+			case 7: // transverse
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 90 counter-clockwise to display
+				dx, dy = y, w-1-x
+			}
+			si := img.PixOffset(x, y)
+			copy(out.Pix[out.PixOffset(dx, dy):], img.Pix[si:si+4])
+		}
+	}
+	return out
+}
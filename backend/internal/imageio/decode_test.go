@@ -0,0 +1,150 @@
+package imageio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// exifJPEG is a w x h JPEG with an EXIF segment holding tiff.
+func exifJPEG(t *testing.T, w, h int, tiff []byte) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+// orientationTIFF is a TIFF header with one IFD holding only the
+// orientation tag.
+func orientationTIFF(order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], 0x0112)
+	order.PutUint16(tiff[12:], 3)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], orientation)
+	return tiff
+}
+
+func TestExifOrientation(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		for want := 1; want <= 8; want++ {
+			data := exifJPEG(t, 6, 4, orientationTIFF(order, uint16(want)))
+			if got := exifOrientation(data); got != want {
+				t.Errorf("%v orientation %d: got %d", order, want, got)
+			}
+			img, err := Decode(data)
+			if err != nil {
+				t.Fatalf("%v orientation %d: %v", order, want, err)
+			}
+			w, h := 6, 4
+			if want >= 5 {
+				w, h = 4, 6
+			}
+			if img.Rect.Dx() != w || img.Rect.Dy() != h {
+				t.Errorf("%v orientation %d: decoded %v, want %dx%d", order, want, img.Rect, w, h)
+			}
+		}
+	}
+}
+
+func TestOrient(t *testing.T) {
+	const w, h = 3, 2
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			src.SetRGBA(x, y, color.RGBA{R: uint8(x), G: uint8(y), A: 0xff})
+		}
+	}
+	// the stored pixel each orientation shows at the top-left
+	topLeft := map[int]image.Point{
+		1: {0, 0}, 2: {w - 1, 0}, 3: {w - 1, h - 1}, 4: {0, h - 1},
+		5: {0, 0}, 6: {0, h - 1}, 7: {w - 1, h - 1}, 8: {w - 1, 0},
+	}
+	for orientation, from := range topLeft {
+		out := orient(src, orientation)
+		wantW, wantH := w, h
+		if orientation >= 5 {
+			wantW, wantH = h, w
+		}
+		if out.Rect.Dx() != wantW || out.Rect.Dy() != wantH {
+			t.Errorf("orientation %d: size %v", orientation, out.Rect)
+			continue
+		}
+		if got, want := out.RGBAAt(0, 0), src.RGBAAt(from.X, from.Y); got != want {
+			t.Errorf("orientation %d: top-left %v, want %v", orientation, got, want)
+		}
+	}
+}
+
+func TestExifOrientationMalformed(t *testing.T) {
+	valid := orientationTIFF(binary.BigEndian, 6)
+	withOffset := func(offset uint32) []byte {
+		tiff := append([]byte{}, valid...)
+		binary.BigEndian.PutUint32(tiff[4:], offset)
+		return tiff
+	}
+	// claims more entries than fit, with the orientation not among those
+	// that do
+	withEntries := func(n uint16) []byte {
+		tiff := append([]byte{}, valid...)
+		binary.BigEndian.PutUint16(tiff[8:], n)
+		binary.BigEndian.PutUint16(tiff[10:], 0x010F)
+		return tiff
+	}
+	tiffs := map[string][]byte{
+		"empty":              {},
+		"short header":       []byte("MM\x00*"),
+		"bad byte order":     append([]byte("XX"), valid[2:]...),
+		"offset past end":    withOffset(uint32(len(valid))),
+		"offset at max":      withOffset(0xFFFFFFFF),
+		"offset wraps int32": withOffset(0x80000000),
+		"too many entries":   withEntries(0xFFFF),
+		"truncated entry":    valid[:15],
+		"bad value":          orientationTIFF(binary.BigEndian, 9),
+	}
+	for name, tiff := range tiffs {
+		data := exifJPEG(t, 4, 4, tiff)
+		if got := exifOrientation(data); got != 1 {
+			t.Errorf("%s: orientation %d, want 1", name, got)
+		}
+		if _, err := Decode(data); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	jpegs := map[string][]byte{
+		"not a jpeg":         []byte("GIF89a"),
+		"soi only":           {0xFF, 0xD8},
+		"segment size 0":     {0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x00},
+		"segment size 1":     {0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x01},
+		"segment past end":   {0xFF, 0xD8, 0xFF, 0xE1, 0xFF, 0xFF, 'E', 'x'},
+		"exif header only":   append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x08}, "Exif\x00\x00"...),
+		"missing marker":     {0xFF, 0xD8, 0x00, 0xE1, 0x00, 0x04, 0, 0},
+		"start of scan only": {0xFF, 0xD8, 0xFF, 0xDA},
+	}
+	for name, data := range jpegs {
+		if got := exifOrientation(data); got != 1 {
+			t.Errorf("%s: orientation %d, want 1", name, got)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package imageio
+
+import (
+	"encoding/base64"
+	"errors"
+	"image"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MaxFetchBytes caps a remote image; provider outputs at full resolution
+// stay well below it.
+const MaxFetchBytes = 20 << 20
+
+var client = &http.Client{Timeout: 20 * time.Second}
+
+// ParseDataURL returns the payload of a base64 data URL of any image type.
+// ok is false when url is not an image data URL at all.
+func ParseDataURL(url string) (data []byte, ok bool, err error) {
+	if !strings.HasPrefix(url, "data:image/") {
+		return nil, false, nil
+	}
+	comma := strings.IndexByte(url, ',')
+	if comma < 0 || !strings.HasSuffix(url[:comma], ";base64") {
+		return nil, true, errors.New("unsupported data url")
+	}
+	data, err = base64.StdEncoding.DecodeString(url[comma+1:])
+	return data, true, err
+}
+
+// Fetch reads the bytes behind an image data URL or an http(s) URL.
+func Fetch(url string) ([]byte, error) {
+	if url == "" {
+		return nil, errors.New("empty url")
+	}
+	if data, ok, err := ParseDataURL(url); ok {
+		return data, err
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, errors.New("image url must be a data url or http(s)")
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, errors.New("image fetch failed")
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, MaxFetchBytes))
+}
+
+// Load fetches and decodes an image data URL or http(s) URL.
+func Load(url string) (*image.RGBA, error) {
+	data, err := Fetch(url)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(data)
+}
@@ -0,0 +1,65 @@
+package imageio
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, image.NewNRGBA(image.Rect(0, 0, 5, 3))); err != nil {
+		t.Fatal(err)
+	}
+	pngData := buf.Bytes()
+	rotated := exifJPEG(t, 6, 4, orientationTIFF(binary.LittleEndian, 6))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sticker.png":
+			w.Write(pngData)
+		case "/photo.jpg":
+			w.Write(rotated)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		url  string
+		w, h int
+	}{
+		{"png data url", "data:image/png;base64," + base64.StdEncoding.EncodeToString(pngData), 5, 3},
+		{"jpeg data url upright", "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(rotated), 4, 6},
+		{"http png", srv.URL + "/sticker.png", 5, 3},
+		{"http jpeg upright", srv.URL + "/photo.jpg", 4, 6},
+		{"not found", srv.URL + "/missing.png", 0, 0},
+		{"plain data url", "data:image/png,raw", 0, 0},
+		{"text data url", "data:text/plain;base64,aGk=", 0, 0},
+		{"file url", "file:///etc/passwd", 0, 0},
+		{"empty", "", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := Load(tt.url)
+			if tt.w == 0 {
+				if err == nil {
+					t.Fatal("want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if img.Rect.Dx() != tt.w || img.Rect.Dy() != tt.h {
+				t.Fatalf("size %v, want %dx%d", img.Rect, tt.w, tt.h)
+			}
+		})
+	}
+}
@@ -1,19 +1,41 @@
 import type {
+  Candidate,
+  CaptionPreviewRequest,
+  CaptionStyle,
   Character,
   CharacterCreateRequest,
+  CharacterUpdateRequest,
   Draft,
   DraftUpdateRequest,
+  ExportRequest,
   ExportResponse,
+  Font,
+  FramesGenerateRequest,
   Job,
+  LibraryCharacter,
+  PostProcessConfig,
+  PostProcessUpdateRequest,
   Project,
   ProjectCreateRequest,
+  ProjectStyleRequest,
   ProjectUpdateRequest,
+  PromptRenderRequest,
+  PromptRenderResponse,
+  PromptTemplate,
+  PromptTemplatesUpdateRequest,
   AIConfigUpdateRequest,
   AICredentialsRequest,
   AIPipelineConfigRequest,
   Provider,
   Sticker,
+  StickerAnimation,
+  StickerCaptionRequest,
+  StickerRegenerateRequest,
+  StylePreset,
+  TextArea,
+  ThemeSuggestRequest,
   ThemeSuggestResponse,
+  ValidationReport,
 } from './types'
 
 const API_BASE_URL = import.meta.env.VITE_API_BASE_URL
@@ -30,12 +52,49 @@ async function request<T>(path: string, options?: RequestInit): Promise<T> {
     if (!res.ok) {
       throw new Error(`API ${res.status}`)
     }
+    if (res.status === 204) {
+      return undefined as T
+    }
     return res.json() as Promise<T>
   } finally {
     clearTimeout(timeout)
   }
 }
 
+// upload posts multipart form data; fetch sets the boundary header itself.
+function upload<T>(path: string, form: FormData): Promise<T> {
+  return request<T>(path, { method: 'POST', headers: {}, body: form })
+}
+
+async function requestBlob(path: string, options?: RequestInit): Promise<Blob> {
+  const res = await fetch(`${API_BASE_URL}${path}`, {
+    headers: { 'Content-Type': 'application/json' },
+    ...options,
+  })
+  if (!res.ok) {
+    throw new Error(`API ${res.status}`)
+  }
+  return res.blob()
+}
+
+function filesForm(files: File[]): FormData {
+  const form = new FormData()
+  files.forEach((f) => form.append('file', f))
+  return form
+}
+
+// validationQuery encodes an export request as /validation query parameters.
+function validationQuery(req: ExportRequest): string {
+  const q = new URLSearchParams()
+  for (const key of ['target', 'format', 'packName', 'packTitle', 'publisher'] as const) {
+    const v = req[key]
+    if (v) q.set(key, v)
+  }
+  Object.entries(req.names ?? {}).forEach(([id, name]) => q.set(`names[${id}]`, name))
+  const s = q.toString()
+  return s ? `?${s}` : ''
+}
+
 export const api = {
   createProject: (body: ProjectCreateRequest) =>
     request<Project>('/projects', {
@@ -81,16 +140,96 @@ export const api = {
   listVerifiedProviders: (projectId: string) =>
     request<{ providers: Provider[] }>(`/projects/${projectId}/verified-providers`),
 
+  setProjectStyle: (projectId: string, body: ProjectStyleRequest) =>
+    request<Project>(`/projects/${projectId}/style`, {
+      method: 'PATCH',
+      body: JSON.stringify(body),
+    }),
+
+  listStylePresets: () => request<StylePreset[]>('/style-presets'),
+
   createCharacter: (projectId: string, body: CharacterCreateRequest) =>
     request<Character>(`/projects/${projectId}/character`, {
       method: 'POST',
       body: JSON.stringify(body),
     }),
 
-  suggestTheme: (projectId: string, seed?: string) =>
+  getCharacter: (projectId: string) =>
+    request<Character>(`/projects/${projectId}/character`),
+
+  updateCharacter: (projectId: string, body: CharacterUpdateRequest) =>
+    request<Character>(`/projects/${projectId}/character`, {
+      method: 'PATCH',
+      body: JSON.stringify(body),
+    }),
+
+  uploadCharacterReferences: (projectId: string, files: File[]) =>
+    upload<Character>(`/projects/${projectId}/character/reference`, filesForm(files)),
+
+  listProjectCharacters: (projectId: string) =>
+    request<Character[]>(`/projects/${projectId}/characters`),
+
+  addProjectCharacter: (projectId: string, body: CharacterCreateRequest) =>
+    request<Character>(`/projects/${projectId}/characters`, {
+      method: 'POST',
+      body: JSON.stringify(body),
+    }),
+
+  updateProjectCharacter: (projectId: string, characterId: string, body: CharacterUpdateRequest) =>
+    request<Character>(`/projects/${projectId}/characters/${characterId}`, {
+      method: 'PATCH',
+      body: JSON.stringify(body),
+    }),
+
+  removeProjectCharacter: (projectId: string, characterId: string) =>
+    request<void>(`/projects/${projectId}/characters/${characterId}`, {
+      method: 'DELETE',
+    }),
+
+  listLibraryCharacters: () => request<LibraryCharacter[]>('/characters'),
+
+  getLibraryCharacter: (characterId: string) =>
+    request<LibraryCharacter>(`/characters/${characterId}`),
+
+  listLibraryCharacterVersions: (characterId: string) =>
+    request<LibraryCharacter[]>(`/characters/${characterId}/versions`),
+
+  createLibraryCharacter: (body: CharacterUpdateRequest) =>
+    request<LibraryCharacter>('/characters', {
+      method: 'POST',
+      body: JSON.stringify(body),
+    }),
+
+  updateLibraryCharacter: (characterId: string, body: CharacterUpdateRequest) =>
+    request<LibraryCharacter>(`/characters/${characterId}`, {
+      method: 'PATCH',
+      body: JSON.stringify(body),
+    }),
+
+  deleteLibraryCharacter: (characterId: string) =>
+    request<void>(`/characters/${characterId}`, {
+      method: 'DELETE',
+    }),
+
+  suggestTheme: (projectId: string, body: ThemeSuggestRequest = {}) =>
     request<ThemeSuggestResponse>(`/projects/${projectId}/theme:suggest`, {
       method: 'POST',
-      body: JSON.stringify({ seed }),
+      body: JSON.stringify(body),
+    }),
+
+  listPromptTemplates: (projectId?: string) =>
+    request<PromptTemplate[]>(projectId ? `/projects/${projectId}/prompt-templates` : '/prompt-templates'),
+
+  updatePromptTemplates: (body: PromptTemplatesUpdateRequest, projectId?: string) =>
+    request<PromptTemplate[]>(projectId ? `/projects/${projectId}/prompt-templates` : '/prompt-templates', {
+      method: 'PATCH',
+      body: JSON.stringify(body),
+    }),
+
+  renderPrompt: (body: PromptRenderRequest) =>
+    request<PromptRenderResponse>('/prompts:render', {
+      method: 'POST',
+      body: JSON.stringify(body),
     }),
 
   generateDrafts: (projectId: string) =>
@@ -115,9 +254,101 @@ export const api = {
   listStickers: (projectId: string) =>
     request<Sticker[]>(`/projects/${projectId}/stickers`),
 
-  regenerateSticker: (stickerId: string) =>
+  uploadStickers: (projectId: string, files: File[], draftIds: string[] = [], captions: string[] = []) => {
+    const form = filesForm(files)
+    draftIds.forEach((id) => form.append('draftId', id))
+    captions.forEach((c) => form.append('caption', c))
+    return upload<Sticker[]>(`/projects/${projectId}/stickers:upload`, form)
+  },
+
+  replaceStickerImage: (stickerId: string, file: File) =>
+    upload<Sticker>(`/stickers/${stickerId}/image`, filesForm([file])),
+
+  regenerateSticker: (stickerId: string, body: StickerRegenerateRequest = {}) =>
     request<Job>(`/stickers/${stickerId}:regenerate`, {
       method: 'POST',
+      body: JSON.stringify(body),
+    }),
+
+  listCandidates: (draftId: string) =>
+    request<Candidate[]>(`/drafts/${draftId}/candidates`),
+
+  selectCandidate: (draftId: string, candidateId: string) =>
+    request<Sticker>(`/drafts/${draftId}/candidates/${candidateId}:select`, {
+      method: 'POST',
+    }),
+
+  setStickerCaption: (stickerId: string, body: StickerCaptionRequest) =>
+    request<Sticker>(`/stickers/${stickerId}/caption`, {
+      method: 'PATCH',
+      body: JSON.stringify(body),
+    }),
+
+  generateFrames: (stickerId: string, body: FramesGenerateRequest = {}) =>
+    request<Job>(`/stickers/${stickerId}/frames:generate`, {
+      method: 'POST',
+      body: JSON.stringify(body),
+    }),
+
+  updateAnimation: (stickerId: string, body: StickerAnimation) =>
+    request<Sticker>(`/stickers/${stickerId}/animation`, {
+      method: 'PATCH',
+      body: JSON.stringify(body),
+    }),
+
+  updateTextArea: (stickerId: string, body: TextArea) =>
+    request<Sticker>(`/stickers/${stickerId}/text-area`, {
+      method: 'PATCH',
+      body: JSON.stringify(body),
+    }),
+
+  stickerPreviewUrl: (stickerId: string, caption?: boolean) =>
+    `${API_BASE_URL}/stickers/${stickerId}/preview.png` + (caption === undefined ? '' : `?caption=${caption ? 'on' : 'off'}`),
+
+  animationPreviewUrl: (stickerId: string) =>
+    `${API_BASE_URL}/stickers/${stickerId}/animation.png`,
+
+  textAreaPreviewUrl: (stickerId: string, text = '') =>
+    `${API_BASE_URL}/stickers/${stickerId}/text-area.png?text=${encodeURIComponent(text)}`,
+
+  projectPreviewUrl: (projectId: string) =>
+    `${API_BASE_URL}/projects/${projectId}/preview.png`,
+
+  getPostProcess: (projectId: string) =>
+    request<PostProcessConfig>(`/projects/${projectId}/post-process`),
+
+  updatePostProcess: (projectId: string, body: PostProcessUpdateRequest) =>
+    request<PostProcessConfig>(`/projects/${projectId}/post-process`, {
+      method: 'PATCH',
+      body: JSON.stringify(body),
+    }),
+
+  getCaptionStyle: (projectId: string) =>
+    request<CaptionStyle>(`/projects/${projectId}/caption-style`),
+
+  updateCaptionStyle: (projectId: string, body: CaptionStyle) =>
+    request<CaptionStyle>(`/projects/${projectId}/caption-style`, {
+      method: 'PATCH',
+      body: JSON.stringify(body),
+    }),
+
+  previewCaptionStyle: (projectId: string, body: CaptionPreviewRequest = {}) =>
+    requestBlob(`/projects/${projectId}/caption-style:preview`, {
+      method: 'POST',
+      body: JSON.stringify(body),
+    }),
+
+  listFonts: () => request<Font[]>('/fonts'),
+
+  uploadFont: (file: File, name?: string) => {
+    const form = filesForm([file])
+    if (name) form.append('name', name)
+    return upload<Font>('/fonts', form)
+  },
+
+  deleteFont: (fontId: string) =>
+    request<void>(`/fonts/${fontId}`, {
+      method: 'DELETE',
     }),
 
   removeBackground: (projectId: string) =>
@@ -125,10 +356,14 @@ export const api = {
       method: 'POST',
     }),
 
-  exportZip: (projectId: string) =>
+  exportZip: (projectId: string, body: ExportRequest = {}) =>
     request<ExportResponse>(`/projects/${projectId}/export`, {
       method: 'POST',
+      body: JSON.stringify(body),
     }),
 
+  validateExport: (projectId: string, req: ExportRequest = {}) =>
+    request<ValidationReport>(`/projects/${projectId}/validation${validationQuery(req)}`),
+
   getJob: (jobId: string) => request<Job>(`/jobs/${jobId}`),
 }
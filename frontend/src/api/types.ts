@@ -7,11 +7,19 @@ export type ProjectStatus =
   | 'EXPORTING'
   | 'DONE'
 
+export type ProjectType =
+  | 'sticker'
+  | 'animated'
+  | 'emoji'
+  | 'popup'
+  | 'effect'
+  | 'message'
+
 export type Project = {
   id: string
   title?: string
   theme?: string
-  stickerCount: 8 | 16 | 24 | 40
+  stickerCount: number
   status: ProjectStatus
   characterId?: string
   aiProvider?: string
@@ -22,15 +30,26 @@ export type Project = {
   imageModel?: string
   bgProvider?: string
   bgModel?: string
+  candidateCount?: number
+  stylePreset?: string
+  locale?: string
+  type?: ProjectType
 }
 
 export type ProjectCreateRequest = {
   title?: string
-  stickerCount: 8 | 16 | 24 | 40
+  stickerCount: number
+  type?: ProjectType
 }
 
 export type ProjectUpdateRequest = {
   theme?: string
+  locale?: string
+  type?: ProjectType
+}
+
+export type ProjectStyleRequest = {
+  stylePreset: string
 }
 
 export type AIConfigUpdateRequest = {
@@ -51,23 +70,64 @@ export type AIPipelineConfigRequest = {
   imageModel?: string
   bgProvider?: string
   bgModel?: string
+  candidateCount?: number
 }
 
 export type AIVerifyRequest = {
   projectId: string
 }
 
+export type CharacterSourceType = 'AI' | 'UPLOAD' | 'HISTORY'
+
 export type CharacterCreateRequest = {
-  sourceType: 'AI' | 'UPLOAD' | 'HISTORY'
+  sourceType?: CharacterSourceType
+  name?: string
   prompt?: string
+  personality?: string
+  palette?: string[]
+  styleNotes?: string
   referenceImageUrl?: string
+  referenceImages?: string[]
+  libraryCharacterId?: string
+  libraryVersion?: number
+}
+
+export type CharacterUpdateRequest = {
+  name?: string
+  prompt?: string
+  personality?: string
+  palette?: string[]
+  styleNotes?: string
+  referenceImages?: string[]
 }
 
 export type Character = {
   id: string
-  sourceType: 'AI' | 'UPLOAD' | 'HISTORY'
+  sourceType: CharacterSourceType
+  name?: string
+  prompt?: string
+  personality?: string
+  palette?: string[]
+  styleNotes?: string
   referenceImageUrl?: string
+  referenceImages?: string[]
   status: 'READY' | 'FAILED'
+  libraryCharacterId?: string
+  libraryVersion?: number
+  libraryPinned?: boolean
+}
+
+export type LibraryCharacter = {
+  id: string
+  version: number
+  name: string
+  prompt: string
+  personality: string
+  palette: string[]
+  styleNotes: string
+  referenceImages: string[]
+  createdAt: string
+  updatedAt?: string
 }
 
 export type Draft = {
@@ -77,11 +137,53 @@ export type Draft = {
   caption: string
   imagePrompt: string
   status: 'DRAFT' | 'APPROVED' | 'REJECTED'
+  characterIds?: string[]
+  stylePreset?: string
+  emoji?: string[]
 }
 
 export type DraftUpdateRequest = {
   caption?: string
   imagePrompt?: string
+  characterIds?: string[]
+  stylePreset?: string
+  emoji?: string[]
+}
+
+export type Generation = {
+  prompt: string
+  negativePrompt?: string
+  seed?: number
+  provider?: string
+  model?: string
+  params?: Record<string, unknown>
+}
+
+export type Candidate = {
+  id: string
+  projectId: string
+  draftId: string
+  imageUrl: string
+  selected: boolean
+  createdAt: string
+  generation?: Generation
+}
+
+export type AnimationFrame = {
+  imageUrl: string
+  delayMs: number
+}
+
+export type StickerAnimation = {
+  frames: AnimationFrame[]
+  loops: number
+}
+
+export type TextArea = {
+  x: number
+  y: number
+  width: number
+  height: number
 }
 
 export type Sticker = {
@@ -91,18 +193,58 @@ export type Sticker = {
   imageUrl: string
   transparentUrl?: string
   status: 'PENDING' | 'GENERATING' | 'READY' | 'FAILED'
+  createdAt?: string
+  source?: 'AI' | 'UPLOAD'
+  generation?: Generation
+  showCaption?: boolean | null
+  animation?: StickerAnimation
+  textArea?: TextArea
+}
+
+export type StickerCaptionRequest = {
+  showCaption: boolean | null
+}
+
+export type StickerRegenerateRequest = {
+  mode?: 'same-seed' | 'new-seed'
+  prompt?: string
+}
+
+export type FramesGenerateRequest = {
+  mode?: 'provider' | 'interpolate'
+  frames?: number
+  durationMs?: number
+  loops?: number
+  motion?: string
+  keyframes?: string[]
 }
 
 export type Job = {
   id: string
-  type: 'GENERATE_DRAFT' | 'GENERATE_IMAGE' | 'REMOVE_BG'
+  type: 'GENERATE_DRAFT' | 'GENERATE_IMAGE' | 'GENERATE_FRAMES' | 'REMOVE_BG'
   status: 'QUEUED' | 'RUNNING' | 'SUCCESS' | 'FAILED'
   progress?: number
   errorMessage?: string
 }
 
+export type ThemeSuggestRequest = {
+  seed?: string
+  keywords?: string[]
+  locale?: string
+  market?: string
+  count?: number
+}
+
+export type ThemeSuggestion = {
+  theme: string
+  rationale: string
+  score: number
+}
+
 export type ThemeSuggestResponse = {
   suggestions: string[]
+  items?: ThemeSuggestion[]
+  source?: 'ai' | 'curated'
 }
 
 export type Provider = {
@@ -111,7 +253,172 @@ export type Provider = {
   models: string[]
 }
 
+export type StylePreset = {
+  id: string
+  name: string
+  promptPrefix: string
+  promptSuffix: string
+  negativePrompt: string
+  providerParams?: Record<string, Record<string, unknown>>
+}
+
+export type CaptionStyle = {
+  enabled: boolean
+  fontId: string
+  fallbackFontIds: string[]
+  size: number
+  color: string
+  outlineColor: string
+  outlineWidth: number
+  position: 'top' | 'bottom' | 'center' | 'left' | 'right'
+  vertical: boolean
+}
+
+export type CaptionPreviewRequest = {
+  style?: CaptionStyle
+  text?: string
+  stickerId?: string
+}
+
+export type Font = {
+  id: string
+  name: string
+  family: string
+  bundled: boolean
+  createdAt?: string
+}
+
+export type DefringeConfig = {
+  enabled: boolean
+  radius: number
+  speckSize: number
+  speckAlpha: number
+  harden: boolean
+  hardenLow: number
+  hardenHigh: number
+}
+
+export type TrimConfig = {
+  enabled: boolean
+  mode: 'fit' | 'fill' | 'smart-crop'
+  margin: number
+  alphaThreshold: number
+}
+
+export type ShadowConfig = {
+  enabled: boolean
+  offsetX: number
+  offsetY: number
+  blur: number
+  color: string
+}
+
+export type OutlineConfig = {
+  enabled: boolean
+  width: number
+  color: string
+  softness: number
+  shadow: ShadowConfig
+}
+
+export type PostProcessConfig = {
+  defringe: DefringeConfig
+  trim: TrimConfig
+  outline: OutlineConfig
+}
+
+export type PostProcessUpdateRequest = {
+  defringe?: Partial<DefringeConfig>
+  trim?: Partial<TrimConfig>
+  outline?: Partial<Omit<OutlineConfig, 'shadow'>> & { shadow?: Partial<ShadowConfig> }
+}
+
+export type PromptKind = 'drafts' | 'image'
+
+export type PromptTemplate = {
+  kind: PromptKind
+  body: string
+  source: 'project' | 'global' | 'default'
+  updatedAt?: string
+}
+
+export type PromptTemplatesUpdateRequest = {
+  drafts?: string | null
+  image?: string | null
+}
+
+export type PromptRenderVars = {
+  theme?: string
+  character?: string
+  locale?: string
+  style?: string
+  index?: number
+  count?: number
+  caption?: string
+  prompt?: string
+}
+
+export type PromptRenderRequest = {
+  projectId?: string
+  draftId?: string
+  kind?: PromptKind
+  template?: string
+  vars?: PromptRenderVars
+}
+
+export type PromptRenderResponse = {
+  kind: PromptKind
+  prompt: string
+}
+
+export type ExportTarget = 'line' | 'telegram' | 'whatsapp' | 'discord' | 'slack'
+
+export type ExportRequest = {
+  target?: ExportTarget
+  format?: 'webp' | 'png'
+  packName?: string
+  packTitle?: string
+  publisher?: string
+  names?: Record<string, string>
+}
+
+export type ValidationFinding = {
+  rule: string
+  severity: 'error' | 'warning'
+  message: string
+}
+
+export type PNGOptimization = {
+  originalBytes: number
+  targetBytes: number
+  colors?: number
+  qualityReduced: boolean
+}
+
+export type FileValidation = {
+  file: string
+  stickerId?: string
+  width: number
+  height: number
+  bytes: number
+  findings: ValidationFinding[]
+  optimization?: PNGOptimization
+}
+
+export type ValidationReport = {
+  projectId: string
+  target: ExportTarget
+  valid: boolean
+  errors: number
+  warnings: number
+  zipBytes: number
+  findings: ValidationFinding[]
+  files: FileValidation[]
+}
+
 export type ExportResponse = {
+  target?: ExportTarget
   downloadUrl: string
   warnings?: string[]
+  validation?: ValidationReport
 }